@@ -5,6 +5,7 @@
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -12,6 +13,7 @@
 	"go.jetify.com/devbox/internal/boxcli/usererr"
 	"go.jetify.com/devbox/internal/devbox"
 	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/lock"
 	"go.jetify.com/devbox/internal/nix"
 )
 
@@ -26,6 +28,7 @@ type addCmdFlags struct {
 	patchGlibc       bool
 	patch            string
 	outputs          []string
+	asOf             string
 }
 
 func addCmd() *cobra.Command {
@@ -75,6 +78,9 @@ func addCmd() *cobra.Command {
 	command.Flags().StringSliceVarP(
 		&flags.outputs, "outputs", "o", []string{},
 		"specify the outputs to select for the nix package")
+	command.Flags().StringVar(
+		&flags.asOf, "as-of", "",
+		"resolve packages to their newest version published before this date (YYYY-MM-DD)")
 
 	_ = command.Flags().MarkDeprecated("patch-glibc", `use --patch=always instead`)
 	command.MarkFlagsMutuallyExclusive("patch", "patch-glibc")
@@ -91,6 +97,10 @@ func addCmdFunc(cmd *cobra.Command, args []string, flags addCmdFlags) error {
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	asOf, err := parseAsOf(flags.asOf)
+	if err != nil {
+		return err
+	}
 
 	opts := devopt.AddOpts{
 		AllowInsecure:    flags.allowInsecure,
@@ -99,6 +109,7 @@ func addCmdFunc(cmd *cobra.Command, args []string, flags addCmdFlags) error {
 		ExcludePlatforms: flags.excludePlatforms,
 		Patch:            flags.patch,
 		Outputs:          flags.outputs,
+		AsOf:             asOf,
 	}
 	if flags.patchGlibc {
 		// Backwards compatibility so --patch-glibc still works.
@@ -106,3 +117,17 @@ func addCmdFunc(cmd *cobra.Command, args []string, flags addCmdFlags) error {
 	}
 	return box.Add(cmd.Context(), args, opts)
 }
+
+// parseAsOf parses the value of an --as-of flag, which is a date or an RFC
+// 3339 time. An empty value returns nil.
+func parseAsOf(value string) (*lock.NixpkgsAsOf, error) {
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return lock.NewNixpkgsAsOf(t), nil
+		}
+	}
+	return nil, usererr.New("invalid --as-of date %q, use a date such as 2024-06-01", value)
+}
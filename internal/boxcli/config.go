@@ -20,14 +20,14 @@ type configFlags struct {
 func (flags *configFlags) register(cmd *cobra.Command) {
 	flags.pathFlag.register(cmd)
 	cmd.Flags().StringVar(
-		&flags.environment, "environment", "dev", "environment to use, when supported (e.g.secrets support dev, prod, preview.)",
+		&flags.environment, "environment", "dev", "environment to use. Applies the matching entry from the \"environments\" section of devbox.json",
 	)
 }
 
 func (flags *configFlags) registerPersistent(cmd *cobra.Command) {
 	flags.pathFlag.registerPersistent(cmd)
 	cmd.PersistentFlags().StringVar(
-		&flags.environment, "environment", "dev", "environment to use, when supported (e.g. secrets support dev, prod, preview.)",
+		&flags.environment, "environment", "dev", "environment to use. Applies the matching entry from the \"environments\" section of devbox.json",
 	)
 }
 
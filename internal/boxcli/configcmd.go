@@ -0,0 +1,288 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetify.com/devbox/internal/boxcli/multi"
+	"go.jetify.com/devbox/internal/boxcli/usererr"
+	"go.jetify.com/devbox/internal/devbox"
+	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/devconfig"
+	"go.jetify.com/devbox/internal/devconfig/configfile"
+	"go.jetify.com/devbox/internal/ux"
+)
+
+type configSetCmdFlags struct {
+	pathFlag
+	json bool
+}
+
+func configCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "config",
+		Short: "Read and edit fields in devbox.json",
+		Long: "Read and edit fields in devbox.json without rewriting the rest of the " +
+			"file. Comments and formatting are preserved.\n\n" +
+			"Fields are addressed with dot-separated paths, such as shell.scripts.test, " +
+			"env.FOO, packages.go.outputs or include.0. Use a backslash to escape a " +
+			"dot in a key.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	command.AddCommand(configGetCmd())
+	command.AddCommand(configSetCmd())
+	command.AddCommand(configUnsetCmd())
+	command.AddCommand(configValidateCmd())
+	command.AddCommand(configMigrateCmd())
+	return command
+}
+
+func configGetCmd() *cobra.Command {
+	flags := pathFlag{}
+	command := &cobra.Command{
+		Use:   "get <path>",
+		Short: "Print the value of a field in devbox.json",
+		Long: "Print the value of a field in devbox.json. Strings are printed as-is, " +
+			"all other values are printed as JSON.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := openConfigFile(flags.path)
+			if err != nil {
+				return err
+			}
+			value, ok, err := cfg.Root.GetPath(args[0])
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return usererr.New("%s is not set in devbox.json", args[0])
+			}
+			var str string
+			if json.Unmarshal(value, &str) == nil {
+				value = []byte(str)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(value))
+			return nil
+		},
+	}
+	flags.register(command)
+	return command
+}
+
+func configSetCmd() *cobra.Command {
+	flags := configSetCmdFlags{}
+	command := &cobra.Command{
+		Use:   "set <path> <value>",
+		Short: "Set the value of a field in devbox.json",
+		Long: "Set the value of a field in devbox.json, creating any missing parent " +
+			"objects. The value is stored as a string unless --json is set.",
+		Example: "  devbox config set shell.scripts.test \"go test ./...\"\n" +
+			"  devbox config set env.FOO bar\n" +
+			"  devbox config set --json packages.go.outputs '[\"out\", \"man\"]'",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := openConfigFile(flags.path)
+			if err != nil {
+				return err
+			}
+			value := []byte(args[1])
+			if !flags.json {
+				value, err = json.Marshal(args[1])
+				if err != nil {
+					return errors.WithStack(err)
+				}
+			}
+			if err := cfg.Root.SetPath(args[0], value); err != nil {
+				return err
+			}
+			return cfg.Root.SaveTo(filepath.Dir(cfg.Root.AbsRootPath))
+		},
+	}
+	flags.register(command)
+	command.Flags().BoolVar(
+		&flags.json, "json", false, "parse the value as JSON instead of storing it as a string")
+	return command
+}
+
+func configUnsetCmd() *cobra.Command {
+	flags := pathFlag{}
+	command := &cobra.Command{
+		Use:   "unset <path>",
+		Short: "Remove a field from devbox.json",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := openConfigFile(flags.path)
+			if err != nil {
+				return err
+			}
+			ok, err := cfg.Root.UnsetPath(args[0])
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return usererr.New("%s is not set in devbox.json", args[0])
+			}
+			return cfg.Root.SaveTo(filepath.Dir(cfg.Root.AbsRootPath))
+		},
+	}
+	flags.register(command)
+	return command
+}
+
+func configValidateCmd() *cobra.Command {
+	flags := pathFlag{}
+	command := &cobra.Command{
+		Use:   "validate",
+		Short: "Check devbox.json for unknown fields and values of the wrong type",
+		Long: "Check devbox.json against a schema generated from the fields that devbox " +
+			"reads. Problems are printed with their line and column. Included plugins " +
+			"are checked when devbox loads them.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigValidateCmd(cmd, flags)
+		},
+	}
+	flags.register(command)
+	return command
+}
+
+func runConfigValidateCmd(cmd *cobra.Command, flags pathFlag) error {
+	var diags []configfile.Diagnostic
+	var file string
+	cfg, err := openConfigFile(flags.path)
+	if validationErr := (&configfile.ValidationError{}); errors.As(err, &validationErr) {
+		// The config has values of the wrong type, so it can't be loaded.
+		diags = validationErr.Diagnostics
+		file = validationErr.Name
+	} else if err != nil {
+		return err
+	} else {
+		diags = cfg.Root.Validate()
+		file = cfg.Root.AbsRootPath
+	}
+
+	for _, d := range diags {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s:%s\n", file, d)
+	}
+	if len(diags) > 0 {
+		return usererr.New("found %d problem(s) in %s", len(diags), file)
+	}
+	ux.Fsuccessf(cmd.ErrOrStderr(), "No problems found in %s\n", file)
+	return nil
+}
+
+type configMigrateCmdFlags struct {
+	pathFlag
+	dryRun      bool
+	allProjects bool
+}
+
+func configMigrateCmd() *cobra.Command {
+	flags := configMigrateCmdFlags{}
+	command := &cobra.Command{
+		Use:   "migrate",
+		Short: "Rewrite deprecated settings in devbox.json and devbox.lock",
+		Long: "Rewrite deprecated settings in devbox.json and devbox.lock to the current " +
+			"format. It moves allow_insecure from devbox.lock to devbox.json, replaces " +
+			"patch_glibc with patch, changes packages without a version to @latest and " +
+			"removes nixpkgs.commit while keeping the commit pinned in devbox.lock.\n\n" +
+			"Comments and formatting in devbox.json are preserved. Use --dry-run to " +
+			"print a diff of the changes without writing them.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigMigrateCmd(cmd, flags)
+		},
+	}
+	flags.register(command)
+	command.Flags().BoolVar(
+		&flags.dryRun, "dry-run", false, "print a diff of the changes instead of writing them")
+	command.Flags().BoolVar(
+		&flags.allProjects, "all-projects", false,
+		"migrate all projects in the working directory, recursively")
+	return command
+}
+
+func runConfigMigrateCmd(cmd *cobra.Command, flags configMigrateCmdFlags) error {
+	opts := &devopt.Opts{
+		Dir:            flags.path,
+		IgnoreWarnings: true,
+		SkipMigrations: true,
+		Stderr:         cmd.ErrOrStderr(),
+	}
+	var boxes []*devbox.Devbox
+	if flags.allProjects {
+		var err error
+		boxes, err = multi.Open(opts)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	} else {
+		box, err := devbox.Open(opts)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		boxes = append(boxes, box)
+	}
+
+	for _, box := range boxes {
+		m, err := box.Migrate(cmd.Context(), devopt.MigrateOpts{DryRun: flags.dryRun})
+		if err != nil {
+			return err
+		}
+		if len(m.Changes) == 0 {
+			ux.Fsuccessf(cmd.ErrOrStderr(), "%s is up to date\n", box.ProjectDir())
+			continue
+		}
+		if flags.dryRun {
+			ux.Finfof(cmd.ErrOrStderr(), "Would migrate %s:\n", box.ProjectDir())
+		} else {
+			ux.Fsuccessf(cmd.ErrOrStderr(), "Migrated %s:\n", box.ProjectDir())
+		}
+		for _, change := range m.Changes {
+			fmt.Fprintf(cmd.ErrOrStderr(), "  - %s\n", change)
+		}
+		if flags.dryRun {
+			if _, err := cmd.OutOrStdout().Write(m.Diff); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+	return nil
+}
+
+// openConfigFile loads the devbox config without opening the whole project,
+// so that it can be edited even when its packages or includes can't be
+// resolved.
+func openConfigFile(path string) (*devconfig.Config, error) {
+	var cfg *devconfig.Config
+	var err error
+	if path == "" {
+		cfg, err = devconfig.Find(".")
+		if errors.Is(err, devconfig.ErrNotFound) {
+			return nil, usererr.New("no devbox.json found in the current directory (or any parent directories). Did you run `devbox init` yet?")
+		}
+	} else {
+		cfg, err = devconfig.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, usererr.New("the devbox config path %q does not exist.", path)
+		}
+		if errors.Is(err, devconfig.ErrNotFound) {
+			return nil, usererr.New("no devbox.json found in %q. Did you run `devbox init` yet?", path)
+		}
+	}
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "Error loading devbox.json.")
+	}
+	return cfg, nil
+}
@@ -59,6 +59,7 @@ func generateCmd() *cobra.Command {
 	command.AddCommand(dockerfileCmd())
 	command.AddCommand(debugCmd())
 	command.AddCommand(direnvCmd())
+	command.AddCommand(gitattributesCmd())
 	command.AddCommand(genReadmeCmd())
 	flags.config.register(command)
 
@@ -168,6 +169,35 @@ func direnvCmd() *cobra.Command {
 	return command
 }
 
+func gitattributesCmd() *cobra.Command {
+	flags := &generateCmdFlags{}
+	command := &cobra.Command{
+		Use:   "gitattributes",
+		Short: "Set up a git merge driver for devbox.lock",
+		Long: "Add devbox.lock to .gitattributes with a merge driver that runs " +
+			"`devbox lock merge`, and configure the driver in the git repository. " +
+			"The driver merges lockfile changes from both sides of a merge, so " +
+			"devbox.lock only conflicts when both sides update a package to different versions.\n\n" +
+			"Git doesn't share merge driver settings, so everyone who clones the " +
+			"repository needs to run this command once.",
+		Args: cobra.MaximumNArgs(0),
+		// Override the generate command's check, this doesn't need nix.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:    flags.config.path,
+				Stderr: cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			return box.GenerateGitattributes(cmd.Context())
+		},
+	}
+	flags.config.register(command)
+	return command
+}
+
 func genReadmeCmd() *cobra.Command {
 	flags := &GenerateReadmeCmdFlags{}
 
@@ -237,9 +267,9 @@ func genAliasCmd() *cobra.Command {
 					cmd.OutOrStdout(),
 					"alias %s%s='devbox -c \"%s\" run %s'\n",
 					lo.Ternary(flags.noPrefix, "", prefix+"-"),
-					script,
+					script.Name,
 					box.ProjectDir(),
-					script,
+					script.Name,
 				)
 			}
 			return nil
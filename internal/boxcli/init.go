@@ -5,7 +5,9 @@
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -35,8 +37,8 @@ func initCmd() *cobra.Command {
 			if path == "" || path == "." {
 				path, _ = os.Getwd()
 			}
-			if errors.Is(err, os.ErrExist) {
-				ux.Fwarningf(cmd.ErrOrStderr(), "devbox.json already exists in %q.", path)
+			if pathErr := (&fs.PathError{}); errors.As(err, &pathErr) && errors.Is(err, fs.ErrExist) {
+				ux.Fwarningf(cmd.ErrOrStderr(), "%s already exists in %q.", filepath.Base(pathErr.Path), path)
 				return nil
 			}
 			if err != nil {
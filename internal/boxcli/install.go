@@ -18,6 +18,7 @@
 type installCmdFlags struct {
 	runCmdFlags
 	tidyLockfile bool
+	verify       bool
 }
 
 func installCmd() *cobra.Command {
@@ -38,6 +39,11 @@ func installCmd() *cobra.Command {
 		"Fix missing store paths in the devbox.lock file.",
 		// Could potentially do more in the future.
 	)
+	command.Flags().BoolVar(
+		&flags.verify, "verify", false,
+		"Check that installed store paths match the NAR hashes in devbox.lock "+
+			"and haven't been modified in the nix store.",
+	)
 
 	return command
 }
@@ -64,6 +70,11 @@ func installCmdFunc(cmd *cobra.Command, flags installCmdFlags) error {
 			return errors.WithStack(err)
 		}
 	}
+	if flags.verify {
+		if err = box.VerifyStorePaths(ctx); err != nil {
+			return errors.WithStack(err)
+		}
+	}
 	fmt.Fprintln(cmd.ErrOrStderr(), "Finished installing packages.")
 	return nil
 }
@@ -0,0 +1,261 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetify.com/devbox/internal/boxcli/usererr"
+	"go.jetify.com/devbox/internal/devbox"
+	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/fileutil"
+	"go.jetify.com/devbox/internal/lock"
+	"go.jetify.com/devbox/internal/ux"
+)
+
+type lockCmdFlags struct {
+	pathFlag
+	systems []string
+}
+
+func lockCmd() *cobra.Command {
+	flags := lockCmdFlags{}
+	command := &cobra.Command{
+		Use:   "lock",
+		Short: "Update devbox.lock without installing packages",
+		Long: "Resolve every package in devbox.json and add the store paths of its " +
+			"outputs to devbox.lock for each system in --systems. Packages are only " +
+			"evaluated for other systems, not built, so this works on any machine.\n\n" +
+			"Lock the systems that your team uses so that `devbox install` on one system " +
+			"doesn't rewrite the lockfile for another. By default, the systems already " +
+			"in devbox.lock are locked.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLockCmd(cmd, flags)
+		},
+	}
+	flags.register(command)
+	command.Flags().StringSliceVar(
+		&flags.systems, "systems", nil,
+		"systems to lock packages for, such as x86_64-linux,aarch64-darwin")
+	command.AddCommand(lockDiffCmd())
+	command.AddCommand(lockMergeCmd())
+	command.AddCommand(lockVerifyCmd())
+	return command
+}
+
+func runLockCmd(cmd *cobra.Command, flags lockCmdFlags) error {
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:    flags.path,
+		Stderr: cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := box.Lock(cmd.Context(), devopt.LockOpts{Systems: flags.systems}); err != nil {
+		return err
+	}
+	ux.Fsuccessf(cmd.ErrOrStderr(), "Updated devbox.lock\n")
+	return nil
+}
+
+type lockDiffCmdFlags struct {
+	pathFlag
+	format string
+}
+
+func lockDiffCmd() *cobra.Command {
+	flags := lockDiffCmdFlags{}
+	command := &cobra.Command{
+		Use:   "diff [<old> [<new>]]",
+		Short: "Show the package changes between two versions of devbox.lock",
+		Long: "Show the packages that were added, removed or updated between two " +
+			"versions of devbox.lock, with their versions, resolved revisions and " +
+			"plugin versions.\n\n" +
+			"<old> and <new> are git revisions or paths to lockfiles. <old> defaults to " +
+			"HEAD and <new> defaults to the current devbox.lock. Use --format markdown " +
+			"to paste the changes into a pull request.",
+		Example: "  devbox lock diff\n" +
+			"  devbox lock diff main HEAD --format markdown\n" +
+			"  devbox lock diff old/devbox.lock devbox.lock --format json",
+		Args: cobra.MaximumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLockDiffCmd(cmd, args, flags)
+		},
+	}
+	flags.register(command)
+	command.Flags().StringVar(
+		&flags.format, "format", "text", "output format: text, markdown or json")
+	return command
+}
+
+func runLockDiffCmd(cmd *cobra.Command, args []string, flags lockDiffCmdFlags) error {
+	if !slices.Contains([]string{"text", "markdown", "json"}, flags.format) {
+		return usererr.New("unknown format %q, must be text, markdown or json", flags.format)
+	}
+
+	// Lockfile paths are read directly so that two of them can be compared
+	// outside of a project. The project is only opened for git revisions and
+	// the current devbox.lock.
+	var box *devbox.Devbox
+	readLockfile := func(rev string) (*lock.File, error) {
+		if rev != "" && fileutil.IsFile(rev) {
+			return lock.ReadFile(rev)
+		}
+		if box == nil {
+			var err error
+			box, err = devbox.Open(&devopt.Opts{
+				Dir:            flags.path,
+				IgnoreWarnings: true,
+				SkipMigrations: true,
+				Stderr:         cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+		return box.LockfileAt(cmd.Context(), rev)
+	}
+
+	revs := append(args, make([]string, 2-len(args))...)
+	if len(args) == 0 {
+		revs[0] = "HEAD"
+	}
+	from, err := readLockfile(revs[0])
+	if err != nil {
+		return err
+	}
+	to, err := readLockfile(revs[1])
+	if err != nil {
+		return err
+	}
+
+	diff := devbox.DiffLockfiles(from, to)
+	switch flags.format {
+	case "markdown":
+		fmt.Fprint(cmd.OutOrStdout(), diff.Markdown())
+	case "json":
+		b, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(b))
+	default:
+		fmt.Fprint(cmd.OutOrStdout(), diff.Text())
+	}
+	return nil
+}
+
+func lockMergeCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "merge <base> <ours> <theirs>",
+		Short: "Merge two versions of devbox.lock (git merge driver)",
+		Long: "Do a three-way merge of two versions of devbox.lock and write the result " +
+			"to <ours>. It's meant to be used as a git merge driver with the arguments " +
+			"%O %A %B. Run `devbox generate gitattributes` to set it up.\n\n" +
+			"Packages that changed on only one side are merged automatically. When both " +
+			"sides changed the same package, the newer resolution wins. The merge only " +
+			"fails if both sides updated a package to different versions.",
+		Args: cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLockMergeCmd(cmd, args[0], args[1], args[2])
+		},
+	}
+	return command
+}
+
+func runLockMergeCmd(cmd *cobra.Command, basePath, oursPath, theirsPath string) error {
+	base, err := lock.ReadFile(basePath)
+	if err != nil {
+		return err
+	}
+	ours, err := lock.ReadFile(oursPath)
+	if err != nil {
+		return err
+	}
+	theirs, err := lock.ReadFile(theirsPath)
+	if err != nil {
+		return err
+	}
+
+	merged, conflicts := lock.Merge(base, ours, theirs)
+	data, err := merged.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(oursPath, data, 0o644); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	for _, conflict := range conflicts {
+		fmt.Fprintf(cmd.ErrOrStderr(), "  - %s\n", conflict)
+	}
+	return usererr.New(
+		"devbox.lock has %d conflicting package(s). The merged lockfile keeps our "+
+			"version of them. Run `devbox update <package>` to pick a version.",
+		len(conflicts),
+	)
+}
+
+func lockVerifyCmd() *cobra.Command {
+	flags := lockCmdFlags{}
+	command := &cobra.Command{
+		Use:   "verify",
+		Short: "Check that devbox.lock is up to date with devbox.json",
+		Long: "Check that devbox.lock is up to date with devbox.json without using the " +
+			"network. Prints a JSON report of any problems to stdout and exits with a " +
+			"non-zero status if there are any, so it can be used in CI to catch a " +
+			"lockfile that `devbox install` would change.\n\n" +
+			"Use --systems to check that packages are locked for each system that the " +
+			"project is used on. By default, the systems already in devbox.lock are checked.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLockVerifyCmd(cmd, flags)
+		},
+	}
+	flags.register(command)
+	command.Flags().StringSliceVar(
+		&flags.systems, "systems", nil,
+		"systems that packages must be locked for, such as x86_64-linux or aarch64-darwin")
+	return command
+}
+
+func runLockVerifyCmd(cmd *cobra.Command, flags lockCmdFlags) error {
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:            flags.path,
+		IgnoreWarnings: true,
+		SkipMigrations: true,
+		Stderr:         cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	report := box.VerifyLock(cmd.Context(), devopt.VerifyLockOpts{Systems: flags.systems})
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(b))
+
+	if len(report.Problems) == 0 {
+		ux.Fsuccessf(cmd.ErrOrStderr(), "devbox.lock is up to date\n")
+		return nil
+	}
+	for _, problem := range report.Problems {
+		fmt.Fprintf(cmd.ErrOrStderr(), "  - %s\n", problem.Message)
+	}
+	return usererr.New(
+		"devbox.lock is out of date: found %d problem(s). Run `devbox install` to update it.",
+		len(report.Problems),
+	)
+}
@@ -47,7 +47,11 @@ func (ex *midcobraExecutable) AddMiddleware(mids ...Middleware) {
 func (ex *midcobraExecutable) Execute(ctx context.Context, args []string) int {
 	// Ensure cobra uses the same arguments
 	ex.cmd.SetContext(ctx)
+	// Parse the root command's persistent flags for the middlewares. Flags of
+	// subcommands are unknown to the root command, so don't stop at them.
+	ex.cmd.FParseErrWhitelist.UnknownFlags = true
 	_ = ex.cmd.ParseFlags(args)
+	ex.cmd.FParseErrWhitelist.UnknownFlags = false
 
 	// Run the 'pre' hooks
 	for _, m := range ex.middlewares {
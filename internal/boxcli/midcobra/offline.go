@@ -0,0 +1,43 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package midcobra
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"go.jetify.com/devbox/internal/envir"
+)
+
+// OfflineMiddleware sets DEVBOX_OFFLINE when the offline flag is set, so that
+// the rest of devbox and any devbox processes it starts don't use the network.
+type OfflineMiddleware struct {
+	flag *pflag.Flag
+}
+
+var _ Middleware = (*OfflineMiddleware)(nil)
+
+func (o *OfflineMiddleware) AttachToFlag(flags *pflag.FlagSet, flagName string) {
+	flags.Bool(
+		flagName,
+		false,
+		"Don't use the network. Packages are installed from devbox.lock and the local nix store. "+
+			"Same as setting "+envir.DevboxOffline+"=1",
+	)
+	o.flag = flags.Lookup(flagName)
+}
+
+func (o *OfflineMiddleware) preRun(cmd *cobra.Command, args []string) {
+	if o == nil || !o.flag.Changed {
+		return
+	}
+	if enabled, _ := strconv.ParseBool(o.flag.Value.String()); enabled {
+		_ = os.Setenv(envir.DevboxOffline, "1")
+	}
+}
+
+func (o *OfflineMiddleware) postRun(cmd *cobra.Command, args []string, runErr error) {}
@@ -21,7 +21,7 @@ func(path string, dirEntry fs.DirEntry, err error) error {
 				return err
 			}
 
-			if !dirEntry.IsDir() && filepath.Base(path) == configfile.DefaultName {
+			if !dirEntry.IsDir() && configfile.IsConfigName(filepath.Base(path)) {
 				optsCopy := *opts
 				optsCopy.Dir = path
 				box, err := devbox.Open(&optsCopy)
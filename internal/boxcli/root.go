@@ -23,8 +23,9 @@
 type cobraFunc func(cmd *cobra.Command, args []string) error
 
 var (
-	debugMiddleware = &midcobra.DebugMiddleware{}
-	traceMiddleware = &midcobra.TraceMiddleware{}
+	debugMiddleware   = &midcobra.DebugMiddleware{}
+	offlineMiddleware = &midcobra.OfflineMiddleware{}
+	traceMiddleware   = &midcobra.TraceMiddleware{}
 )
 
 type rootCmdFlags struct {
@@ -54,6 +55,7 @@ func RootCmd() *cobra.Command {
 
 	// Stable commands
 	command.AddCommand(addCmd())
+	command.AddCommand(configCmd())
 	command.AddCommand(createCmd())
 	command.AddCommand(generateCmd())
 	command.AddCommand(globalCmd())
@@ -62,11 +64,13 @@ func RootCmd() *cobra.Command {
 	command.AddCommand(installCmd())
 	command.AddCommand(integrateCmd())
 	command.AddCommand(listCmd())
+	command.AddCommand(lockCmd())
 	command.AddCommand(logCmd())
 	command.AddCommand(patchCmd())
 	command.AddCommand(removeCmd())
 	command.AddCommand(runCmd(runFlagDefaults{}))
 	command.AddCommand(searchCmd())
+	command.AddCommand(searchIndexCmd())
 	command.AddCommand(servicesCmd())
 	command.AddCommand(setupCmd())
 	command.AddCommand(shellCmd(shellFlagDefaults{}))
@@ -93,6 +97,7 @@ func RootCmd() *cobra.Command {
 		&flags.quiet, "quiet", "q", false, "suppresses logs")
 	debugMiddleware.AttachToFlag(command.PersistentFlags(), "debug")
 	traceMiddleware.AttachToFlag(command.PersistentFlags(), "trace")
+	offlineMiddleware.AttachToFlag(command.PersistentFlags(), "offline")
 
 	return command
 }
@@ -102,6 +107,7 @@ func Execute(ctx context.Context, args []string) int {
 	rootCmd := RootCmd()
 	exe := midcobra.New(rootCmd)
 	exe.AddMiddleware(traceMiddleware)
+	exe.AddMiddleware(offlineMiddleware)
 	exe.AddMiddleware(midcobra.Telemetry())
 	exe.AddMiddleware(debugMiddleware)
 	return exe.Execute(ctx, wrapArgsForRun(rootCmd, args))
@@ -32,6 +32,7 @@ type runCmdFlags struct {
 	listScripts  bool
 	recomputeEnv bool
 	allProjects  bool
+	groups       []string
 }
 
 // runFlagDefaults are the flag default values that differ
@@ -70,6 +71,8 @@ func runCmd(defaults runFlagDefaults) *cobra.Command {
 	)
 	_ = command.Flags().MarkHidden("omit-nix-env")
 	command.Flags().BoolVar(&flags.recomputeEnv, "recompute", true, "recompute environment if needed")
+	command.Flags().StringSliceVar(
+		&flags.groups, "with", nil, "optional package groups from devbox.json to install before running")
 	command.Flags().BoolVar(
 		&flags.allProjects,
 		"all-projects",
@@ -77,12 +80,18 @@ func runCmd(defaults runFlagDefaults) *cobra.Command {
 		"run command in all projects in the working directory, recursively. If command is not found in any project, it will be skipped.",
 	)
 
-	command.ValidArgs = listScripts(command, flags)
+	for _, script := range listScripts(command, flags) {
+		// Shell completion shows the text after a tab as a description.
+		command.ValidArgs = append(
+			command.ValidArgs,
+			strings.TrimSuffix(script.Name+"\t"+script.Description, "\t"),
+		)
+	}
 
 	return command
 }
 
-func listScripts(cmd *cobra.Command, flags runCmdFlags) []string {
+func listScripts(cmd *cobra.Command, flags runCmdFlags) []devbox.ScriptInfo {
 	path := flags.config.path
 
 	// Special code path for shell completion.
@@ -110,12 +119,12 @@ func listScripts(cmd *cobra.Command, flags runCmdFlags) []string {
 			slog.Error("failed to open devbox", "err", err)
 			return nil
 		}
-		scripts := []string{}
+		scripts := []devbox.ScriptInfo{}
 		for _, box := range boxes {
 			scripts = append(scripts, box.ListScripts()...)
 		}
-		sort.Strings(scripts)
-		return lo.Uniq(scripts)
+		sort.SliceStable(scripts, func(i, j int) bool { return scripts[i].Name < scripts[j].Name })
+		return lo.UniqBy(scripts, func(s devbox.ScriptInfo) string { return s.Name })
 	}
 	box, err := devbox.Open(devboxOpts)
 	if err != nil {
@@ -134,8 +143,13 @@ func runScriptCmd(cmd *cobra.Command, args []string, flags runCmdFlags) error {
 			return nil
 		}
 		fmt.Fprintln(cmd.OutOrStdout(), "Available scripts:")
+		width := 0
+		for _, p := range scripts {
+			width = max(width, len(p.Usage))
+		}
 		for _, p := range scripts {
-			fmt.Fprintf(cmd.OutOrStdout(), "* %s\n", p)
+			line := fmt.Sprintf("* %-*s  %s", width, p.Usage, p.Description)
+			fmt.Fprintln(cmd.OutOrStdout(), strings.TrimRight(line, " "))
 		}
 		return nil
 	}
@@ -156,6 +170,7 @@ func runScriptCmd(cmd *cobra.Command, args []string, flags runCmdFlags) error {
 		Dir:         path,
 		Env:         env,
 		Environment: flags.config.environment,
+		Groups:      flags.groups,
 		Stderr:      cmd.ErrOrStderr(),
 	}
 
@@ -192,7 +207,9 @@ func runScriptCmd(cmd *cobra.Command, args []string, flags runCmdFlags) error {
 
 	if flags.allProjects {
 		boxes = lo.Filter(boxes, func(box *devbox.Devbox, _ int) bool {
-			return slices.Contains(box.ListScripts(), script)
+			return slices.ContainsFunc(box.ListScripts(), func(s devbox.ScriptInfo) bool {
+				return s.Name == script
+			})
 		})
 	}
 
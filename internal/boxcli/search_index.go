@@ -0,0 +1,140 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetify.com/devbox/internal/envir"
+	"go.jetify.com/devbox/internal/searcher"
+	"go.jetify.com/devbox/internal/ux"
+	"go.jetify.com/devbox/nix/flake"
+)
+
+type searchIndexBuildCmdFlags struct {
+	nixpkgs string
+	from    string
+}
+
+type searchIndexServeCmdFlags struct {
+	addr string
+}
+
+func searchIndexCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "search-index",
+		Short: "Build and serve a local package search index",
+		Long: "Build and serve a package search index from pinned nixpkgs commits. " +
+			"Set " + envir.DevboxSearchIndex + " to the path of an index to use it instead of " +
+			"the devbox search service, or serve it and set " + envir.DevboxSearchHost +
+			" to its URL.",
+	}
+	command.AddCommand(searchIndexBuildCmd())
+	command.AddCommand(searchIndexServeCmd())
+	return command
+}
+
+func searchIndexBuildCmd() *cobra.Command {
+	flags := &searchIndexBuildCmdFlags{}
+	command := &cobra.Command{
+		Use:   "build <index.json>",
+		Short: "Add the packages of a nixpkgs commit to a search index",
+		Long: "Add the packages of a nixpkgs commit to a search index, creating it if " +
+			"it doesn't exist. Each commit adds one version of every package, so " +
+			"building an index from several commits lets it resolve several versions.",
+		Args:    cobra.ExactArgs(1),
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return searchIndexBuildCmdFunc(cmd, args[0], flags)
+		},
+	}
+	command.Flags().StringVar(
+		&flags.nixpkgs, "nixpkgs", "github:NixOS/nixpkgs/nixpkgs-unstable",
+		"the nixpkgs flake reference to index")
+	command.Flags().StringVar(
+		&flags.from, "from", "",
+		"read packages from the output of `nix search --json` or `nix-env -qa --json` "+
+			"for the nixpkgs commit instead of running nix search")
+	return command
+}
+
+func searchIndexBuildCmdFunc(cmd *cobra.Command, path string, flags *searchIndexBuildCmdFlags) error {
+	ref, err := flake.ParseRef(flags.nixpkgs)
+	if err != nil {
+		return err
+	}
+	var packages []byte
+	if flags.from != "" {
+		packages, err = os.ReadFile(flags.from)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	} else {
+		ux.Finfof(cmd.ErrOrStderr(), "Listing the packages in %s. This may take a few minutes.\n", ref)
+	}
+	entries, err := searcher.IndexNixpkgs(cmd.Context(), ref, packages)
+	if err != nil {
+		return err
+	}
+
+	index, err := searcher.ReadIndex(path)
+	if err != nil {
+		return err
+	}
+	index.Add(entries...)
+	if err := index.WriteFile(path); err != nil {
+		return err
+	}
+	ux.Fsuccessf(cmd.ErrOrStderr(), "Indexed %d packages from %s in %s\n", len(entries), ref, path)
+	return nil
+}
+
+func searchIndexServeCmd() *cobra.Command {
+	flags := &searchIndexServeCmdFlags{}
+	command := &cobra.Command{
+		Use:   "serve <index.json>",
+		Short: "Serve a search index with the devbox search service API",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return searchIndexServeCmdFunc(cmd, args[0], flags)
+		},
+	}
+	command.Flags().StringVar(
+		&flags.addr, "addr", "localhost:8484",
+		"the address to listen on")
+	return command
+}
+
+func searchIndexServeCmdFunc(cmd *cobra.Command, path string, flags *searchIndexServeCmdFlags) error {
+	index, err := searcher.ReadIndex(path)
+	if err != nil {
+		return err
+	}
+	if len(index.Packages) == 0 {
+		return errors.Errorf("search index %s is empty or doesn't exist", path)
+	}
+	listener, err := net.Listen("tcp", flags.addr)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	url := "http://" + listener.Addr().String()
+	ux.Finfof(cmd.ErrOrStderr(), "Serving %d packages from %s at %s\n", len(index.Packages), path, url)
+	fmt.Fprintf(cmd.ErrOrStderr(), "Use it with: export %s=%s\n", envir.DevboxSearchHost, url)
+
+	server := &http.Server{Handler: searcher.NewHandler(searcher.IndexBackend(path))}
+	go func() {
+		<-cmd.Context().Done()
+		_ = server.Close()
+	}()
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return errors.WithStack(err)
+	}
+	return nil
+}
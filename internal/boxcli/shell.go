@@ -23,6 +23,7 @@ type shellCmdFlags struct {
 	printEnv     bool
 	pure         bool
 	recomputeEnv bool
+	groups       []string
 }
 
 // shellFlagDefaults are the flag default values that differ
@@ -56,6 +57,8 @@ func shellCmd(defaults shellFlagDefaults) *cobra.Command {
 	)
 	_ = command.Flags().MarkHidden("omit-nix-env")
 	command.Flags().BoolVar(&flags.recomputeEnv, "recompute", true, "recompute environment if needed")
+	command.Flags().StringSliceVar(
+		&flags.groups, "with", nil, "optional package groups from devbox.json to install in the shell")
 
 	flags.config.register(command)
 	flags.envFlag.register(command)
@@ -74,6 +77,7 @@ func runShellCmd(cmd *cobra.Command, flags shellCmdFlags) error {
 		Dir:         flags.config.path,
 		Env:         env,
 		Environment: flags.config.environment,
+		Groups:      flags.groups,
 		Stderr:      cmd.ErrOrStderr(),
 	})
 	if err != nil {
@@ -5,9 +5,11 @@
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 	"go.jetify.com/devbox/internal/devbox"
 	"go.jetify.com/devbox/internal/devbox/devopt"
@@ -152,6 +154,9 @@ func shellEnvFunc(
 		NoRefreshAlias: flags.noRefreshAlias,
 		RunHooks:       flags.runInitHook,
 		ShellFormat:    shellFormat,
+		// Printing to a terminal (rather than to eval) means the
+		// user is looking at the output, so hide secrets.
+		RedactSecrets: isTerminal(cmd.OutOrStdout()),
 	})
 	if err != nil {
 		return "", err
@@ -159,3 +164,8 @@ func shellEnvFunc(
 
 	return envStr, nil
 }
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && isatty.IsTerminal(f.Fd())
+}
@@ -4,6 +4,9 @@
 package boxcli
 
 import (
+	"fmt"
+
+	"github.com/AlecAivazis/survey/v2"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
@@ -11,6 +14,8 @@
 	"go.jetify.com/devbox/internal/boxcli/usererr"
 	"go.jetify.com/devbox/internal/devbox"
 	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/lock"
+	"go.jetify.com/devbox/internal/ux"
 )
 
 type updateCmdFlags struct {
@@ -18,6 +23,36 @@ type updateCmdFlags struct {
 	sync        bool
 	allProjects bool
 	noInstall   bool
+	dryRun      bool
+	interactive bool
+	consolidate bool
+	asOf        string
+	patch       bool
+	minor       bool
+	major       bool
+}
+
+// maxBump returns the semver bump that the --patch, --minor and --major flags
+// allow.
+func (f *updateCmdFlags) maxBump() devopt.SemverBump {
+	switch {
+	case f.patch:
+		return devopt.BumpPatch
+	case f.minor:
+		return devopt.BumpMinor
+	case f.major:
+		return devopt.BumpMajor
+	}
+	return ""
+}
+
+// relocksIncludes reports whether the update re-locks remote includes when
+// the project is opened. Updating everything does, but patch and minor
+// updates only bump packages. Interactive updates only re-lock the includes
+// that are picked, and dry runs don't change anything.
+func (f *updateCmdFlags) relocksIncludes(args []string) bool {
+	limited := f.maxBump() == devopt.BumpPatch || f.maxBump() == devopt.BumpMinor
+	return len(args) == 0 && !limited && !f.interactive && !f.consolidate && !f.dryRun
 }
 
 func updateCmd() *cobra.Command {
@@ -31,7 +66,7 @@ func updateCmd() *cobra.Command {
 			"Legacy non-versioned packages will be converted to @latest versioned " +
 			"packages resolved to their current version.",
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			if flags.noInstall {
+			if flags.noInstall || flags.dryRun {
 				return nil
 			}
 			return ensureNixInstalled(cmd, args)
@@ -61,6 +96,63 @@ func updateCmd() *cobra.Command {
 		false,
 		"update lockfile but don't install anything",
 	)
+	command.Flags().BoolVar(
+		&flags.dryRun,
+		"dry-run",
+		false,
+		"print the updates that would be made to devbox.lock without changing it",
+	)
+	command.Flags().BoolVarP(
+		&flags.interactive,
+		"interactive",
+		"i",
+		false,
+		"choose which packages, flake inputs and https plugins to update before changing devbox.lock. "+
+			"GitHub and builtin plugins aren't listed because devbox.lock doesn't pin them",
+	)
+	command.Flags().BoolVar(
+		&flags.consolidate,
+		"consolidate",
+		false,
+		"re-resolve packages to as few nixpkgs revisions as their version constraints allow",
+	)
+	command.Flags().StringVar(
+		&flags.asOf,
+		"as-of",
+		"",
+		"update packages to their newest version published before this date (YYYY-MM-DD), "+
+			"even if it's older than the locked version",
+	)
+	command.Flags().BoolVar(
+		&flags.patch,
+		"patch",
+		false,
+		"only update packages to newer patch versions of their locked version",
+	)
+	command.Flags().BoolVar(
+		&flags.minor,
+		"minor",
+		false,
+		"only update packages to newer minor or patch versions of their locked version",
+	)
+	command.Flags().BoolVar(
+		&flags.major,
+		"major",
+		false,
+		"allow updates to new major versions (the default)",
+	)
+	command.MarkFlagsMutuallyExclusive("patch", "minor", "major")
+	command.MarkFlagsMutuallyExclusive("dry-run", "sync-lock")
+	command.MarkFlagsMutuallyExclusive("dry-run", "all-projects")
+	command.MarkFlagsMutuallyExclusive("interactive", "dry-run")
+	command.MarkFlagsMutuallyExclusive("interactive", "sync-lock")
+	command.MarkFlagsMutuallyExclusive("interactive", "all-projects")
+	for _, flag := range []string{"interactive", "sync-lock", "all-projects", "patch", "minor", "major", "as-of"} {
+		command.MarkFlagsMutuallyExclusive("consolidate", flag)
+	}
+	for _, flag := range []string{"sync-lock", "patch", "minor", "major"} {
+		command.MarkFlagsMutuallyExclusive("as-of", flag)
+	}
 	return command
 }
 
@@ -68,9 +160,16 @@ func updateCmdFunc(cmd *cobra.Command, args []string, flags *updateCmdFlags) err
 	if len(args) > 0 && flags.sync {
 		return usererr.New("cannot specify both a package and --sync")
 	}
+	if len(args) > 0 && flags.consolidate {
+		return usererr.New("cannot specify both a package and --consolidate")
+	}
+	asOf, err := parseAsOf(flags.asOf)
+	if err != nil {
+		return err
+	}
 
 	if flags.allProjects {
-		return updateAllProjects(cmd, args)
+		return updateAllProjects(cmd, args, flags, asOf)
 	}
 
 	if flags.sync {
@@ -78,23 +177,87 @@ func updateCmdFunc(cmd *cobra.Command, args []string, flags *updateCmdFlags) err
 	}
 
 	box, err := devbox.Open(&devopt.Opts{
-		Dir:         flags.config.path,
-		Environment: flags.config.environment,
-		Stderr:      cmd.ErrOrStderr(),
+		Dir:            flags.config.path,
+		Environment:    flags.config.environment,
+		RelockIncludes: flags.relocksIncludes(args),
+		Stderr:         cmd.ErrOrStderr(),
 	})
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	return box.Update(cmd.Context(), devopt.UpdateOpts{
+	opts := devopt.UpdateOpts{
 		Pkgs:      args,
 		NoInstall: flags.noInstall,
-	})
+		MaxBump:   flags.maxBump(),
+		AsOf:      asOf,
+	}
+	if flags.consolidate {
+		report, err := box.Consolidate(cmd.Context(), devopt.ConsolidateOpts{
+			DryRun:    flags.dryRun,
+			NoInstall: flags.noInstall,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(cmd.OutOrStdout(), report.Text())
+		return nil
+	}
+	if flags.dryRun {
+		plan, err := box.PlanUpdate(cmd.Context(), opts)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(cmd.OutOrStdout(), plan.Text())
+		return nil
+	}
+	if flags.interactive {
+		return updateInteractive(cmd, box, opts)
+	}
+	return box.Update(cmd.Context(), opts)
+}
+
+// updateInteractive shows a checklist of the available updates and applies
+// the ones that the user picks.
+func updateInteractive(cmd *cobra.Command, box *devbox.Devbox, opts devopt.UpdateOpts) error {
+	candidates, err := box.UpdateCandidates(cmd.Context(), opts)
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		ux.Fsuccessf(cmd.ErrOrStderr(), "Everything is up-to-date\n")
+		return nil
+	}
+
+	options := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		options[i] = candidate.String()
+	}
+	prompt := &survey.MultiSelect{
+		Message:  "Select the updates to apply:",
+		Options:  options,
+		PageSize: 15,
+	}
+	var picked []int
+	if err := survey.AskOne(prompt, &picked); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(picked) == 0 {
+		ux.Finfof(cmd.ErrOrStderr(), "No updates selected, devbox.lock was not changed\n")
+		return nil
+	}
+
+	updates := make([]devbox.UpdateCandidate, len(picked))
+	for i, index := range picked {
+		updates[i] = candidates[index]
+	}
+	return box.ApplyUpdates(cmd.Context(), updates, opts)
 }
 
-func updateAllProjects(cmd *cobra.Command, args []string) error {
+func updateAllProjects(cmd *cobra.Command, args []string, flags *updateCmdFlags, asOf *lock.NixpkgsAsOf) error {
 	boxes, err := multi.Open(&devopt.Opts{
-		Stderr: cmd.ErrOrStderr(),
+		RelockIncludes: flags.relocksIncludes(args),
+		Stderr:         cmd.ErrOrStderr(),
 	})
 	if err != nil {
 		return errors.WithStack(err)
@@ -103,6 +266,8 @@ func updateAllProjects(cmd *cobra.Command, args []string) error {
 		if err := box.Update(cmd.Context(), devopt.UpdateOpts{
 			Pkgs:                  args,
 			IgnoreMissingPackages: true,
+			MaxBump:               flags.maxBump(),
+			AsOf:                  asOf,
 		}); err != nil {
 			return err
 		}
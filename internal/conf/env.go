@@ -5,26 +5,182 @@
 
 import (
 	"os"
+	"slices"
+	"strings"
+
+	"go.jetify.com/devbox/internal/boxcli/usererr"
 )
 
-func OSExpandEnvMap(env, existingEnv map[string]string, projectDir string) map[string]string {
-	mapperfunc := func(value string) string {
-		// Special variables that should return correct value
-		switch value {
-		case "PWD":
-			return projectDir
+// OSExpandEnvMap expands variable references in the values of env. It supports
+// the following forms:
+//
+//   - $NAME and ${NAME} expand to the value of NAME.
+//   - ${NAME:-default} expands to default if NAME is unset or empty. The
+//     default can contain other references.
+//   - ${env:NAME} expands to NAME from the host environment (the environment
+//     devbox itself was started with), ignoring env and existingEnv.
+//
+// A NAME that is a key of env expands to that key's expanded value, so keys
+// can reference each other regardless of their order. A key that references
+// itself, as in "PATH": "$PATH:/foo", expands the reference with existingEnv
+// instead. Other names expand to their value in existingEnv, except for PWD,
+// which is always projectDir. References that form a cycle are an error.
+func OSExpandEnvMap(env, existingEnv map[string]string, projectDir string) (map[string]string, error) {
+	e := &envExpander{
+		env:         env,
+		existingEnv: existingEnv,
+		projectDir:  projectDir,
+		resolved:    make(map[string]string, len(env)),
+	}
+	res := make(map[string]string, len(env))
+	for k := range env {
+		v, err := e.resolve(k)
+		if err != nil {
+			return nil, err
 		}
+		res[k] = v
+	}
+	return res, nil
+}
+
+type envExpander struct {
+	env         map[string]string
+	existingEnv map[string]string
+	projectDir  string
 
-		// in case existingEnv is nil
-		if existingEnv == nil {
-			return ""
+	// resolved caches the expanded values of env keys.
+	resolved map[string]string
+
+	// visiting is the chain of keys currently being expanded. It's used to
+	// detect cycles.
+	visiting []string
+}
+
+func (e *envExpander) resolve(key string) (string, error) {
+	if v, ok := e.resolved[key]; ok {
+		return v, nil
+	}
+	for i, k := range e.visiting {
+		if k == key {
+			cycle := append(slices.Clone(e.visiting[i:]), key)
+			return "", usererr.New(
+				"the env variables in devbox.json reference each other in a cycle: %s. "+
+					"Set one of them to a value that doesn't reference the others.",
+				strings.Join(cycle, " -> "),
+			)
 		}
-		return existingEnv[value]
 	}
 
-	res := map[string]string{}
-	for k, v := range env {
-		res[k] = os.Expand(v, mapperfunc)
+	e.visiting = append(e.visiting, key)
+	v, err := e.expand(e.env[key], key)
+	e.visiting = e.visiting[:len(e.visiting)-1]
+	if err != nil {
+		return "", err
 	}
-	return res
+	e.resolved[key] = v
+	return v, nil
+}
+
+// expand expands the references in s, which is the value (or part of the
+// value) of the env key self.
+func (e *envExpander) expand(s, self string) (string, error) {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			buf.WriteByte(s[i])
+			continue
+		}
+		name, width := shellName(s[i+1:])
+		if name == "" {
+			if width == 0 {
+				// A $ that isn't followed by a name is left as-is.
+				buf.WriteByte(s[i])
+			}
+			// Otherwise the syntax is invalid, so drop it like
+			// os.Expand does.
+			i += width
+			continue
+		}
+		v, err := e.lookup(name, self)
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(v)
+		i += width
+	}
+	return buf.String(), nil
+}
+
+// lookup returns the value of a reference, which is the text between "${" and
+// "}" or after "$".
+func (e *envExpander) lookup(ref, self string) (string, error) {
+	name, def, hasDefault := strings.Cut(ref, ":-")
+
+	var v string
+	switch {
+	case strings.HasPrefix(name, "env:"):
+		v = os.Getenv(strings.TrimPrefix(name, "env:"))
+	case name == "PWD":
+		v = e.projectDir
+	default:
+		if _, ok := e.env[name]; ok && name != self {
+			var err error
+			v, err = e.resolve(name)
+			if err != nil {
+				return "", err
+			}
+		} else {
+			v = e.existingEnv[name]
+		}
+	}
+
+	if v == "" && hasDefault {
+		return e.expand(def, self)
+	}
+	return v, nil
+}
+
+// shellName returns the reference at the start of s, which follows a "$", and
+// the number of bytes it takes up. It's like os.Expand's parsing, except that
+// braces can nest so that defaults can contain references.
+func shellName(s string) (string, int) {
+	if s[0] == '{' {
+		depth := 0
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '{':
+				depth++
+			case '}':
+				if depth > 0 {
+					depth--
+					continue
+				}
+				if i == 1 {
+					return "", 2 // Bad syntax; eat "${}"
+				}
+				return s[1:i], i + 1
+			}
+		}
+		return "", 1 // Bad syntax; eat "${"
+	}
+	if isShellSpecialVar(s[0]) {
+		return s[0:1], 1
+	}
+	i := 0
+	for i < len(s) && isAlphaNum(s[i]) {
+		i++
+	}
+	return s[:i], i
+}
+
+func isShellSpecialVar(c uint8) bool {
+	switch c {
+	case '*', '#', '$', '@', '!', '?', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return true
+	}
+	return false
+}
+
+func isAlphaNum(c uint8) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
 }
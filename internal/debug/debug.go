@@ -14,15 +14,31 @@
 
 	"github.com/getsentry/sentry-go"
 	"github.com/pkg/errors"
+	"go.jetify.com/devbox/internal/redact"
 )
 
 const DevboxDebug = "DEVBOX_DEBUG"
 
 var (
 	level = slog.LevelVar{}
-	opts  = slog.HandlerOptions{AddSource: true, Level: &level}
+	opts  = slog.HandlerOptions{AddSource: true, Level: &level, ReplaceAttr: redactSecrets}
 )
 
+// redactSecrets removes the values that were marked as secret, such as the
+// output of env_from commands, from log messages and attributes.
+func redactSecrets(_ []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(redact.Secrets(a.Value.String()))
+	case slog.KindAny:
+		s := fmt.Sprint(a.Value.Any())
+		if redacted := redact.Secrets(s); redacted != s {
+			a.Value = slog.StringValue(redacted)
+		}
+	}
+	return a
+}
+
 func init() {
 	enabled, _ := strconv.ParseBool(os.Getenv(DevboxDebug))
 	if enabled {
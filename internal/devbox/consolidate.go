@@ -0,0 +1,432 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"runtime/trace"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+
+	"go.jetify.com/devbox/internal/boxcli/usererr"
+	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/devpkg"
+	"go.jetify.com/devbox/internal/envir"
+	"go.jetify.com/devbox/internal/lock"
+	"go.jetify.com/devbox/internal/nix"
+	"go.jetify.com/devbox/internal/searcher"
+	"go.jetify.com/devbox/internal/ux"
+	"go.jetify.com/devbox/nix/flake"
+)
+
+// ConsolidateReport is the result of Consolidate.
+type ConsolidateReport struct {
+	*LockDiff
+
+	// RevisionsBefore and RevisionsAfter are the number of nixpkgs
+	// revisions that packages and the stdenv are resolved to. Each revision
+	// is a separate input of the generated flake.
+	RevisionsBefore int `json:"revisions_before"`
+	RevisionsAfter  int `json:"revisions_after"`
+
+	// ClosureBefore and ClosureAfter are the combined closures of the
+	// locked outputs for the current system. They're nil if the binary
+	// cache couldn't be queried for them.
+	ClosureBefore *nix.Closure `json:"closure_before,omitempty"`
+	ClosureAfter  *nix.Closure `json:"closure_after,omitempty"`
+}
+
+// Text returns the report as plain text.
+func (r *ConsolidateReport) Text() string {
+	buf := &strings.Builder{}
+	buf.WriteString(r.LockDiff.Text())
+	fmt.Fprintf(buf, "\nnixpkgs inputs: %d -> %d\n", r.RevisionsBefore, r.RevisionsAfter)
+	if r.ClosureBefore != nil && r.ClosureAfter != nil {
+		fmt.Fprintf(buf, "Closure: %d paths (%s) -> %d paths (%s)\n",
+			r.ClosureBefore.Paths, formatNARSize(r.ClosureBefore.NARSize),
+			r.ClosureAfter.Paths, formatNARSize(r.ClosureAfter.NARSize))
+	}
+	return buf.String()
+}
+
+// Consolidate re-resolves versioned packages so that they use as few nixpkgs
+// revisions as possible. The revisions a package can move to are the ones
+// that other packages and the stdenv already use, and the newest revision of
+// each version in the search index. A package only moves to a revision that
+// has a version satisfying its version constraint in devbox.json, and that
+// isn't older than its locked version. Packages that don't share a revision
+// with others keep their locked version.
+func (d *Devbox) Consolidate(ctx context.Context, opts devopt.ConsolidateOpts) (*ConsolidateReport, error) {
+	if envir.IsOffline() {
+		return nil, usererr.New(updateOfflineMessage)
+	}
+	defer trace.StartRegion(ctx, "devboxConsolidate").End()
+
+	revs := d.lockedRevisions()
+	versionAt := nixpkgsVersionAt(ctx)
+	candidates := map[string][]revisionCandidate{}
+	for _, pkg := range d.AllPackages() {
+		name, constraint, ok := searcher.ParseVersionedPackage(pkg.Raw)
+		locked := d.lockfile.Packages[pkg.Raw]
+		if !ok || !pkg.IsDevboxPackage || pkg.IsRunX() || locked == nil {
+			continue
+		}
+		current := revisionCandidate{
+			version:      locked.Version,
+			rev:          nixpkgsRev(locked.Resolved),
+			lastModified: locked.LastModified,
+		}
+		if current.rev == "" {
+			continue
+		}
+		// The search results only have the newest revision of each
+		// version, so they rarely list the revisions that other packages
+		// use. Those are checked directly, and the search results are
+		// extra candidates.
+		atRevs, err := candidatesAtRevisions(locked, constraint, revs, versionAt)
+		if err != nil {
+			return nil, err
+		}
+		results, err := searcher.Client().Search(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		candidates[pkg.Raw] = slices.Concat(
+			[]revisionCandidate{current},
+			atRevs,
+			consolidationCandidates(results, name, constraint, locked.Version),
+		)
+	}
+
+	from, to := cloneLockfile(d.lockfile), cloneLockfile(d.lockfile)
+	for raw, chosen := range consolidateRevisions(candidates, d.Stdenv().Rev) {
+		locked := from.Packages[raw]
+		if chosen.rev == nixpkgsRev(locked.Resolved) {
+			continue
+		}
+		if chosen.attrPath != "" {
+			resolved, err := d.resolveAtRevision(ctx, locked, chosen)
+			if err != nil {
+				return nil, err
+			}
+			to.Packages[raw] = resolved
+			continue
+		}
+		name, _, _ := searcher.ParseVersionedPackage(raw)
+		resolved, err := d.lockfile.FetchResolvedPackage(name+"@"+chosen.version, true /*refresh*/)
+		if err != nil {
+			return nil, err
+		}
+		// The search service resolves an exact version to the newest
+		// revision that has it, which is where the candidate came from.
+		// Keep the locked version if that's no longer the case.
+		if nixpkgsRev(resolved.Resolved) != chosen.rev {
+			ux.Fwarningf(d.stderr, "Not consolidating %s: %s@%s no longer resolves to nixpkgs %s\n",
+				raw, name, chosen.version, chosen.rev)
+			continue
+		}
+		resolved.AllowInsecure = locked.AllowInsecure
+		to.Packages[raw] = resolved
+	}
+
+	report := &ConsolidateReport{
+		LockDiff:        DiffLockfiles(from, to),
+		RevisionsBefore: d.countRevisions(from),
+		RevisionsAfter:  d.countRevisions(to),
+	}
+	before, errBefore := lockedClosure(ctx, from)
+	after, errAfter := lockedClosure(ctx, to)
+	if err := cmp.Or(errBefore, errAfter); err != nil {
+		ux.Fwarningf(d.stderr, "Could not get closure sizes from %s: %s\n", devpkg.BinaryCache, err)
+	} else {
+		report.ClosureBefore, report.ClosureAfter = &before, &after
+	}
+
+	if opts.DryRun || len(report.Changes) == 0 {
+		return report, nil
+	}
+	for _, change := range report.Changes {
+		d.lockfile.Packages[change.Package] = to.Packages[change.Package]
+	}
+	mode := update
+	if opts.NoInstall {
+		mode = noInstall
+	}
+	// The report replaces the messages about each package.
+	stderr := d.stderr
+	d.stderr = io.Discard
+	defer func() { d.stderr = stderr }()
+	if err := d.ensureStateIsUpToDate(ctx, mode); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// revisionCandidate is a version of a package and the nixpkgs revision that
+// has it.
+type revisionCandidate struct {
+	version      string
+	rev          string
+	lastModified string
+
+	// attrPath is set if the version was found by evaluating the package
+	// at a revision instead of by the search service.
+	attrPath string
+}
+
+// lockedRevisions returns the nixpkgs revisions that the locked packages and
+// the stdenv are resolved to, mapped to their last modified time.
+func (d *Devbox) lockedRevisions() map[string]string {
+	revs := map[string]string{}
+	if rev := d.Stdenv().Rev; rev != "" {
+		revs[rev] = ""
+	}
+	for _, pkg := range d.lockfile.Packages {
+		if rev := nixpkgsRev(pkg.Resolved); rev != "" {
+			revs[rev] = max(revs[rev], pkg.LastModified)
+		}
+	}
+	return revs
+}
+
+// candidatesAtRevisions returns the versions of a locked package at the
+// revisions in revs that it can move to. versionAt returns the version of a
+// nixpkgs attribute path at a revision, or an empty string if the attribute
+// path doesn't exist there.
+func candidatesAtRevisions(
+	locked *lock.Package,
+	constraint string,
+	revs map[string]string,
+	versionAt func(rev, attrPath string) (string, error),
+) ([]revisionCandidate, error) {
+	installable, err := flake.ParseInstallable(locked.Resolved)
+	if err != nil || installable.AttrPath == "" {
+		return nil, nil
+	}
+	var candidates []revisionCandidate
+	for _, rev := range slices.Sorted(maps.Keys(revs)) {
+		if rev == installable.Ref.Rev {
+			continue
+		}
+		version, err := versionAt(rev, installable.AttrPath)
+		if err != nil {
+			return nil, err
+		}
+		if version == "" || !canConsolidateTo(constraint, locked.Version, version) {
+			continue
+		}
+		candidates = append(candidates, revisionCandidate{
+			version:      version,
+			rev:          rev,
+			lastModified: revs[rev],
+			attrPath:     installable.AttrPath,
+		})
+	}
+	return candidates, nil
+}
+
+// nixpkgsVersionAt returns a function that evaluates the version of a nixpkgs
+// attribute path at a revision. It returns an empty version if the attribute
+// path can't be evaluated at that revision.
+func nixpkgsVersionAt(ctx context.Context) func(rev, attrPath string) (string, error) {
+	versions := map[string]string{}
+	return func(rev, attrPath string) (string, error) {
+		ref := flake.Ref{Type: flake.TypeGitHub, Owner: "NixOS", Repo: "nixpkgs", Rev: rev}
+		installable := flake.Installable{Ref: ref, AttrPath: attrPath + ".version"}
+		if version, ok := versions[installable.String()]; ok {
+			return version, nil
+		}
+		out, err := nix.Command("eval", "--raw", installable).Output(ctx)
+		if err != nil {
+			slog.Debug("can't evaluate package version", "installable", installable, "err", err)
+		}
+		versions[installable.String()] = string(out)
+		return string(out), nil
+	}
+}
+
+// resolveAtRevision returns the lock entry of a locked package moved to a
+// candidate that was found by evaluating it at a revision. Like devbox lock,
+// it evaluates the outputs of the systems that the package was locked for.
+func (d *Devbox) resolveAtRevision(
+	ctx context.Context,
+	locked *lock.Package,
+	chosen revisionCandidate,
+) (*lock.Package, error) {
+	ref := flake.Ref{Type: flake.TypeGitHub, Owner: "NixOS", Repo: "nixpkgs", Rev: chosen.rev}
+	installable := flake.Installable{Ref: ref, AttrPath: chosen.attrPath}
+	resolved := &lock.Package{
+		AllowInsecure: locked.AllowInsecure,
+		LastModified:  chosen.lastModified,
+		Resolved:      installable.String(),
+		Source:        locked.Source,
+		Version:       chosen.version,
+		Systems:       map[string]*lock.SystemInfo{},
+	}
+	for system := range locked.Systems {
+		evaluated, err := nix.OutputsForSystem(ctx, installable.String(), system, locked.AllowInsecure)
+		if err != nil {
+			return nil, fmt.Errorf("evaluate %s for %s: %w", installable, system, err)
+		}
+		info := &lock.SystemInfo{}
+		for _, out := range evaluated {
+			info.Outputs = append(info.Outputs, lock.Output{Name: out.Name, Path: out.Path, Default: out.Default})
+		}
+		resolved.Systems[system] = info
+	}
+	return resolved, nil
+}
+
+// consolidationCandidates returns the versions of a package in search results
+// that a package locked to lockedVersion can move to: versions that satisfy
+// its version constraint and aren't older than the locked version.
+func consolidationCandidates(
+	results *searcher.SearchResults,
+	name, constraint, lockedVersion string,
+) []revisionCandidate {
+	var candidates []revisionCandidate
+	for _, result := range results.Packages {
+		if result.Name != name {
+			continue
+		}
+		for _, v := range result.Versions {
+			if v.CommitHash == "" || !canConsolidateTo(constraint, lockedVersion, v.Version) {
+				continue
+			}
+			candidates = append(candidates, revisionCandidate{
+				version:      v.Version,
+				rev:          v.CommitHash,
+				lastModified: time.Unix(int64(v.LastUpdated), 0).UTC().Format(time.RFC3339),
+			})
+		}
+	}
+	return candidates
+}
+
+// canConsolidateTo reports whether a package with a version constraint that
+// is locked to one version can be resolved to another version instead.
+func canConsolidateTo(constraint, locked, version string) bool {
+	if version == locked {
+		return true
+	}
+	if constraint != "latest" && !isVersionPrefix(constraint, version) {
+		return false
+	}
+	// Only semantic versions can be ordered.
+	v, l := "v"+version, "v"+locked
+	return semver.IsValid(v) && semver.IsValid(l) && semver.Compare(v, l) > 0
+}
+
+// consolidateRevisions picks one candidate for each package so that the
+// packages use as few revisions as possible. It's a greedy set cover: the
+// revision that the most packages can use is picked first, preferring the
+// preferred revision and then newer revisions on ties. Each package gets the
+// newest of its versions in the picked revision.
+func consolidateRevisions(
+	candidates map[string][]revisionCandidate,
+	preferred string,
+) map[string]revisionCandidate {
+	chosen := map[string]revisionCandidate{}
+	for len(chosen) < len(candidates) {
+		count := map[string]int{}
+		newest := map[string]string{}
+		for raw, cs := range candidates {
+			if _, ok := chosen[raw]; ok {
+				continue
+			}
+			seen := map[string]bool{}
+			for _, c := range cs {
+				if !seen[c.rev] {
+					seen[c.rev] = true
+					count[c.rev]++
+				}
+				newest[c.rev] = max(newest[c.rev], c.lastModified)
+			}
+		}
+		revs := slices.Collect(maps.Keys(count))
+		best := slices.MaxFunc(revs, func(a, b string) int {
+			return cmp.Or(
+				cmp.Compare(count[a], count[b]),
+				compareBool(a == preferred, b == preferred),
+				cmp.Compare(newest[a], newest[b]),
+				strings.Compare(a, b),
+			)
+		})
+		for raw, cs := range candidates {
+			if _, ok := chosen[raw]; ok {
+				continue
+			}
+			for _, c := range cs {
+				if c.rev == best && (chosen[raw].rev != best || searcher.CompareVersions(c.version, chosen[raw].version) > 0) {
+					chosen[raw] = c
+				}
+			}
+		}
+	}
+	return chosen
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// nixpkgsRev returns the nixpkgs commit that a locked package is resolved
+// to, or an empty string if it isn't resolved to a nixpkgs commit.
+func nixpkgsRev(resolved string) string {
+	installable, err := flake.ParseInstallable(resolved)
+	if err != nil || !installable.Ref.IsNixpkgs() {
+		return ""
+	}
+	return installable.Ref.Rev
+}
+
+// countRevisions returns the number of nixpkgs revisions that the packages
+// in lockfile and the stdenv are resolved to.
+func (d *Devbox) countRevisions(lockfile *lock.File) int {
+	revs := map[string]bool{d.Stdenv().Rev: true}
+	for _, pkg := range lockfile.Packages {
+		if rev := nixpkgsRev(pkg.Resolved); rev != "" {
+			revs[rev] = true
+		}
+	}
+	return len(revs)
+}
+
+// lockedClosure returns the combined closure of the outputs locked for the
+// current system, as reported by the binary cache.
+func lockedClosure(ctx context.Context, lockfile *lock.File) (nix.Closure, error) {
+	var paths []string
+	for _, pkg := range lockfile.Packages {
+		if info := pkg.Systems[nix.System()]; info != nil {
+			for _, output := range info.Outputs {
+				paths = append(paths, output.Path)
+			}
+		}
+	}
+	slices.Sort(paths)
+	return nix.ClosureOf(ctx, devpkg.BinaryCache, slices.Compact(paths))
+}
+
+// formatNARSize formats a size in bytes as MiB or GiB.
+func formatNARSize(size int64) string {
+	if size >= 1<<30 {
+		return fmt.Sprintf("%.1f GiB", float64(size)/(1<<30))
+	}
+	return fmt.Sprintf("%.1f MiB", float64(size)/(1<<20))
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"testing"
+
+	"go.jetify.com/devbox/internal/lock"
+	"go.jetify.com/devbox/internal/searcher"
+)
+
+func TestConsolidateRevisions(t *testing.T) {
+	candidates := map[string][]revisionCandidate{
+		"go@latest": {
+			{version: "1.22.5", rev: "aaa", lastModified: "2024-01-01T00:00:00Z"},
+			{version: "1.22.7", rev: "ccc", lastModified: "2024-03-01T00:00:00Z"},
+			{version: "1.22.6", rev: "ccc", lastModified: "2024-03-01T00:00:00Z"},
+		},
+		"python@3.12": {
+			{version: "3.12.1", rev: "bbb", lastModified: "2024-02-01T00:00:00Z"},
+			{version: "3.12.4", rev: "ccc", lastModified: "2024-03-01T00:00:00Z"},
+		},
+		"nodejs@20": {
+			{version: "20.1.0", rev: "aaa", lastModified: "2024-01-01T00:00:00Z"},
+		},
+		"jq@1.7": {
+			{version: "1.7.1", rev: "ddd", lastModified: "2024-02-01T00:00:00Z"},
+		},
+	}
+	want := map[string]string{
+		"go@latest":   "1.22.7 ccc",
+		"python@3.12": "3.12.4 ccc",
+		"nodejs@20":   "20.1.0 aaa",
+		"jq@1.7":      "1.7.1 ddd",
+	}
+	got := consolidateRevisions(candidates, "")
+	for pkg, w := range want {
+		if g := got[pkg].version + " " + got[pkg].rev; g != w {
+			t.Errorf("consolidateRevisions picked %s for %s, want %s", g, pkg, w)
+		}
+	}
+
+	// Ties go to the preferred revision.
+	tied := map[string][]revisionCandidate{
+		"go@latest": {
+			{version: "1.22.5", rev: "aaa", lastModified: "2024-01-01T00:00:00Z"},
+			{version: "1.22.7", rev: "bbb", lastModified: "2024-03-01T00:00:00Z"},
+		},
+	}
+	if got := consolidateRevisions(tied, "aaa")["go@latest"].rev; got != "aaa" {
+		t.Errorf("consolidateRevisions with preferred revision picked %s, want aaa", got)
+	}
+	if got := consolidateRevisions(tied, "")["go@latest"].rev; got != "bbb" {
+		t.Errorf("consolidateRevisions without preferred revision picked %s, want bbb", got)
+	}
+}
+
+func TestCanConsolidateTo(t *testing.T) {
+	for _, test := range []struct {
+		constraint, locked, version string
+		want                        bool
+	}{
+		{"latest", "1.22.5", "1.22.5", true},
+		{"latest", "1.22.5", "1.23.0", true},
+		{"latest", "1.22.5", "1.21.0", false},
+		{"1.22", "1.22.5", "1.22.7", true},
+		{"1.22", "1.22.5", "1.23.0", false},
+		{"3", "3.11.2", "3.12.4", true},
+		{"latest", "unstable-2024-01-01", "unstable-2024-02-01", false},
+	} {
+		if got := canConsolidateTo(test.constraint, test.locked, test.version); got != test.want {
+			t.Errorf("canConsolidateTo(%q, %q, %q) = %v, want %v",
+				test.constraint, test.locked, test.version, got, test.want)
+		}
+	}
+}
+
+func TestConsolidateAtLockedRevisions(t *testing.T) {
+	// The search service only lists the newest revision of each version, so
+	// it has none of the revisions below. Each package is checked at the
+	// revisions that the others are locked to. Versions are keyed by the
+	// first three characters of a revision.
+	locked := map[string]*lock.Package{
+		"go@1.22": {
+			Version:      "1.22.5",
+			Resolved:     "github:NixOS/nixpkgs/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa#go_1_22",
+			LastModified: "2024-01-01T00:00:00Z",
+		},
+		"python@3.12": {
+			Version:      "3.12.1",
+			Resolved:     "github:NixOS/nixpkgs/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb#python312",
+			LastModified: "2024-02-01T00:00:00Z",
+		},
+		"jq@1.6": {
+			Version:      "1.6",
+			Resolved:     "github:NixOS/nixpkgs/cccccccccccccccccccccccccccccccccccccccc#jq",
+			LastModified: "2024-03-01T00:00:00Z",
+		},
+	}
+	versions := map[string]string{
+		"aaa#python312": "3.11.8", // Doesn't satisfy the constraint.
+		"aaa#jq":        "1.7.1",  // Doesn't satisfy the constraint.
+		"bbb#go_1_22":   "1.22.6",
+		"bbb#jq":        "1.6",
+		"ccc#go_1_22":   "1.22.4", // Older than the locked version.
+	}
+	versionAt := func(rev, attrPath string) (string, error) {
+		return versions[rev[:3]+"#"+attrPath], nil
+	}
+	revs := map[string]string{}
+	for _, pkg := range locked {
+		revs[nixpkgsRev(pkg.Resolved)] = pkg.LastModified
+	}
+
+	candidates := map[string][]revisionCandidate{}
+	for raw, pkg := range locked {
+		_, constraint, _ := searcher.ParseVersionedPackage(raw)
+		atRevs, err := candidatesAtRevisions(pkg, constraint, revs, versionAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		current := revisionCandidate{version: pkg.Version, rev: nixpkgsRev(pkg.Resolved), lastModified: pkg.LastModified}
+		candidates[raw] = append([]revisionCandidate{current}, atRevs...)
+	}
+	want := map[string]string{
+		"go@1.22":     "1.22.6 bbb go_1_22",
+		"python@3.12": "3.12.1 bbb ",
+		"jq@1.6":      "1.6 bbb jq",
+	}
+	got := consolidateRevisions(candidates, "")
+	for pkg, w := range want {
+		if g := got[pkg].version + " " + got[pkg].rev[:3] + " " + got[pkg].attrPath; g != w {
+			t.Errorf("consolidateRevisions picked %q for %s, want %q", g, pkg, w)
+		}
+	}
+}
@@ -71,10 +71,20 @@ type Devbox struct {
 	// packagesBeingUpdated tracks which packages are being updated so that
 	// installNixPackagesToStore only refreshes those, not all packages.
 	packagesBeingUpdated []*devpkg.Package
+
+	// execEnvs caches the output of env_from commands by command, and
+	// execEnvSessionVars holds the env vars that let a shell session reuse
+	// it. See execEnvFrom.
+	execEnvs           map[string]map[string]string
+	execEnvSessionVars map[string]string
 }
 
 var legacyPackagesWarningHasBeenShown = false
 
+// configWarningsHaveBeenShown prevents schema warnings from being printed
+// every time a command opens the devbox project.
+var configWarningsHaveBeenShown = false
+
 func InitConfig(dir string) error {
 	_, err := devconfig.Init(dir)
 	return err
@@ -109,10 +119,15 @@ func Open(opts *devopt.Opts) (*Devbox, error) {
 		return nil, usererr.WithUserMessage(err, "Error loading devbox.json.")
 	}
 
-	environment, err := validateEnvironment(opts.Environment)
+	environment, err := validateEnvironment(cfg, opts.Environment)
 	if err != nil {
 		return nil, err
 	}
+	cfg.SelectEnvironment(environment)
+	if err := validateGroups(cfg, opts.Groups); err != nil {
+		return nil, err
+	}
+	cfg.SelectGroups(opts.Groups)
 
 	box := &Devbox{
 		cfg:                      cfg,
@@ -130,20 +145,38 @@ func Open(opts *devopt.Opts) (*Devbox, error) {
 		return nil, err
 	}
 
+	if opts.RelockIncludes {
+		// Drop the pinned hashes and cached content of remote includes so
+		// that LoadRecursive fetches and pins them again.
+		lock.UnpinIncludes()
+		if err := plugin.Update(); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := cfg.LoadRecursive(lock); err != nil {
 		return nil, err
 	}
 
+	if !opts.IgnoreWarnings && !configWarningsHaveBeenShown {
+		configWarningsHaveBeenShown = true
+		for _, d := range cfg.Diagnostics() {
+			ux.Fwarningf(box.stderr, "%s\n", d)
+		}
+	}
+
 	// if lockfile has any allow insecure, we need to set the env var to ensure
 	// all nix commands work.
-	if err := box.moveAllowInsecureFromLockfile(box.stderr, lock, cfg); err != nil {
-		ux.Fwarningf(
-			box.stderr,
-			"Failed to move allow_insecure from devbox.lock to devbox.json. An insecure package may "+
-				"not work until you invoke `devbox add <pkg> --allow-insecure=<packages>` again: %s\n",
-			err,
-		)
-		// continue on, since we do not want to block user.
+	if !opts.SkipMigrations {
+		if err := box.moveAllowInsecureFromLockfile(box.stderr, lock, cfg); err != nil {
+			ux.Fwarningf(
+				box.stderr,
+				"Failed to move allow_insecure from devbox.lock to devbox.json. An insecure package may "+
+					"not work until you invoke `devbox add <pkg> --allow-insecure=<packages>` again: %s\n",
+				err,
+			)
+			// continue on, since we do not want to block user.
+		}
 	}
 
 	box.pluginManager.ApplyOptions(
@@ -227,10 +260,17 @@ func (d *Devbox) Shell(ctx context.Context, envOpts devopt.EnvOptions) error {
 	ctx, task := trace.NewTask(ctx, "devboxShell")
 	defer task.End()
 
+	// Report missing variables before the slow work of installing packages.
+	if err := d.checkRequiredEnvBeforeInstall(); err != nil {
+		return err
+	}
 	envs, err := d.ensureStateIsUpToDateAndComputeEnv(ctx, envOpts)
 	if err != nil {
 		return err
 	}
+	if err := d.checkPluginRequiredEnv(envs); err != nil {
+		return err
+	}
 
 	fmt.Fprintln(d.stderr, "Starting a devbox shell...")
 
@@ -267,6 +307,17 @@ func (d *Devbox) RunScript(ctx context.Context, envOpts devopt.EnvOptions, cmdNa
 		return err
 	}
 
+	// Check the script's arguments before doing the slow work of computing
+	// the environment.
+	var argEnv map[string]string
+	if script, ok := d.cfg.Scripts()[cmdName]; ok {
+		var err error
+		argEnv, err = script.ParseArgs(cmdName, cmdArgs)
+		if err != nil {
+			return err
+		}
+	}
+
 	lock.SetIgnoreShellMismatch(true)
 
 	var env map[string]string
@@ -277,14 +328,27 @@ func (d *Devbox) RunScript(ctx context.Context, envOpts devopt.EnvOptions, cmdNa
 		// We set this to ensure that init-hooks do NOT re-run. They would have
 		// run when initializing the Devbox Environment in the current shell.
 		env[d.SkipInitHookEnvName()] = "true"
+		if err := checkRequiredEnv(d.cfg.EnvRequired(), env); err != nil {
+			return err
+		}
 	} else {
+		// Report missing variables before the slow work of installing
+		// packages.
+		if err := d.checkRequiredEnvBeforeInstall(); err != nil {
+			return err
+		}
 		var err error
 		env, err = d.ensureStateIsUpToDateAndComputeEnv(ctx, envOpts)
 		if err != nil {
 			return err
 		}
+		if err := d.checkPluginRequiredEnv(env); err != nil {
+			return err
+		}
 	}
 
+	maps.Copy(env, argEnv)
+
 	// Used to determine whether we're inside a shell (e.g. to prevent shell inception)
 	// This is temporary because StartServices() needs it but should be replaced with
 	// better alternative since devbox run and devbox shell are not the same.
@@ -349,18 +413,30 @@ func (d *Devbox) Install(ctx context.Context) error {
 	return d.ensureStateIsUpToDate(ctx, ensure)
 }
 
-func (d *Devbox) ListScripts() []string {
-	scripts := d.cfg.Scripts()
-	keys := make([]string, len(scripts))
-	i := 0
-	for k := range scripts {
-		keys[i] = k
-		i++
-	}
+// ScriptInfo describes a script for listing.
+type ScriptInfo struct {
+	Name string
 
-	slices.Sort(keys)
+	// Usage is the script's name followed by its arguments.
+	Usage       string
+	Description string
+}
 
-	return keys
+// ListScripts returns the scripts in the config, sorted by name.
+func (d *Devbox) ListScripts() []ScriptInfo {
+	scripts := d.cfg.Scripts()
+	infos := make([]ScriptInfo, 0, len(scripts))
+	for name, script := range scripts {
+		infos = append(infos, ScriptInfo{
+			Name:        name,
+			Usage:       script.Usage(name),
+			Description: script.Description,
+		})
+	}
+	slices.SortFunc(infos, func(a, b ScriptInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return infos
 }
 
 // EnvExports returns a string of the env-vars that would need to be applied
@@ -378,6 +454,10 @@ func (d *Devbox) EnvExports(ctx context.Context, opts devopt.EnvExportsOpts) (st
 		return "", err
 	}
 
+	if opts.RedactSecrets {
+		envs = d.redactEnv(envs)
+	}
+
 	// Use the appropriate export format based on shell type
 	var envStr string
 	if opts.ShellFormat == devopt.ShellFormatNushell {
@@ -455,7 +535,7 @@ func (d *Devbox) Info(ctx context.Context, pkg string, markdown bool) (string, e
 	if err != nil {
 		return "", err
 	}
-	return info + readme, nil
+	return redact.Secrets(info + readme), nil
 }
 
 // GenerateDevcontainer generates devcontainer.json and Dockerfile for vscode run-in-container
@@ -492,6 +572,7 @@ func (d *Devbox) GenerateDevcontainer(ctx context.Context, generateOpts devopt.G
 		IsDevcontainer: true,
 		Pkgs:           d.AllPackageNamesIncludingRemovedTriggerPackages(),
 		LocalFlakeDirs: d.getLocalFlakesDirs(),
+		ConfigName:     d.cfg.Root.FileName(),
 	}
 
 	// generate dockerfile
@@ -531,6 +612,7 @@ func (d *Devbox) GenerateDockerfile(ctx context.Context, generateOpts devopt.Gen
 		IsDevcontainer: false,
 		Pkgs:           d.AllPackageNamesIncludingRemovedTriggerPackages(),
 		LocalFlakeDirs: d.getLocalFlakesDirs(),
+		ConfigName:     d.cfg.Root.FileName(),
 	}
 
 	scripts := d.cfg.Scripts()
@@ -591,6 +673,54 @@ func (d *Devbox) GenerateEnvrcFile(ctx context.Context, opts devopt.EnvrcOpts) e
 	return nil
 }
 
+// GenerateGitattributes sets up the devbox.lock merge driver. It adds it to
+// .gitattributes and, if the project is in a git repository, configures git
+// to run `devbox lock merge`.
+func (d *Devbox) GenerateGitattributes(ctx context.Context) error {
+	ctx, task := trace.NewTask(ctx, "devboxGenerateGitattributes")
+	defer task.End()
+
+	updated, err := generate.UpdateGitattributes(ctx, d.projectDir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if updated {
+		ux.Fsuccessf(d.stderr, "added the devbox.lock merge driver to .gitattributes\n")
+	} else {
+		ux.Finfof(d.stderr, ".gitattributes already uses the devbox.lock merge driver\n")
+	}
+
+	// The merge driver command can only be set in the git config, which
+	// isn't committed, so every clone of the repository needs to set it.
+	driverKey := "merge." + generate.LockMergeDriver
+	if err := d.configureLockMergeDriver(ctx, driverKey); err != nil {
+		ux.Fwarningf(d.stderr, "could not configure git. To use the merge driver, run:\n\n"+
+			"  git config %s.name \"devbox.lock merge driver\"\n"+
+			"  git config %s.driver \"%s\"\n\n",
+			driverKey, driverKey, generate.LockMergeDriverCommand)
+		return nil
+	}
+	ux.Fsuccessf(d.stderr, "configured the %q git merge driver\n", generate.LockMergeDriver)
+	return nil
+}
+
+func (d *Devbox) configureLockMergeDriver(ctx context.Context, driverKey string) error {
+	if !cmdutil.Exists("git") {
+		return errors.New("git is not installed")
+	}
+	config := [][2]string{
+		{driverKey + ".name", "devbox.lock merge driver"},
+		{driverKey + ".driver", generate.LockMergeDriverCommand},
+	}
+	for _, kv := range config {
+		cmd := exec.CommandContext(ctx, "git", "-C", d.projectDir, "config", kv[0], kv[1])
+		if err := cmd.Run(); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
 // saveCfg writes the config file to the devbox directory.
 func (d *Devbox) saveCfg() error {
 	return d.cfg.Root.SaveTo(d.ProjectDir())
@@ -764,6 +894,7 @@ func (d *Devbox) computeEnv(
 	addEnvIfNotPreviouslySetByDevbox(env, configEnv)
 
 	markEnvsAsSetByDevbox(configEnv)
+	maps.Copy(env, d.execEnvSessionVars)
 
 	// devboxEnvPath starts with the initial PATH from print-dev-env, and is
 	// transformed to be the "PATH of the Devbox environment"
@@ -901,6 +1032,52 @@ func (d *Devbox) AllPackageNamesIncludingRemovedTriggerPackages() []string {
 	return result
 }
 
+// InactivePackageNames returns the names of packages that are declared by
+// environments other than the selected one, by groups that aren't selected, or
+// by devbox.json and excluded by the selected environment. They aren't
+// installed, but their lockfile entries are preserved.
+func (d *Devbox) InactivePackageNames() []string {
+	active := d.AllPackageNamesIncludingRemovedTriggerPackages()
+	result := []string{}
+	inactive := slices.Concat(
+		d.cfg.Root.TopLevelPackages(),
+		d.cfg.AllEnvironmentPackages(),
+		d.cfg.AllGroupPackages(),
+	)
+	for _, p := range inactive {
+		if !slices.Contains(active, p.VersionedName()) {
+			result = append(result, p.VersionedName())
+		}
+	}
+	return result
+}
+
+// IncludeLockfileKeys returns the lockfile keys of all plugins included by the
+// config, including plugins included by other plugins.
+func (d *Devbox) IncludeLockfileKeys() []string {
+	keys := []string{}
+	for _, include := range d.includedPlugins() {
+		keys = append(keys, include.LockfileKey())
+	}
+	return keys
+}
+
+// includedPlugins returns all plugins included by the config, including
+// plugins included by other plugins, environments and devbox.local.json.
+func (d *Devbox) includedPlugins() []plugin.Includable {
+	includes := []plugin.Includable{}
+	for _, pluginConfig := range d.cfg.IncludedPluginConfigs() {
+		includes = append(includes, pluginConfig.Source)
+	}
+	return includes
+}
+
+// LocalPackageNames returns the names of packages that are only declared in
+// devbox.local.json. Their lockfile entries are saved in devbox.local.lock.
+func (d *Devbox) LocalPackageNames() []string {
+	return d.cfg.LocalPackageNames()
+}
+
 func (d *Devbox) AllPackagesIncludingRemovedTriggerPackages() []*devpkg.Package {
 	packages := d.cfg.Packages(true /*includeRemovedTriggerPackages*/)
 	return devpkg.PackagesFromConfig(packages, d.lockfile)
@@ -994,43 +1171,38 @@ func (d *Devbox) checkOldEnvrc() error {
 // their value in the existing env variables. Note, this doesn't
 // allow env variables from outside the shell to be referenced so
 // no leaked variables are caused by this function.
+//
+// Values from env_from are used as is, because secrets can contain a $.
+// The env variables in Config can reference them.
 func (d *Devbox) configEnvs(
 	existingEnv map[string]string,
 ) (map[string]string, error) {
 	defer debug.FunctionTimer().End()
-	env := map[string]string{}
 	if d.cfg.IsJetifyCloudEnvFrom() {
 		ux.Fwarningf(
 			d.stderr,
 			"Ignoring env_from = %q. Jetify Cloud secrets are no longer "+
 				"supported by Devbox.\n",
-			d.cfg.Root.EnvFrom,
-		)
-	} else if d.cfg.Root.IsdotEnvEnabled() {
-		// if env_from points to a .env file, parse and add it
-		parsedEnvs, err := d.cfg.Root.ParseEnvsFromDotEnv()
-		if err != nil {
-			// it's fine to include the error ParseEnvsFromDotEnv here because
-			// the error message is relevant to the user
-			return nil, usererr.New(
-				"failed parsing %s file. Error: %v",
-				d.cfg.Root.EnvFrom,
-				err,
-			)
-		}
-		for k, v := range parsedEnvs {
-			env[k] = v
-		}
-	} else if d.cfg.Root.EnvFrom != "" {
-		return nil, usererr.New(
-			"unknown env_from value: %s. It must be a path to a file ending in \".env\"",
-			d.cfg.Root.EnvFrom,
+			d.cfg.EnvFrom(),
 		)
 	}
-	for k, v := range d.cfg.Env() {
-		env[k] = v
+	// Load the files and run the commands listed in env_from. Jetify Cloud
+	// sources are skipped.
+	parsedEnvs, err := d.cfg.ParseEnvFrom(d.execEnvFrom)
+	if err != nil {
+		return nil, err
 	}
-	return conf.OSExpandEnvMap(env, existingEnv, d.ProjectDir()), nil
+	referenceable := make(map[string]string, len(existingEnv)+len(parsedEnvs))
+	maps.Copy(referenceable, existingEnv)
+	maps.Copy(referenceable, parsedEnvs)
+	configEnv, err := conf.OSExpandEnvMap(d.cfg.Env(), referenceable, d.ProjectDir())
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string, len(parsedEnvs)+len(configEnv))
+	maps.Copy(env, parsedEnvs)
+	maps.Copy(env, configEnv)
+	return env, nil
 }
 
 // ignoreCurrentEnvVar contains environment variables that Devbox should remove
@@ -1154,7 +1326,7 @@ func (d *Devbox) RunXPaths(ctx context.Context) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		paths, err := pkgtype.RunXClient().Install(ctx, lockedPkg.Resolved)
+		paths, err := pkgtype.RunXInstall(ctx, lockedPkg.Resolved)
 		if err != nil {
 			return "", err
 		}
@@ -1176,15 +1348,40 @@ func (d *Devbox) RunXPaths(ctx context.Context) (string, error) {
 	return runxBinPath, nil
 }
 
-func validateEnvironment(environment string) (string, error) {
+// validateGroups checks that every group is defined in the "groups" section of
+// devbox.json.
+func validateGroups(cfg *devconfig.Config, groups []string) error {
+	for _, group := range groups {
+		if cfg.Root.Group(group) != nil {
+			continue
+		}
+		if len(cfg.Root.GroupNames()) == 0 {
+			return usererr.New("invalid group %q. devbox.json doesn't define any groups.", group)
+		}
+		return usererr.New(
+			"invalid group %q. Group must be one of %s.",
+			group,
+			strings.Join(cfg.Root.GroupNames(), ", "),
+		)
+	}
+	return nil
+}
+
+// validateEnvironment checks that environment is either one of the built-in
+// environments (dev, prod and preview) or is defined in the "environments"
+// section of devbox.json.
+func validateEnvironment(cfg *devconfig.Config, environment string) (string, error) {
 	if environment == "" {
 		return "dev", nil
 	}
-	if environment == "dev" || environment == "prod" || environment == "preview" {
+	builtin := []string{"dev", "prod", "preview"}
+	if slices.Contains(builtin, environment) || cfg.Root.Environment(environment) != nil {
 		return environment, nil
 	}
+	valid := lo.Uniq(append(builtin, cfg.Root.EnvironmentNames()...))
 	return "", usererr.New(
-		"invalid environment %q. Environment must be one of dev, prod, or preview.",
+		"invalid environment %q. Environment must be one of %s.",
 		environment,
+		strings.Join(valid, ", "),
 	)
 }
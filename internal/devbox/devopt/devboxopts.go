@@ -2,6 +2,8 @@
 
 import (
 	"io"
+
+	"go.jetify.com/devbox/internal/lock"
 )
 
 // Naming Convention:
@@ -14,7 +16,21 @@ type Opts struct {
 	Environment              string
 	IgnoreWarnings           bool
 	CustomProcessComposeFile string
-	Stderr                   io.Writer
+	// Groups are the optional package groups to install, as selected with
+	// --with.
+	Groups []string
+	// RelockIncludes re-fetches remote includes and pins their new content
+	// hashes in the lockfile instead of verifying the old ones.
+	RelockIncludes bool
+	// SkipMigrations opens the project without moving deprecated settings
+	// out of devbox.lock, which would otherwise save both files.
+	SkipMigrations bool
+	Stderr         io.Writer
+}
+
+type MigrateOpts struct {
+	// DryRun computes the changes without writing them to disk.
+	DryRun bool
 }
 
 type ProcessComposeOpts struct {
@@ -53,14 +69,49 @@ type AddOpts struct {
 	DisablePlugin    bool
 	Patch            string
 	Outputs          []string
+	// AsOf resolves the added packages to their newest version published
+	// before its date. Nil resolves their newest version.
+	AsOf *lock.NixpkgsAsOf
+}
+
+type LockOpts struct {
+	// Systems are the systems to add store paths for.
+	Systems []string
+}
+
+type VerifyLockOpts struct {
+	// Systems are the systems that every package must be locked for.
+	Systems []string
 }
 
 type UpdateOpts struct {
 	Pkgs                  []string
 	NoInstall             bool
 	IgnoreMissingPackages bool
+	// MaxBump limits updates to versions within this semver distance of
+	// the locked version. The zero value allows any update.
+	MaxBump SemverBump
+	// AsOf resolves packages to their newest version published before its
+	// date, which can be older than the locked version. Nil resolves their
+	// newest version.
+	AsOf *lock.NixpkgsAsOf
 }
 
+type ConsolidateOpts struct {
+	// DryRun reports the changes without writing devbox.lock.
+	DryRun    bool
+	NoInstall bool
+}
+
+// SemverBump is the distance between two semantic versions.
+type SemverBump string
+
+const (
+	BumpPatch SemverBump = "patch"
+	BumpMinor SemverBump = "minor"
+	BumpMajor SemverBump = "major"
+)
+
 type ShellFormat string
 
 const (
@@ -73,6 +124,11 @@ type EnvExportsOpts struct {
 	NoRefreshAlias bool
 	RunHooks       bool
 	ShellFormat    ShellFormat
+
+	// RedactSecrets replaces the values loaded by env_from commands with a
+	// placeholder. Use it when the exports are shown to the user instead of
+	// evaluated by a shell.
+	RedactSecrets bool
 }
 
 // EnvOptions configure the Devbox Environment in the `computeEnv` function.
@@ -0,0 +1,119 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"go.jetify.com/devbox/internal/boxcli/usererr"
+	"go.jetify.com/devbox/internal/cachehash"
+	"go.jetify.com/devbox/internal/devconfig/configfile"
+	"go.jetify.com/devbox/internal/redact"
+)
+
+// execEnvPrefix is the prefix of the env vars that record which variables an
+// env_from command set in the current shell session. The value is a
+// comma-separated list of variable names. The variables themselves are already
+// in the session's environment, so devbox commands run inside the session can
+// reuse them instead of running the command again.
+const execEnvPrefix = "__DEVBOX_EXEC_ENV_"
+
+// execEnvFrom runs the command of an "exec:" env_from source and parses its
+// output as env variables. The variables are secret, so they're redacted
+// from printed environments, and their values are redacted from logs.
+func (d *Devbox) execEnvFrom(command string) (map[string]string, error) {
+	if env, ok := d.execEnvs[command]; ok {
+		return env, nil
+	}
+	sessionVar := d.execEnvSessionVar(command)
+	env, ok := sessionExecEnv(sessionVar)
+	if !ok {
+		slog.Debug("running env_from command", "command", command)
+		var err error
+		env, err = d.runEnvFromCommand(command)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(env))
+	for k, v := range env {
+		names = append(names, k)
+		redact.AddSecrets(v)
+	}
+	slices.Sort(names)
+	if d.execEnvs == nil {
+		d.execEnvs = map[string]map[string]string{}
+		d.execEnvSessionVars = map[string]string{}
+	}
+	d.execEnvs[command] = env
+	d.execEnvSessionVars[sessionVar] = strings.Join(names, ",")
+	return env, nil
+}
+
+func (d *Devbox) runEnvFromCommand(command string) (map[string]string, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = d.projectDir
+	// Let commands like pass or gpg prompt for a passphrase.
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = d.stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "env_from command %q failed.", command)
+	}
+	env, err := configfile.ParseEnvOutput(bytes.TrimSpace(out))
+	if err != nil {
+		return nil, usererr.New(
+			"failed parsing the output of env_from command %q. Error: %v", command, err)
+	}
+	return env, nil
+}
+
+func (d *Devbox) execEnvSessionVar(command string) string {
+	return execEnvPrefix + cachehash.Bytes6([]byte(d.projectDir+"\x00"+command))
+}
+
+// sessionExecEnv returns the variables that an env_from command set in the
+// current shell session, if the session has all of them.
+func sessionExecEnv(sessionVar string) (map[string]string, bool) {
+	names, ok := os.LookupEnv(sessionVar)
+	if !ok {
+		return nil, false
+	}
+	env := map[string]string{}
+	for name := range strings.SplitSeq(names, ",") {
+		if name == "" {
+			continue
+		}
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, false
+		}
+		env[name] = v
+	}
+	return env, true
+}
+
+// redactEnv returns a copy of env with the variables that env_from commands
+// set replaced by a placeholder. Secret values in other variables, such as a
+// URL that embeds a token, are replaced too.
+func (d *Devbox) redactEnv(env map[string]string) map[string]string {
+	redacted := make(map[string]string, len(env))
+	for k, v := range env {
+		redacted[k] = redact.Secrets(v)
+	}
+	for _, execEnv := range d.execEnvs {
+		for k := range execEnv {
+			if _, ok := redacted[k]; ok {
+				redacted[k] = redact.SecretPlaceholder
+			}
+		}
+	}
+	return redacted
+}
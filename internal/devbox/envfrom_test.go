@@ -0,0 +1,84 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecEnvFromSession(t *testing.T) {
+	projectDir := t.TempDir()
+	// The command counts its runs so that the test can tell when the
+	// session's values are reused.
+	command := "echo run >> runs; echo EXEC_ENV_TOKEN=s3cr3t-token"
+
+	d := &Devbox{projectDir: projectDir, stderr: io.Discard}
+	for range 2 {
+		env, err := d.execEnvFrom(command)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := env["EXEC_ENV_TOKEN"]; got != "s3cr3t-token" {
+			t.Errorf("got EXEC_ENV_TOKEN = %q, want %q", got, "s3cr3t-token")
+		}
+	}
+	assertRuns(t, projectDir, 1)
+
+	got := d.redactEnv(map[string]string{
+		"EXEC_ENV_TOKEN": "s3cr3t-token",
+		"URL":            "https://s3cr3t-token@example.com",
+		"PORT":           "5432",
+	})
+	want := map[string]string{
+		"EXEC_ENV_TOKEN": "<redacted>",
+		"URL":            "https://<redacted>@example.com",
+		"PORT":           "5432",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("got redacted env = %v, want %v", got, want)
+	}
+
+	// A new devbox process inside the shell session reuses the values.
+	for k, v := range d.execEnvSessionVars {
+		t.Setenv(k, v)
+	}
+	t.Setenv("EXEC_ENV_TOKEN", "s3cr3t-token")
+	d = &Devbox{projectDir: projectDir, stderr: io.Discard}
+	if _, err := d.execEnvFrom(command); err != nil {
+		t.Fatal(err)
+	}
+	assertRuns(t, projectDir, 1)
+
+	// The command runs again if the session lost one of its variables.
+	os.Unsetenv("EXEC_ENV_TOKEN")
+	d = &Devbox{projectDir: projectDir, stderr: io.Discard}
+	if _, err := d.execEnvFrom(command); err != nil {
+		t.Fatal(err)
+	}
+	assertRuns(t, projectDir, 2)
+}
+
+func TestExecEnvFromError(t *testing.T) {
+	d := &Devbox{projectDir: t.TempDir(), stderr: io.Discard}
+	for _, command := range []string{"exit 1", "echo not env"} {
+		if _, err := d.execEnvFrom(command); err == nil {
+			t.Errorf("execEnvFrom(%q) returned nil error", command)
+		}
+	}
+}
+
+func assertRuns(t *testing.T, projectDir string, want int) {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(projectDir, "runs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(b) / len("run\n"); got != want {
+		t.Errorf("got %d command runs, want %d", got, want)
+	}
+}
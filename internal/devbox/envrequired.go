@@ -0,0 +1,91 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"go.jetify.com/devbox/internal/boxcli/usererr"
+	"go.jetify.com/devbox/internal/devconfig/configfile"
+	"go.jetify.com/devbox/internal/envir"
+)
+
+// checkRequiredEnvBeforeInstall checks the variables from env_required
+// against the current environment and the env and env_from sections of the
+// config, so that a missing variable is reported before packages are
+// installed. Variables that plugins set are left to checkPluginRequiredEnv,
+// because their values can depend on the installed packages.
+func (d *Devbox) checkRequiredEnvBeforeInstall() error {
+	required, _ := d.splitRequiredEnv()
+	if len(required) == 0 {
+		return nil
+	}
+	env := envir.PairsToMap(os.Environ())
+	configEnv, err := d.configEnvs(env)
+	if err != nil {
+		return err
+	}
+	maps.Copy(env, configEnv)
+	return checkRequiredEnv(required, env)
+}
+
+// checkPluginRequiredEnv checks the variables from env_required that plugins
+// set against the computed environment.
+func (d *Devbox) checkPluginRequiredEnv(env map[string]string) error {
+	_, required := d.splitRequiredEnv()
+	return checkRequiredEnv(required, env)
+}
+
+// splitRequiredEnv splits env_required into the variables that plugins don't
+// set and the ones that they do.
+func (d *Devbox) splitRequiredEnv() (notFromPlugins, fromPlugins map[string]*configfile.RequiredEnv) {
+	pluginEnv := d.cfg.PluginEnv()
+	notFromPlugins = map[string]*configfile.RequiredEnv{}
+	fromPlugins = map[string]*configfile.RequiredEnv{}
+	for name, required := range d.cfg.EnvRequired() {
+		if _, ok := pluginEnv[name]; ok {
+			fromPlugins[name] = required
+		} else {
+			notFromPlugins[name] = required
+		}
+	}
+	return notFromPlugins, fromPlugins
+}
+
+// checkRequiredEnv returns a user error that lists every variable from
+// env_required that is missing from env or has an invalid value. It's called
+// before the init hook runs, so that hooks can rely on the variables.
+func checkRequiredEnv(required map[string]*configfile.RequiredEnv, env map[string]string) error {
+	names := make([]string, 0, len(required))
+	for name := range required {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var problems []string
+	for _, name := range names {
+		problem := required[name].Check(env[name])
+		if problem == "" {
+			continue
+		}
+		line := fmt.Sprintf("  %s: %s", name, problem)
+		if required[name] != nil && required[name].Description != "" {
+			line += "\n      " + required[name].Description
+		}
+		problems = append(problems, line)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return usererr.New(
+		"Missing or invalid environment variables from env_required in devbox.json:\n%s\n\n"+
+			"Set them in your shell, in the env or env_from section of devbox.json, or in %s.",
+		strings.Join(problems, "\n"),
+		configfile.LocalName,
+	)
+}
@@ -0,0 +1,79 @@
+package devbox
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.jetify.com/devbox/internal/devconfig"
+	"go.jetify.com/devbox/internal/devconfig/configfile"
+)
+
+func TestCheckRequiredEnv(t *testing.T) {
+	required := map[string]*configfile.RequiredEnv{
+		"API_KEY": {Description: "Create one at https://example.com/keys"},
+		"PORT":    {Pattern: "^[0-9]+$"},
+		"REGION":  nil,
+	}
+
+	err := checkRequiredEnv(required, map[string]string{"PORT": "http", "REGION": "us"})
+	if err == nil {
+		t.Fatal("got nil error, want missing API_KEY and invalid PORT")
+	}
+	for _, want := range []string{
+		"API_KEY: not set\n      Create one at https://example.com/keys",
+		"PORT: doesn't match ^[0-9]+$",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error doesn't contain %q:\n%s", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "REGION") {
+		t.Errorf("error lists REGION, which is set:\n%s", err)
+	}
+
+	err = checkRequiredEnv(required, map[string]string{"API_KEY": "k", "PORT": "80", "REGION": "us"})
+	if err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+}
+
+func TestCheckRequiredEnvBeforeInstall(t *testing.T) {
+	cfgJSON := `{
+  "env": {
+    "FROM_CONFIG": "$FROM_HOST"
+  },
+  "env_required": {
+    "FROM_HOST": {},
+    "FROM_CONFIG": {},
+    "MISSING": {}
+  }
+}`
+	dir := t.TempDir()
+	if err := os.WriteFile(
+		filepath.Join(dir, "devbox.json"), []byte(cfgJSON), 0o644,
+	); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := devconfig.Open(dir)
+	if err != nil {
+		t.Fatalf("Open config error: %v", err)
+	}
+	t.Setenv("FROM_HOST", "host")
+	d := &Devbox{projectDir: dir, cfg: cfg, stderr: io.Discard}
+
+	err = d.checkRequiredEnvBeforeInstall()
+	if err == nil {
+		t.Fatal("got nil error, want missing MISSING")
+	}
+	if !strings.Contains(err.Error(), "MISSING: not set") {
+		t.Errorf("error doesn't list MISSING:\n%s", err)
+	}
+	for _, name := range []string{"FROM_HOST", "FROM_CONFIG"} {
+		if strings.Contains(err.Error(), name) {
+			t.Errorf("error lists %s, which is set:\n%s", name, err)
+		}
+	}
+}
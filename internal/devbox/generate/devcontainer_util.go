@@ -23,6 +23,7 @@
 	"github.com/samber/lo"
 	"go.jetify.com/devbox/internal/boxcli/usererr"
 	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/devconfig/configfile"
 )
 
 //go:embed tmpl/*
@@ -34,6 +35,9 @@ type Options struct {
 	IsDevcontainer bool
 	Pkgs           []string
 	LocalFlakeDirs []string
+	// ConfigName is the file name of the project's config. It defaults to
+	// devbox.json.
+	ConfigName string
 }
 
 type devcontainerObject struct {
@@ -111,6 +115,7 @@ func (g *Options) CreateDockerfile(
 		"IsDevcontainer": g.IsDevcontainer,
 		"RootUser":       g.RootUser,
 		"LocalFlakeDirs": g.LocalFlakeDirs,
+		"ConfigName":     cmp.Or(g.ConfigName, configfile.DefaultName),
 
 		// The following are only used for prod Dockerfile
 		"DevboxRunInstall": lo.Ternary(opts.HasInstall, "devbox run install", "echo 'No install script found, skipping'"),
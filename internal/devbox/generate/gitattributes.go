@@ -0,0 +1,47 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package generate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/trace"
+)
+
+// LockMergeDriver is the name of the git merge driver for devbox.lock.
+const LockMergeDriver = "devbox"
+
+// LockMergeDriverCommand is the git merge driver command for devbox.lock.
+const LockMergeDriverCommand = "devbox lock merge %O %A %B"
+
+var lockAttributes = []byte("devbox.lock merge=" + LockMergeDriver)
+
+// UpdateGitattributes adds the devbox.lock merge driver to the .gitattributes
+// file in dir, creating it if it doesn't exist. It returns false if the
+// file already uses the merge driver.
+func UpdateGitattributes(ctx context.Context, dir string) (bool, error) {
+	defer trace.StartRegion(ctx, "updateGitattributes").End()
+
+	path := filepath.Join(dir, ".gitattributes")
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.Equal(bytes.TrimSpace(line), lockAttributes) {
+			return false, nil
+		}
+	}
+
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	data = append(data, lockAttributes...)
+	data = append(data, '\n')
+	return true, os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,56 @@
+package devbox
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/devconfig/configfile"
+)
+
+func TestLocalPackagesLockfile(t *testing.T) {
+	projectDir := t.TempDir()
+	writeFile(t, projectDir, configfile.DefaultName, `{"packages": ["hello"]}`)
+	writeFile(t, projectDir, configfile.LocalName, `{"packages": ["ripgrep"]}`)
+	writeFile(t, projectDir, "devbox.lock", `{
+  "lockfile_version": "1",
+  "packages": {
+    "github:NixOS/nixpkgs/nixpkgs-unstable": {
+      "resolved": "github:NixOS/nixpkgs/`+migrateCommit+`?lastModified=1700000000"
+    }
+  }
+}
+`)
+
+	box, err := Open(&devopt.Opts{Dir: projectDir, Stderr: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range []string{"hello", "ripgrep"} {
+		if _, err := box.lockfile.Resolve(pkg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := box.lockfile.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	shared := readFile(t, projectDir, "devbox.lock")
+	if !strings.Contains(shared, `"hello"`) || strings.Contains(shared, `"ripgrep"`) {
+		t.Errorf("devbox.lock should only lock hello:\n%s", shared)
+	}
+	local := readFile(t, projectDir, "devbox.local.lock")
+	if !strings.Contains(local, `"ripgrep"`) || strings.Contains(local, `"hello"`) {
+		t.Errorf("devbox.local.lock should only lock ripgrep:\n%s", local)
+	}
+
+	// Reopening the project reads both lockfiles.
+	box, err = Open(&devopt.Opts{Dir: projectDir, Stderr: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if box.lockfile.Get("ripgrep") == nil {
+		t.Error("ripgrep isn't locked after reopening the project")
+	}
+}
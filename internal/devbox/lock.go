@@ -0,0 +1,109 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"runtime/trace"
+
+	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/devpkg"
+	"go.jetify.com/devbox/internal/envir"
+	"go.jetify.com/devbox/internal/lock"
+	"go.jetify.com/devbox/internal/nix"
+	"go.jetify.com/devbox/internal/ux"
+)
+
+// Lock resolves every package in devbox.json and adds the store paths of its
+// outputs to devbox.lock for each of opts.Systems. If opts.Systems is empty,
+// the systems that are already in devbox.lock are used.
+//
+// The search service only has store paths for some packages and flakes have
+// none, so devbox install fills in the current system's store paths as it
+// goes. When developers on different systems share a project, that makes
+// every install rewrite devbox.lock. Lock evaluates the missing store paths
+// for all systems up front so that installs leave the lockfile alone.
+func (d *Devbox) Lock(ctx context.Context, opts devopt.LockOpts) error {
+	defer trace.StartRegion(ctx, "devboxLock").End()
+
+	systems := opts.Systems
+	if len(systems) == 0 {
+		systems = d.lockedSystems()
+	}
+	if err := nix.EnsureValidPlatform(systems...); err != nil {
+		return err
+	}
+
+	cfgPackages := d.cfg.Packages(false /*includeRemovedTriggerPackages*/)
+	cfgPackages = append(cfgPackages, d.cfg.AllGroupPackages()...)
+	packages := devpkg.PackagesFromConfig(cfgPackages, d.lockfile)
+	for _, pkg := range packages {
+		if _, err := d.lockfile.Resolve(pkg.Raw); err != nil {
+			return err
+		}
+	}
+	if err := d.updateLockfile(false /*recomputeState*/); err != nil {
+		return err
+	}
+
+	for i, pkg := range packages {
+		if pkg.IsRunX() {
+			continue
+		}
+		for _, system := range systems {
+			if !cfgPackages[i].IsEnabledOnSystem(system) {
+				continue
+			}
+			if err := d.lockSystem(ctx, pkg, system); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Outputs for other systems can't be installed here, so get their NAR
+	// hashes from the binary cache. Outputs that aren't cached get theirs
+	// when they're first installed, which is also the case in offline mode.
+	if envir.IsOffline() {
+		return d.lockfile.Save()
+	}
+	if err := d.recordNARHashes(ctx, devpkg.BinaryCache, systems...); err != nil {
+		ux.Fwarningf(d.stderr, "Could not get NAR hashes from %s: %v\n", devpkg.BinaryCache, err)
+	}
+	return d.lockfile.Save()
+}
+
+// lockSystem evaluates the outputs of pkg for system if they aren't locked
+// yet.
+func (d *Devbox) lockSystem(ctx context.Context, pkg *devpkg.Package, system string) error {
+	entry, err := d.lockfile.Resolve(pkg.Raw)
+	if err != nil {
+		return err
+	}
+	if sysInfo := entry.Systems[system]; sysInfo != nil && len(sysInfo.Outputs) > 0 {
+		return nil
+	}
+
+	installable, err := pkg.FlakeInstallable()
+	if err != nil {
+		return err
+	}
+	// Evaluate every output so that the lock has the same entries no
+	// matter which outputs this project selects.
+	installable.Outputs = ""
+
+	ux.Finfof(d.stderr, "Locking %s for %s\n", pkg.Raw, system)
+	evaluated, err := nix.OutputsForSystem(ctx, installable.String(), system, pkg.HasAllowInsecure())
+	if err != nil {
+		return fmt.Errorf("evaluate %s for %s: %w", pkg.Raw, system, err)
+	}
+	if len(evaluated) == 0 {
+		return fmt.Errorf("no outputs found for package %s on %s", pkg.Raw, system)
+	}
+	outputs := make([]lock.Output, len(evaluated))
+	for i, out := range evaluated {
+		outputs[i] = lock.Output{Name: out.Name, Path: out.Path, Default: out.Default}
+	}
+	return d.lockfile.SetOutputsForSystem(pkg.Raw, system, outputs)
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/devconfig/configfile"
+)
+
+func TestLockSkipsLockedSystems(t *testing.T) {
+	projectDir := t.TempDir()
+	writeFile(t, projectDir, configfile.DefaultName, `{
+  "packages": {
+    "hello": "latest",
+    "gnumake": {"version": "latest", "platforms": ["x86_64-linux"]}
+  }
+}`)
+	lockfile := `{
+  "lockfile_version": "1",
+  "packages": {
+    "github:NixOS/nixpkgs/nixpkgs-unstable": {
+      "resolved": "github:NixOS/nixpkgs/` + migrateCommit + `?lastModified=1700000000"
+    },
+    "gnumake@latest": {
+      "resolved": "github:NixOS/nixpkgs/` + migrateCommit + `#gnumake",
+      "version": "4.4.1",
+      "systems": {
+        "x86_64-linux": {
+          "outputs": [
+            {
+              "name": "out",
+              "path": "/nix/store/00000000000000000000000000000000-gnumake-4.4.1",
+              "default": true
+            }
+          ]
+        }
+      }
+    },
+    "hello@latest": {
+      "resolved": "github:NixOS/nixpkgs/` + migrateCommit + `#hello",
+      "version": "2.12.1",
+      "systems": {
+        "aarch64-darwin": {
+          "outputs": [
+            {
+              "name": "out",
+              "path": "/nix/store/00000000000000000000000000000000-hello-2.12.1",
+              "default": true
+            }
+          ]
+        },
+        "x86_64-linux": {
+          "outputs": [
+            {
+              "name": "out",
+              "path": "/nix/store/11111111111111111111111111111111-hello-2.12.1",
+              "default": true
+            }
+          ]
+        }
+      }
+    }
+  }
+}
+`
+	writeFile(t, projectDir, "devbox.lock", lockfile)
+
+	box, err := Open(&devopt.Opts{Dir: projectDir, Stderr: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every package is already locked for these systems (gnumake is only
+	// enabled on x86_64-linux), so nothing needs to be evaluated.
+	err = box.Lock(context.Background(), devopt.LockOpts{
+		Systems: []string{"x86_64-linux", "aarch64-darwin"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, projectDir, "devbox.lock"); got != lockfile {
+		t.Errorf("Lock changed devbox.lock:\n%s", got)
+	}
+
+	err = box.Lock(context.Background(), devopt.LockOpts{Systems: []string{"x86_64-windows"}})
+	if err == nil {
+		t.Error("got nil error for an unsupported system")
+	}
+}
+
+func TestTidyKeepsPackagesExcludedByEnvironment(t *testing.T) {
+	projectDir := t.TempDir()
+	writeFile(t, projectDir, configfile.DefaultName, `{
+  "packages": {
+    "hello": "latest",
+    "postgresql": "latest"
+  },
+  "environments": {
+    "ci": {
+      "exclude_packages": ["postgresql"]
+    }
+  }
+}`)
+	writeFile(t, projectDir, "devbox.lock", `{
+  "lockfile_version": "1",
+  "packages": {
+    "github:NixOS/nixpkgs/nixpkgs-unstable": {
+      "resolved": "github:NixOS/nixpkgs/`+migrateCommit+`?lastModified=1700000000"
+    },
+    "hello@latest": {
+      "resolved": "github:NixOS/nixpkgs/`+migrateCommit+`#hello"
+    },
+    "postgresql@latest": {
+      "resolved": "github:NixOS/nixpkgs/`+migrateCommit+`#postgresql"
+    }
+  }
+}
+`)
+
+	box, err := Open(&devopt.Opts{
+		Dir:            projectDir,
+		Environment:    "ci",
+		SkipMigrations: true,
+		Stderr:         io.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	box.lockfile.Tidy()
+	for _, key := range []string{"hello@latest", "postgresql@latest"} {
+		if box.lockfile.Packages[key] == nil {
+			t.Errorf("Tidy removed %s from devbox.lock", key)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"go.jetify.com/devbox/internal/boxcli/usererr"
+	"go.jetify.com/devbox/internal/fileutil"
+	"go.jetify.com/devbox/internal/lock"
+)
+
+// Kinds of changes in a LockDiff.
+const (
+	LockChangeAdded   = "added"
+	LockChangeRemoved = "removed"
+	LockChangeUpdated = "updated"
+)
+
+// LockDiff is the difference between two versions of devbox.lock.
+type LockDiff struct {
+	Changes []LockChange `json:"changes"`
+}
+
+// LockChange is a package that was added, removed or updated between two
+// versions of devbox.lock. Changes that only add or remove store paths
+// aren't included.
+type LockChange struct {
+	Package string `json:"package"`
+	Kind    string `json:"kind"`
+
+	OldVersion string `json:"old_version,omitempty"`
+	NewVersion string `json:"new_version,omitempty"`
+
+	// OldRev and NewRev are the short git revisions that the package is
+	// resolved to, such as the nixpkgs commit.
+	OldRev string `json:"old_rev,omitempty"`
+	NewRev string `json:"new_rev,omitempty"`
+
+	OldPluginVersion string `json:"old_plugin_version,omitempty"`
+	NewPluginVersion string `json:"new_plugin_version,omitempty"`
+
+	oldPkg, newPkg *lock.Package
+}
+
+// LockfileAt reads devbox.lock as it was at a git revision of the project's
+// repository. If rev is the path of a file, that file is read instead, and if
+// rev is empty the current devbox.lock is read. A revision or project without
+// devbox.lock, such as the commit before it was added, has an empty lockfile.
+func (d *Devbox) LockfileAt(ctx context.Context, rev string) (*lock.File, error) {
+	if rev == "" {
+		f, err := lock.ReadFile(lock.FilePath(d.projectDir))
+		if errors.Is(err, fs.ErrNotExist) {
+			return lock.LoadBytes(nil)
+		}
+		return f, err
+	}
+	if fileutil.IsFile(rev) {
+		return lock.ReadFile(rev)
+	}
+
+	// A "./" path is relative to the directory that git runs in. Listing
+	// the file first tells a missing lockfile apart from a bad revision.
+	out, err := d.git(ctx, "ls-tree", "--name-only", rev, "--", "./devbox.lock")
+	if err != nil {
+		return nil, usererr.New("could not read devbox.lock at %q: %v", rev, err)
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return lock.LoadBytes(nil)
+	}
+	out, err = d.git(ctx, "show", rev+":./devbox.lock")
+	if err != nil {
+		return nil, usererr.New("could not read devbox.lock at %q: %v", rev, err)
+	}
+	f, err := lock.LoadBytes(out)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse devbox.lock at %s", rev)
+	}
+	return f, nil
+}
+
+// git runs a git command in the project directory and returns its output. The
+// error has git's error message.
+func (d *Devbox) git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", d.projectDir}, args...)...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.New(cmp.Or(strings.TrimSpace(stderr.String()), err.Error()))
+	}
+	return out, nil
+}
+
+// DiffLockfiles returns the packages that were added, removed or updated
+// between two versions of devbox.lock, sorted by name.
+func DiffLockfiles(from, to *lock.File) *LockDiff {
+	names := slices.Concat(
+		slices.Collect(maps.Keys(from.Packages)),
+		slices.Collect(maps.Keys(to.Packages)),
+	)
+	slices.Sort(names)
+
+	diff := &LockDiff{Changes: []LockChange{}}
+	for _, name := range slices.Compact(names) {
+		oldPkg, newPkg := from.Packages[name], to.Packages[name]
+		change := LockChange{Package: name, oldPkg: oldPkg, newPkg: newPkg}
+		switch {
+		case oldPkg == nil:
+			change.Kind = LockChangeAdded
+		case newPkg == nil:
+			change.Kind = LockChangeRemoved
+		case oldPkg.Version != newPkg.Version || oldPkg.Resolved != newPkg.Resolved ||
+			oldPkg.PluginVersion != newPkg.PluginVersion:
+			change.Kind = LockChangeUpdated
+		default:
+			continue
+		}
+		if oldPkg != nil {
+			change.OldVersion = oldPkg.Version
+			change.OldRev = shortRev(oldPkg.Resolved)
+			change.OldPluginVersion = oldPkg.PluginVersion
+		}
+		if newPkg != nil {
+			change.NewVersion = newPkg.Version
+			change.NewRev = shortRev(newPkg.Resolved)
+			change.NewPluginVersion = newPkg.PluginVersion
+		}
+		diff.Changes = append(diff.Changes, change)
+	}
+	return diff
+}
+
+// Text returns the diff as plain text, grouped by the kind of change.
+func (d *LockDiff) Text() string {
+	if len(d.Changes) == 0 {
+		return "No changes to devbox.lock\n"
+	}
+	buf := &strings.Builder{}
+	for _, kind := range []string{LockChangeAdded, LockChangeRemoved, LockChangeUpdated} {
+		changes := d.changesOfKind(kind)
+		if len(changes) == 0 {
+			continue
+		}
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(buf, "%s%s:\n", strings.ToUpper(kind[:1]), kind[1:])
+		for _, c := range changes {
+			parts := []string{c.Package}
+			parts = append(parts, c.versionSummary(), c.revSummary(), c.pluginSummary())
+			fmt.Fprintf(buf, "  %s\n", joinNonEmpty(parts, "  "))
+		}
+	}
+	return buf.String()
+}
+
+// Markdown returns the diff as a markdown table, such as for the description
+// of a pull request.
+func (d *LockDiff) Markdown() string {
+	if len(d.Changes) == 0 {
+		return "No changes to `devbox.lock`.\n"
+	}
+	buf := &strings.Builder{}
+	buf.WriteString("| Package | Change | Version | Revision | Plugin |\n")
+	buf.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, c := range d.Changes {
+		fmt.Fprintf(buf, "| `%s` | %s | %s | %s | %s |\n",
+			c.Package, c.Kind, c.versionSummary(), c.revSummary(), c.pluginSummary())
+	}
+	return buf.String()
+}
+
+func (d *LockDiff) changesOfKind(kind string) []LockChange {
+	var changes []LockChange
+	for _, c := range d.Changes {
+		if c.Kind == kind {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+func (c *LockChange) versionSummary() string {
+	return describeChange(c.OldVersion, c.NewVersion)
+}
+
+func (c *LockChange) revSummary() string {
+	if c.Kind == LockChangeUpdated && c.oldPkg.Resolved != c.newPkg.Resolved {
+		return describeFlakeUpdate(c.oldPkg, c.newPkg)
+	}
+	return cmp.Or(c.NewRev, c.OldRev)
+}
+
+func (c *LockChange) pluginSummary() string {
+	if c.Kind == LockChangeUpdated && c.OldPluginVersion == c.NewPluginVersion {
+		return ""
+	}
+	if summary := describeChange(c.OldPluginVersion, c.NewPluginVersion); summary != "" {
+		return "plugin " + summary
+	}
+	return ""
+}
+
+// describeChange returns "from -> to" if a value changed, or the value if
+// it's only on one side.
+func describeChange(from, to string) string {
+	switch {
+	case from == to:
+		return from
+	case from == "" || to == "":
+		return from + to
+	default:
+		return from + " -> " + to
+	}
+}
+
+func joinNonEmpty(parts []string, sep string) string {
+	return strings.Join(slices.DeleteFunc(parts, func(s string) bool { return s == "" }), sep)
+}
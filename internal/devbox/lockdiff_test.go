@@ -0,0 +1,133 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"go.jetify.com/devbox/internal/lock"
+)
+
+func TestDiffLockfiles(t *testing.T) {
+	from := &lock.File{Packages: map[string]*lock.Package{
+		"go@latest": {
+			Version:      "1.22.0",
+			Resolved:     "github:NixOS/nixpkgs/1111111111111111111111111111111111111111#go",
+			LastModified: "2024-01-01T00:00:00Z",
+		},
+		"hello@latest": {Version: "2.12", Resolved: "github:NixOS/nixpkgs/1111111111111111111111111111111111111111#hello"},
+		"postgresql@latest": {
+			Version:       "16.2",
+			Resolved:      "github:NixOS/nixpkgs/1111111111111111111111111111111111111111#postgresql",
+			PluginVersion: "0.0.1",
+		},
+		"ripgrep@latest": {Version: "14.0", Resolved: "github:NixOS/nixpkgs/1111111111111111111111111111111111111111#ripgrep"},
+	}}
+	to := &lock.File{Packages: map[string]*lock.Package{
+		"go@latest": {
+			Version:      "1.23.0",
+			Resolved:     "github:NixOS/nixpkgs/2222222222222222222222222222222222222222#go",
+			LastModified: "2024-06-01T00:00:00Z",
+		},
+		"hello@latest": {Version: "2.12", Resolved: "github:NixOS/nixpkgs/1111111111111111111111111111111111111111#hello"},
+		"jq@latest":    {Version: "1.7", Resolved: "github:NixOS/nixpkgs/2222222222222222222222222222222222222222#jq"},
+		"postgresql@latest": {
+			Version:       "16.2",
+			Resolved:      "github:NixOS/nixpkgs/1111111111111111111111111111111111111111#postgresql",
+			PluginVersion: "0.0.2",
+		},
+	}}
+
+	diff := DiffLockfiles(from, to)
+	wantText := `Added:
+  jq@latest  1.7  2222222
+
+Removed:
+  ripgrep@latest  14.0  1111111
+
+Updated:
+  go@latest  1.22.0 -> 1.23.0  1111111 -> 2222222  (2024-01-01 → 2024-06-01)
+  postgresql@latest  16.2  1111111  plugin 0.0.1 -> 0.0.2
+`
+	if d := cmp.Diff(wantText, diff.Text()); d != "" {
+		t.Errorf("wrong text (-want +got):\n%s", d)
+	}
+
+	wantMarkdown := "| Package | Change | Version | Revision | Plugin |\n" +
+		"| --- | --- | --- | --- | --- |\n" +
+		"| `go@latest` | updated | 1.22.0 -> 1.23.0 | 1111111 -> 2222222  (2024-01-01 → 2024-06-01) |  |\n" +
+		"| `jq@latest` | added | 1.7 | 2222222 |  |\n" +
+		"| `postgresql@latest` | updated | 16.2 | 1111111 | plugin 0.0.1 -> 0.0.2 |\n" +
+		"| `ripgrep@latest` | removed | 14.0 | 1111111 |  |\n"
+	if d := cmp.Diff(wantMarkdown, diff.Markdown()); d != "" {
+		t.Errorf("wrong markdown (-want +got):\n%s", d)
+	}
+}
+
+func TestLockfileAtRevisionWithoutLockfile(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("skipping: git not found in PATH")
+	}
+
+	// The project is in a subdirectory of the repository.
+	repoDir := t.TempDir()
+	projectDir := filepath.Join(repoDir, "project")
+	if err := os.Mkdir(projectDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	runGit := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test",
+			"GIT_AUTHOR_EMAIL=test@test.com",
+			"GIT_COMMITTER_NAME=test",
+			"GIT_COMMITTER_EMAIL=test@test.com",
+		)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	runGit("init")
+	if err := os.WriteFile(filepath.Join(projectDir, "devbox.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit("add", ".")
+	runGit("commit", "-m", "init")
+
+	d := &Devbox{projectDir: projectDir}
+	from, err := d.LockfileAt(t.Context(), "HEAD")
+	if err != nil {
+		t.Fatalf("LockfileAt(HEAD) error: %v", err)
+	}
+	if len(from.Packages) != 0 {
+		t.Errorf("got %d packages at HEAD, want 0", len(from.Packages))
+	}
+
+	lockJSON := `{"lockfile_version": "1", "packages": {"go@latest": {"version": "1.22.0"}}}`
+	if err := os.WriteFile(filepath.Join(projectDir, "devbox.lock"), []byte(lockJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit("add", ".")
+	runGit("commit", "-m", "lock")
+	to, err := d.LockfileAt(t.Context(), "HEAD")
+	if err != nil {
+		t.Fatalf("LockfileAt(HEAD) error: %v", err)
+	}
+	diff := DiffLockfiles(from, to)
+	if len(diff.Changes) != 1 || diff.Changes[0].Kind != LockChangeAdded {
+		t.Errorf("got changes %+v, want go@latest added", diff.Changes)
+	}
+
+	if _, err := d.LockfileAt(t.Context(), "no-such-rev"); err == nil {
+		t.Error("LockfileAt(no-such-rev) returned nil error")
+	}
+}
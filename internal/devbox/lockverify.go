@@ -0,0 +1,134 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"runtime/trace"
+	"slices"
+
+	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/nix"
+)
+
+// Kinds of problems that VerifyLock reports.
+const (
+	// LockProblemMissing is a package in devbox.json that isn't locked.
+	LockProblemMissing = "missing"
+	// LockProblemStale is a lock entry that devbox.json no longer needs.
+	LockProblemStale = "stale"
+	// LockProblemMissingSystem is a locked package without store paths for
+	// one of the systems that the project is used on.
+	LockProblemMissingSystem = "missing_system"
+	// LockProblemPluginVersion is a plugin whose locked version is older or
+	// newer than the version that devbox would use.
+	LockProblemPluginVersion = "plugin_version"
+	// LockProblemExcluded is a lock entry for a package that its platforms
+	// exclude on every system that the project is used on.
+	LockProblemExcluded = "excluded"
+)
+
+// LockReport is the result of VerifyLock.
+type LockReport struct {
+	// Systems are the systems that were checked.
+	Systems  []string      `json:"systems"`
+	Problems []LockProblem `json:"problems"`
+}
+
+// LockProblem is a difference between devbox.json and devbox.lock.
+type LockProblem struct {
+	Kind    string `json:"kind"`
+	Package string `json:"package"`
+	System  string `json:"system,omitempty"`
+	Message string `json:"message"`
+}
+
+// VerifyLock checks that devbox.lock is in sync with devbox.json. It doesn't
+// use the network or change any files, so it can run in CI to catch a
+// lockfile that `devbox install` would rewrite.
+//
+// Systems are checked for the systems in opts. If there are none, the
+// systems that are already in devbox.lock are used, or the current system if
+// the lockfile has no systems.
+func (d *Devbox) VerifyLock(ctx context.Context, opts devopt.VerifyLockOpts) *LockReport {
+	defer trace.StartRegion(ctx, "devboxVerifyLock").End()
+
+	report := &LockReport{Systems: opts.Systems, Problems: []LockProblem{}}
+	if len(report.Systems) == 0 {
+		report.Systems = d.lockedSystems()
+	}
+	add := func(kind, pkg, system, format string, a ...any) {
+		report.Problems = append(report.Problems, LockProblem{
+			Kind:    kind,
+			Package: pkg,
+			System:  system,
+			Message: fmt.Sprintf(format, a...),
+		})
+	}
+
+	packages := d.cfg.Packages(true /*includeRemovedTriggerPackages*/)
+	packages = append(packages, d.cfg.AllGroupPackages()...)
+	checked := map[string]bool{}
+	for _, pkg := range packages {
+		name := pkg.VersionedName()
+		if checked[name] {
+			continue
+		}
+		checked[name] = true
+
+		enabledSystems := slices.DeleteFunc(slices.Clone(report.Systems), func(system string) bool {
+			return !pkg.IsEnabledOnSystem(system)
+		})
+		locked := d.lockfile.Get(name)
+		switch {
+		case locked == nil && len(enabledSystems) > 0:
+			add(LockProblemMissing, name, "", "%s is in devbox.json but not in devbox.lock", name)
+		case locked != nil && len(enabledSystems) == 0:
+			add(LockProblemExcluded, name, "",
+				"%s is locked but its platforms exclude every checked system", name)
+		case locked != nil && len(locked.Systems) > 0:
+			for _, system := range enabledSystems {
+				if locked.Systems[system] == nil {
+					add(LockProblemMissingSystem, name, system,
+						"%s is not locked for %s", name, system)
+				}
+			}
+		}
+	}
+
+	for _, key := range d.lockfile.StaleKeys() {
+		add(LockProblemStale, key, "", "%s is in devbox.lock but devbox.json no longer uses it", key)
+	}
+
+	for _, pluginConfig := range d.cfg.IncludedPluginConfigs() {
+		key := pluginConfig.Source.LockfileKey()
+		locked := d.lockfile.Packages[key]
+		if locked == nil || locked.PluginVersion == pluginConfig.Version {
+			continue
+		}
+		add(LockProblemPluginVersion, key, "",
+			"the plugin for %s is locked at version %q but devbox uses version %q",
+			key, locked.PluginVersion, pluginConfig.Version)
+	}
+	return report
+}
+
+// lockedSystems returns the sorted systems that any package in devbox.lock is
+// locked for, or the current system if there are none.
+func (d *Devbox) lockedSystems() []string {
+	systems := []string{}
+	for _, pkg := range d.lockfile.Packages {
+		for system := range pkg.Systems {
+			if !slices.Contains(systems, system) {
+				systems = append(systems, system)
+			}
+		}
+	}
+	if len(systems) == 0 {
+		return []string{nix.System()}
+	}
+	slices.Sort(systems)
+	return systems
+}
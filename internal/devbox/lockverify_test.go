@@ -0,0 +1,89 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/devconfig/configfile"
+)
+
+func TestVerifyLock(t *testing.T) {
+	projectDir := t.TempDir()
+	writeFile(t, projectDir, configfile.DefaultName, `{
+  "packages": {
+    "hello": "latest",
+    "jq": "latest",
+    "gnumake": {"version": "latest", "platforms": ["x86_64-linux"]},
+    "libiconv": {"version": "latest", "platforms": ["aarch64-darwin"]}
+  }
+}`)
+	writeFile(t, projectDir, "devbox.lock", `{
+  "lockfile_version": "1",
+  "packages": {
+    "github:NixOS/nixpkgs/nixpkgs-unstable": {
+      "resolved": "github:NixOS/nixpkgs/`+migrateCommit+`?lastModified=1700000000"
+    },
+    "hello@latest": {
+      "resolved": "github:NixOS/nixpkgs/`+migrateCommit+`#hello",
+      "systems": {
+        "x86_64-linux": {"outputs": [{"name": "out", "path": "/nix/store/hello"}]}
+      }
+    },
+    "gnumake@latest": {
+      "resolved": "github:NixOS/nixpkgs/`+migrateCommit+`#gnumake",
+      "systems": {
+        "x86_64-linux": {"outputs": [{"name": "out", "path": "/nix/store/gnumake"}]}
+      }
+    },
+    "libiconv@latest": {
+      "resolved": "github:NixOS/nixpkgs/`+migrateCommit+`#libiconv"
+    },
+    "ripgrep@latest": {
+      "resolved": "github:NixOS/nixpkgs/`+migrateCommit+`#ripgrep"
+    }
+  }
+}
+`)
+
+	box, err := Open(&devopt.Opts{Dir: projectDir, SkipMigrations: true, Stderr: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the systems in devbox.lock are checked by default.
+	report := box.VerifyLock(context.Background(), devopt.VerifyLockOpts{})
+	want := []LockProblem{
+		{Kind: LockProblemMissing, Package: "jq@latest"},
+		{Kind: LockProblemExcluded, Package: "libiconv@latest"},
+		{Kind: LockProblemStale, Package: "ripgrep@latest"},
+	}
+	if diff := cmp.Diff(want, withoutMessages(report.Problems)); diff != "" {
+		t.Errorf("wrong problems (-want +got):\n%s", diff)
+	}
+
+	report = box.VerifyLock(context.Background(), devopt.VerifyLockOpts{
+		Systems: []string{"x86_64-linux", "aarch64-darwin"},
+	})
+	want = []LockProblem{
+		{Kind: LockProblemMissingSystem, Package: "hello@latest", System: "aarch64-darwin"},
+		{Kind: LockProblemMissing, Package: "jq@latest"},
+		{Kind: LockProblemStale, Package: "ripgrep@latest"},
+	}
+	if diff := cmp.Diff(want, withoutMessages(report.Problems)); diff != "" {
+		t.Errorf("wrong problems (-want +got):\n%s", diff)
+	}
+}
+
+func withoutMessages(problems []LockProblem) []LockProblem {
+	for i := range problems {
+		problems[i].Message = ""
+	}
+	return problems
+}
@@ -0,0 +1,135 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/trace"
+
+	"github.com/pkg/errors"
+	"github.com/rogpeppe/go-internal/diff"
+	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/devpkg"
+	"go.jetify.com/devbox/internal/lock"
+)
+
+// Migration describes the changes that Migrate made to a project, or would
+// make if it isn't a dry run.
+type Migration struct {
+	// Changes describes each change in a sentence.
+	Changes []string
+
+	// Diff is a unified diff of devbox.json and devbox.lock. It's empty when
+	// there are no changes.
+	Diff []byte
+}
+
+// Migrate rewrites deprecated settings in devbox.json and devbox.lock to the
+// current format:
+//
+//   - allow_insecure is moved from devbox.lock to the package in devbox.json.
+//   - patch_glibc is replaced with patch.
+//   - Packages without a version (the legacy format) get the "latest" version.
+//     Their lock entries are removed so that the next install resolves them.
+//   - nixpkgs.commit is removed from devbox.json. The commit stays pinned in
+//     devbox.lock, so packages that depend on it don't change.
+//
+// devbox.json is edited through its syntax tree, so comments and formatting
+// are preserved. The Devbox must be opened with [devopt.Opts.SkipMigrations]
+// so that opening it doesn't already save some of the changes.
+func (d *Devbox) Migrate(ctx context.Context, opts devopt.MigrateOpts) (*Migration, error) {
+	defer trace.StartRegion(ctx, "devboxMigrate").End()
+
+	lockPath := lock.FilePath(d.projectDir)
+	oldConfig, err := d.cfg.Root.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	oldLock, err := os.ReadFile(lockPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	m := &Migration{}
+	lockChanged := false
+
+	insecurePackages, err := migrateAllowInsecure(d.lockfile, d.cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range insecurePackages {
+		m.Changes = append(m.Changes, fmt.Sprintf(
+			"Moved allow_insecure of package %s from devbox.lock to devbox.json", pkg))
+		lockChanged = true
+	}
+
+	for _, pkg := range d.cfg.PackageMutator().MigratePatchGlibc() {
+		m.Changes = append(m.Changes, fmt.Sprintf(
+			"Replaced patch_glibc with patch in package %s", pkg))
+	}
+
+	for _, pkg := range d.cfg.Root.TopLevelPackages() {
+		if !devpkg.PackageFromStringWithDefaults(pkg.VersionedName(), d.lockfile).IsLegacy() {
+			continue
+		}
+		if err := d.cfg.PackageMutator().SetVersion(pkg.VersionedName(), "latest"); err != nil {
+			return nil, err
+		}
+		if _, ok := d.lockfile.Packages[pkg.Name]; ok {
+			delete(d.lockfile.Packages, pkg.Name)
+			lockChanged = true
+		}
+		m.Changes = append(m.Changes, fmt.Sprintf(
+			"Changed legacy package %s to %s@latest", pkg.Name, pkg.Name))
+	}
+
+	if commit := d.cfg.NixPkgsCommitHash(); commit != "" {
+		pinned := d.Stdenv().String()
+		entry := d.lockfile.Packages[pinned]
+		if entry == nil || entry.Resolved == "" {
+			entry = &lock.Package{Resolved: pinned}
+		}
+		if _, err := d.cfg.Root.UnsetPath("nixpkgs"); err != nil {
+			return nil, err
+		}
+		delete(d.lockfile.Packages, pinned)
+		d.lockfile.Packages[d.Stdenv().String()] = entry
+		lockChanged = true
+		m.Changes = append(m.Changes, fmt.Sprintf(
+			"Removed nixpkgs.commit from devbox.json and pinned nixpkgs to %s in devbox.lock", commit))
+	}
+
+	newConfig, err := d.cfg.Root.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	configName := d.cfg.Root.FileName()
+	m.Diff = append(m.Diff, diff.Diff(
+		"a/"+configName, oldConfig, "b/"+configName, newConfig)...)
+	if lockChanged {
+		newLock, err := d.lockfile.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		name := filepath.Base(lockPath)
+		m.Diff = append(m.Diff, diff.Diff("a/"+name, oldLock, "b/"+name, newLock)...)
+	}
+
+	if opts.DryRun || len(m.Changes) == 0 {
+		return m, nil
+	}
+	if err := d.saveCfg(); err != nil {
+		return nil, err
+	}
+	if lockChanged {
+		if err := d.lockfile.Save(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/devconfig/configfile"
+)
+
+const migrateCommit = "0123456789abcdef0123456789abcdef01234567"
+
+func TestMigrate(t *testing.T) {
+	projectDir := t.TempDir()
+	config := `{
+  "nixpkgs": {"commit": "` + migrateCommit + `"},
+  // Tools for the build.
+  "packages": {
+    "hello": "",
+    "python": {"version": "3.12", "patch_glibc": true}
+  }
+}
+`
+	lockfile := `{
+  "lockfile_version": "1",
+  "packages": {
+    "github:NixOS/nixpkgs/` + migrateCommit + `": {
+      "resolved": "github:NixOS/nixpkgs/` + migrateCommit + `?lastModified=1700000000"
+    },
+    "hello": {
+      "resolved": "github:NixOS/nixpkgs/` + migrateCommit + `#hello"
+    }
+  }
+}
+`
+	writeFile(t, projectDir, configfile.DefaultName, config)
+	writeFile(t, projectDir, "devbox.lock", lockfile)
+
+	// A dry run only returns the diff.
+	m := migrateProject(t, projectDir, true)
+	if len(m.Changes) != 3 {
+		t.Errorf("got %d changes, want 3: %q", len(m.Changes), m.Changes)
+	}
+	for _, want := range []string{
+		`-  "nixpkgs": {"commit": "` + migrateCommit + `"},`,
+		`+    "hello":  "latest",`,
+		`"patch": "always"`,
+		`-    "github:NixOS/nixpkgs/` + migrateCommit + `": {`,
+		`+    "github:NixOS/nixpkgs/nixpkgs-unstable": {`,
+	} {
+		if !strings.Contains(string(m.Diff), want) {
+			t.Errorf("diff doesn't contain %q:\n%s", want, m.Diff)
+		}
+	}
+	if got := readFile(t, projectDir, configfile.DefaultName); got != config {
+		t.Errorf("dry run changed devbox.json:\n%s", got)
+	}
+
+	migrateProject(t, projectDir, false)
+	gotConfig := readFile(t, projectDir, configfile.DefaultName)
+	for _, unwanted := range []string{"nixpkgs", "patch_glibc"} {
+		if strings.Contains(gotConfig, unwanted) {
+			t.Errorf("migrated devbox.json still contains %q:\n%s", unwanted, gotConfig)
+		}
+	}
+	if !strings.Contains(gotConfig, "// Tools for the build.") {
+		t.Errorf("migrated devbox.json lost its comment:\n%s", gotConfig)
+	}
+
+	// The migrated project keeps the same nixpkgs commit.
+	box, err := Open(&devopt.Opts{Dir: projectDir, Stderr: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := box.lockfile.Stdenv().Rev; got != migrateCommit {
+		t.Errorf("got stdenv commit %q, want %q", got, migrateCommit)
+	}
+	if _, ok := box.lockfile.Packages["hello"]; ok {
+		t.Error("migrated devbox.lock still has the legacy hello entry")
+	}
+
+	if m := migrateProject(t, projectDir, false); len(m.Changes) != 0 || len(m.Diff) != 0 {
+		t.Errorf("migrating twice made changes %q:\n%s", m.Changes, m.Diff)
+	}
+}
+
+func migrateProject(t *testing.T, projectDir string, dryRun bool) *Migration {
+	t.Helper()
+
+	box, err := Open(&devopt.Opts{
+		Dir:            projectDir,
+		SkipMigrations: true,
+		Stderr:         io.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := box.Migrate(context.Background(), devopt.MigrateOpts{DryRun: dryRun})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"go.jetify.com/devbox/internal/boxcli/usererr"
+	"go.jetify.com/devbox/internal/devpkg"
+	"go.jetify.com/devbox/internal/lock"
+	"go.jetify.com/devbox/internal/nix"
+	"go.jetify.com/devbox/internal/ux"
+)
+
+// recordNARHashes records the NAR hashes and sizes of locked outputs that
+// don't have one yet. They are read from store, or the local store if store
+// is empty, so outputs that aren't in the store are skipped.
+func (d *Devbox) recordNARHashes(ctx context.Context, store string, systems ...string) error {
+	var infos []*lock.SystemInfo
+	var paths []string
+	for _, pkg := range d.lockfile.Packages {
+		for _, system := range systems {
+			if unhashed := pkg.Systems[system].UnhashedOutputPaths(); len(unhashed) > 0 {
+				infos = append(infos, pkg.Systems[system])
+				paths = append(paths, unhashed...)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	slices.Sort(paths)
+	pathInfos, err := nix.PathInfos(ctx, store, slices.Compact(paths))
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		for _, path := range info.UnhashedOutputPaths() {
+			if pathInfo, ok := pathInfos[path]; ok {
+				info.SetNARInfo(path, pathInfo.NARHash, pathInfo.NARSize)
+			}
+		}
+	}
+	return nil
+}
+
+// VerifyStorePaths checks that the installed outputs of every package match
+// the NAR hashes and sizes in devbox.lock, and that their contents in the
+// local store haven't been modified since they were added. A hash that doesn't
+// match means that a substituter served different content than when the
+// package was locked.
+func (d *Devbox) VerifyStorePaths(ctx context.Context) error {
+	expected := map[string]lock.Output{}
+	unhashed := 0
+	for _, pkg := range lo.Filter(d.InstallablePackages(), devpkg.IsNix) {
+		entry := d.lockfile.Get(pkg.Raw)
+		if entry == nil || entry.Systems[nix.System()] == nil {
+			continue
+		}
+		installed, err := pkg.GetResolvedStorePaths()
+		if err != nil {
+			return err
+		}
+		for _, output := range entry.Systems[nix.System()].Outputs {
+			if !slices.Contains(installed, output.Path) {
+				continue
+			}
+			if output.NARHash == "" {
+				unhashed++
+				continue
+			}
+			expected[output.Path] = output
+		}
+	}
+	if unhashed > 0 {
+		ux.Fwarningf(d.stderr, "%d output(s) in devbox.lock have no NAR hash and can't be verified.\n", unhashed)
+	}
+	if len(expected) == 0 {
+		return nil
+	}
+
+	paths := make([]string, 0, len(expected))
+	for path := range expected {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	pathInfos, err := nix.PathInfos(ctx, "", paths)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	for _, path := range paths {
+		want, got := expected[path], pathInfos[path]
+		switch {
+		case got.NARHash == "":
+			problems = append(problems, fmt.Sprintf("%s is not in the nix store", path))
+		case got.NARHash != want.NARHash || got.NARSize != want.NARSize:
+			problems = append(problems, fmt.Sprintf(
+				"%s has NAR hash %s (%d bytes) but devbox.lock expects %s (%d bytes). "+
+					"A substituter may have served different content.",
+				path, got.NARHash, got.NARSize, want.NARHash, want.NARSize))
+		}
+	}
+
+	modified, err := nix.ModifiedStorePaths(ctx, paths)
+	if err != nil {
+		return err
+	}
+	for _, path := range modified {
+		problems = append(problems, fmt.Sprintf(
+			"%s was modified after it was added to the nix store", path))
+	}
+
+	if len(problems) > 0 {
+		return usererr.New("store path verification failed:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	ux.Fsuccessf(d.stderr, "Verified %d store path(s) against devbox.lock\n", len(paths))
+	return nil
+}
@@ -30,6 +30,7 @@
 
 	"go.jetify.com/devbox/internal/boxcli/usererr"
 	"go.jetify.com/devbox/internal/debug"
+	"go.jetify.com/devbox/internal/envir"
 	"go.jetify.com/devbox/internal/nix"
 	"go.jetify.com/devbox/internal/plugin"
 	"go.jetify.com/devbox/internal/ux"
@@ -159,6 +160,20 @@ func (d *Devbox) Add(ctx context.Context, pkgsNames []string, opts devopt.AddOpt
 		return err
 	}
 
+	// Lock the packages as of the date before ensureStateIsUpToDate, which
+	// only resolves packages that aren't locked yet.
+	if opts.AsOf != nil {
+		for _, name := range addedPackageNames {
+			resolved, err := d.lockfile.FetchResolvedPackageAsOf(ctx, name, opts.AsOf)
+			if err != nil {
+				return err
+			}
+			ux.Finfof(d.stderr, "Resolved %s to version %s as of %s\n",
+				name, resolved.Version, opts.AsOf.Date.Format(time.DateOnly))
+			d.lockfile.Packages[name] = resolved
+		}
+	}
+
 	if err := d.ensureStateIsUpToDate(ctx, install); err != nil {
 		return usererr.WithUserMessage(err, "There was an error installing nix packages")
 	}
@@ -366,6 +381,14 @@ func (d *Devbox) updateLockfile(recomputeState bool) error {
 		}
 	}
 
+	// Lock the packages of groups that aren't selected, so that selecting
+	// them later installs the same versions on every machine.
+	for _, pkg := range d.cfg.AllGroupPackages() {
+		if _, err := d.lockfile.Resolve(pkg.VersionedName()); err != nil {
+			return err
+		}
+	}
+
 	// Update plugin versions in lockfile.
 	for _, pluginConfig := range d.Config().IncludedPluginConfigs() {
 		if err := d.PluginManager().UpdateLockfileVersion(pluginConfig); err != nil {
@@ -472,6 +495,12 @@ func (d *Devbox) installPackages(ctx context.Context, mode installMode) error {
 		return err
 	}
 
+	// Now that the outputs are in the store, record their NAR hashes so
+	// that `devbox install --verify` can check them later.
+	if err := d.recordNARHashes(ctx, "" /*store*/, nix.System()); err != nil {
+		return err
+	}
+
 	return d.InstallRunXPackages(ctx)
 }
 
@@ -481,10 +510,7 @@ func (d *Devbox) InstallRunXPackages(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
-		if _, err := pkgtype.RunXClient().Install(
-			ctx,
-			lockedPkg.Resolved,
-		); err != nil {
+		if _, err := pkgtype.RunXInstall(ctx, lockedPkg.Resolved); err != nil {
 			return fmt.Errorf("error installing runx package %s: %w", pkg, err)
 		}
 	}
@@ -544,6 +570,13 @@ func(p *devpkg.Package, _ int) string { return p.Raw },
 		args.AllowInsecure = allowInsecure
 		err = nix.Build(ctx, args, installables...)
 		if err != nil {
+			if envir.IsOffline() {
+				return usererr.WithUserMessage(err,
+					"could not install %s in offline mode. Their store paths aren't in the "+
+						"local nix store and nix doesn't have the sources to build them cached. "+
+						"Run devbox install without --offline first.",
+					strings.Join(packageNames, ", "))
+			}
 			return err
 		}
 		telemetry.Event(telemetry.EventNixBuildSuccess, telemetry.Metadata{
@@ -608,12 +641,39 @@ func (d *Devbox) isBeingUpdated(pkg *devpkg.Package) bool {
 
 // moveAllowInsecureFromLockfile will modernize a Devbox project by moving the allow_insecure: boolean
 // setting from the devbox.lock file to the corresponding package in devbox.json.
+func (d *Devbox) moveAllowInsecureFromLockfile(writer io.Writer, lockfile *lock.File, cfg *devconfig.Config) error {
+	insecurePackages, err := migrateAllowInsecure(lockfile, cfg)
+	if err != nil || len(insecurePackages) == 0 {
+		return err
+	}
+
+	if err := d.saveCfg(); err != nil {
+		return err
+	}
+
+	// Now, clear it from the lockfile
+	if err := lockfile.Save(); err != nil {
+		return err
+	}
+
+	ux.Finfof(
+		writer,
+		"Modernized the allow_insecure setting for package %q by moving it from devbox.lock to devbox.json. Please commit the changes.\n",
+		strings.Join(insecurePackages, ", "),
+	)
+
+	return nil
+}
+
+// migrateAllowInsecure moves the allow_insecure: boolean setting from the
+// in-memory lockfile to the corresponding packages in the config without
+// saving either of them. It returns the packages that it moved.
 //
 // NOTE: ideally, this function would be in devconfig, but it leads to an import cycle with devpkg, so
 // leaving in this "top-level" devbox package where we can import devconfig, devpkg and lock.
-func (d *Devbox) moveAllowInsecureFromLockfile(writer io.Writer, lockfile *lock.File, cfg *devconfig.Config) error {
+func migrateAllowInsecure(lockfile *lock.File, cfg *devconfig.Config) ([]string, error) {
 	if !lockfile.HasAllowInsecurePackages() {
-		return nil
+		return nil, nil
 	}
 
 	insecurePackages := []string{}
@@ -623,35 +683,20 @@ func (d *Devbox) moveAllowInsecureFromLockfile(writer io.Writer, lockfile *lock.
 		}
 		pkg.AllowInsecure = false
 	}
+	slices.Sort(insecurePackages)
 
 	// Set the devbox.json packages to allow_insecure
 	for _, versionedName := range insecurePackages {
 		pkg := devpkg.PackageFromStringWithDefaults(versionedName, lockfile)
 		storeName, err := pkg.StoreName()
 		if err != nil {
-			return fmt.Errorf("failed to get package's store name for package %q with error %w", versionedName, err)
+			return nil, fmt.Errorf("failed to get package's store name for package %q with error %w", versionedName, err)
 		}
-		if err := cfg.PackageMutator().SetAllowInsecure(writer, versionedName, []string{storeName}); err != nil {
-			return fmt.Errorf("failed to set allow_insecure in devbox.json for package %q with error %w", versionedName, err)
+		if err := cfg.PackageMutator().SetAllowInsecure(io.Discard, versionedName, []string{storeName}); err != nil {
+			return nil, fmt.Errorf("failed to set allow_insecure in devbox.json for package %q with error %w", versionedName, err)
 		}
 	}
-
-	if err := d.saveCfg(); err != nil {
-		return err
-	}
-
-	// Now, clear it from the lockfile
-	if err := lockfile.Save(); err != nil {
-		return err
-	}
-
-	ux.Finfof(
-		writer,
-		"Modernized the allow_insecure setting for package %q by moving it from devbox.lock to devbox.json. Please commit the changes.\n",
-		strings.Join(insecurePackages, ", "),
-	)
-
-	return nil
+	return insecurePackages, nil
 }
 
 func (d *Devbox) FixMissingStorePaths(ctx context.Context) error {
@@ -109,11 +109,15 @@ func (d *Devbox) shellPath(envOpts devopt.EnvOptions) (path string, err error) {
 
 	var bashNixStorePath string // of the form /nix/store/{hash}-bash-{version}/
 
+	nixFlags := nix.ExperimentalFlags()
+	if envir.IsOffline() {
+		nixFlags = append(nixFlags, "--offline")
+	}
 	cmd := exec.Command(
 		"nix", "eval", "--raw",
 		fmt.Sprintf("%s#bashInteractive", d.Lockfile().Stdenv().String()),
 	)
-	cmd.Args = append(cmd.Args, nix.ExperimentalFlags()...)
+	cmd.Args = append(cmd.Args, nixFlags...)
 	out, err := cmd.Output()
 	if err != nil {
 		return "", errors.WithStack(err)
@@ -122,7 +126,7 @@ func (d *Devbox) shellPath(envOpts devopt.EnvOptions) (path string, err error) {
 
 	// install bashInteractive in nix/store without creating a symlink to local directory (--no-link)
 	cmd = exec.Command("nix", "build", bashNixStorePath, "--no-link")
-	cmd.Args = append(cmd.Args, nix.ExperimentalFlags()...)
+	cmd.Args = append(cmd.Args, nixFlags...)
 	err = cmd.Run()
 	if err != nil {
 		return "", errors.WithStack(err)
@@ -11,9 +11,11 @@
 	"time"
 
 	"github.com/pkg/errors"
+	"go.jetify.com/devbox/internal/boxcli/usererr"
 	"go.jetify.com/devbox/internal/devbox/devopt"
 	"go.jetify.com/devbox/internal/devpkg"
 	"go.jetify.com/devbox/internal/devpkg/pkgtype"
+	"go.jetify.com/devbox/internal/envir"
 	"go.jetify.com/devbox/internal/lock"
 	"go.jetify.com/devbox/internal/nix"
 	"go.jetify.com/devbox/internal/plugin"
@@ -24,7 +26,15 @@
 )
 
 func (d *Devbox) Update(ctx context.Context, opts devopt.UpdateOpts) error {
-	if len(opts.Pkgs) == 0 || slices.Contains(opts.Pkgs, "nixpkgs") {
+	if envir.IsOffline() {
+		return usererr.New(updateOfflineMessage)
+	}
+	if err := checkUpdateOpts(opts); err != nil {
+		return err
+	}
+
+	var skipped []SkippedUpdate
+	if updatesNixpkgs(opts) {
 		if err := d.lockfile.UpdateStdenv(); err != nil {
 			return err
 		}
@@ -36,6 +46,8 @@ func (d *Devbox) Update(ctx context.Context, opts devopt.UpdateOpts) error {
 		opts.Pkgs = slices.DeleteFunc(opts.Pkgs, func(pkg string) bool {
 			return pkg == "nixpkgs"
 		})
+	} else if len(opts.Pkgs) == 0 {
+		skipped = append(skipped, skippedNixpkgs)
 	}
 
 	inputs, err := d.inputsToUpdate(opts)
@@ -45,7 +57,9 @@ func (d *Devbox) Update(ctx context.Context, opts devopt.UpdateOpts) error {
 
 	pendingPackagesToUpdate := []*devpkg.Package{}
 	for _, pkg := range inputs {
-		if pkg.IsLegacy() {
+		if pkg.IsLegacy() && keepsUnversioned(opts) {
+			skipped = append(skipped, SkippedUpdate{Package: pkg.Raw, Reason: skippedLegacyReason})
+		} else if pkg.IsLegacy() {
 			fmt.Fprintf(d.stderr, "Updating %s -> %s\n", pkg.Raw, pkg.LegacyToVersioned())
 
 			// Get the package from the config to get the Platforms and ExcludedPlatforms later
@@ -71,9 +85,13 @@ func (d *Devbox) Update(ctx context.Context, opts devopt.UpdateOpts) error {
 		}
 	}
 
-	if err := d.updatePendingPackages(pendingPackagesToUpdate); err != nil {
+	pendingSkipped, err := d.updatePendingPackages(pendingPackagesToUpdate, d.lockfile, opts)
+	if err != nil {
 		return err
 	}
+	for _, s := range append(skipped, pendingSkipped...) {
+		ux.Fwarningf(d.stderr, "Not updating %s\n", s)
+	}
 
 	d.packagesBeingUpdated = inputs
 
@@ -122,37 +140,115 @@ func (d *Devbox) inputsToUpdate(
 // updatePendingPackages updates the lockfile entries for each package, using
 // the right strategy per package kind. Flake refs warn-and-continue on
 // failure (see #1180 / #1840); versioned nixpkgs packages abort the update on
-// failure. Unversioned non-flake entries are left alone.
-func (d *Devbox) updatePendingPackages(pkgs []*devpkg.Package) error {
+// failure. Unversioned non-flake entries are left alone. It returns the
+// packages with newer versions that opts.MaxBump doesn't allow.
+func (d *Devbox) updatePendingPackages(
+	pkgs []*devpkg.Package,
+	lockfile *lock.File,
+	opts devopt.UpdateOpts,
+) ([]SkippedUpdate, error) {
+	var skipped []SkippedUpdate
 	for _, pkg := range pkgs {
 		if pkgtype.IsFlake(pkg.Raw) {
-			if err := d.updateDevboxPackage(pkg); err != nil {
+			s, err := d.updateDevboxPackage(pkg, lockfile, opts)
+			if err != nil {
 				ux.Fwarningf(d.stderr, "Failed to update %s: %s\n", pkg.Raw, err)
+			} else if s != nil {
+				skipped = append(skipped, *s)
 			}
 			continue
 		}
 		if _, _, isVersioned := searcher.ParseVersionedPackage(pkg.Raw); isVersioned {
-			if err := d.updateDevboxPackage(pkg); err != nil {
-				return err
+			s, err := d.updateDevboxPackage(pkg, lockfile, opts)
+			if err != nil {
+				return nil, err
+			}
+			if s != nil {
+				skipped = append(skipped, *s)
 			}
 		}
 	}
-	return nil
+	return skipped, nil
 }
 
-func (d *Devbox) updateDevboxPackage(pkg *devpkg.Package) error {
+// updateDevboxPackage resolves the newest version of pkg and merges it into
+// lockfile. If the newest version is further from the locked version than
+// opts.MaxBump allows, the lockfile isn't changed and the skipped update is
+// returned instead.
+func (d *Devbox) updateDevboxPackage(
+	pkg *devpkg.Package,
+	lockfile *lock.File,
+	opts devopt.UpdateOpts,
+) (*SkippedUpdate, error) {
+	if opts.AsOf != nil {
+		return d.updateDevboxPackageAsOf(pkg, lockfile, opts.AsOf)
+	}
+	maxBump := opts.MaxBump
+	existing := lockfile.Packages[pkg.Raw]
+	limited := existing != nil && isLimitedBump(maxBump)
+	if limited && pkgtype.IsFlake(pkg.Raw) {
+		return &SkippedUpdate{Package: pkg.Raw, Reason: skippedFlakeReason}, nil
+	}
+	query := pkg.Raw
+	if limited {
+		query = constrainedQuery(pkg.Raw, existing.Version, maxBump)
+	}
+
 	// refresh=true so flake refs bypass nix's own metadata cache and re-query
 	// upstream. Without this, `devbox update` on a github: ref can return a
 	// stale commit that nix had cached from an earlier call.
-	resolved, err := d.lockfile.FetchResolvedPackage(pkg.Raw, true)
+	resolved, err := d.lockfile.FetchResolvedPackage(query, true)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if resolved == nil {
-		return nil
+		return nil, nil
+	}
+	if limited && resolved.Version != existing.Version {
+		if reason := bumpExceeded(existing.Version, resolved.Version, maxBump); reason != "" {
+			return &SkippedUpdate{
+				Package:        pkg.Raw,
+				CurrentVersion: existing.Version,
+				NewVersion:     resolved.Version,
+				Reason:         reason,
+			}, nil
+		}
+	}
+
+	return nil, d.mergeResolvedPackageToLockfile(pkg, resolved, lockfile)
+}
+
+// updateDevboxPackageAsOf resolves pkg to its newest version published before
+// the date of asOf and merges it into lockfile. Unlike other updates, it moves
+// packages to older versions if that's what was newest at the time.
+func (d *Devbox) updateDevboxPackageAsOf(
+	pkg *devpkg.Package,
+	lockfile *lock.File,
+	asOf *lock.NixpkgsAsOf,
+) (*SkippedUpdate, error) {
+	switch {
+	case pkgtype.IsFlake(pkg.Raw):
+		return &SkippedUpdate{Package: pkg.Raw, Reason: skippedFlakeReason}, nil
+	case pkg.IsRunX():
+		return &SkippedUpdate{Package: pkg.Raw, Reason: skippedRunXAsOf}, nil
 	}
 
-	return d.mergeResolvedPackageToLockfile(pkg, resolved, d.lockfile)
+	resolved, err := d.lockfile.FetchResolvedPackageAsOf(context.TODO(), pkg.Raw, asOf)
+	if err != nil {
+		return nil, err
+	}
+	existing := lockfile.Packages[pkg.Raw]
+	switch {
+	case existing == nil:
+		ux.Finfof(d.stderr, "Resolved %s to %[1]s %[2]s\n", pkg, resolved.Resolved)
+		lockfile.Packages[pkg.Raw] = resolved
+	case existing.Resolved == resolved.Resolved:
+		ux.Finfof(d.stderr, "Already at %s %s\n", pkg, existing.Version)
+	default:
+		ux.Finfof(d.stderr, "Updating %s %s -> %s\n", pkg, existing.Version, resolved.Version)
+		useResolvedPackageInLockfile(lockfile, pkg, resolved, existing)
+	}
+	return nil, nil
 }
 
 func (d *Devbox) mergeResolvedPackageToLockfile(
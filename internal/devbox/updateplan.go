@@ -0,0 +1,293 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"runtime/trace"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/semver"
+
+	"go.jetify.com/devbox/internal/boxcli/usererr"
+	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/devpkg"
+	"go.jetify.com/devbox/internal/devpkg/pkgtype"
+	"go.jetify.com/devbox/internal/envir"
+	"go.jetify.com/devbox/internal/lock"
+	"go.jetify.com/devbox/internal/plugin"
+	"go.jetify.com/devbox/internal/searcher"
+)
+
+const updateOfflineMessage = "devbox update looks for newer versions with the devbox search " +
+	"service and nix, which offline mode doesn't use."
+
+// Reasons for a SkippedUpdate other than the size of the version bump.
+const (
+	skippedFlakeReason  = "flake references aren't versioned"
+	skippedLegacyReason = "legacy packages aren't versioned"
+	skippedRunXAsOf     = "runx packages can't be resolved as of a date"
+)
+
+var skippedNixpkgs = SkippedUpdate{
+	Package: "nixpkgs",
+	Reason:  "nixpkgs isn't versioned, run `devbox update nixpkgs` to update it",
+}
+
+// UpdatePlan is the changes that Update would make to devbox.lock.
+type UpdatePlan struct {
+	*LockDiff
+
+	// Includes are the remote includes whose content changed since it was
+	// pinned in devbox.lock. Update pins their new content.
+	Includes []*plugin.IncludeUpdate `json:"includes"`
+
+	// Skipped are packages that weren't updated because their newest
+	// version is further from the locked version than UpdateOpts.MaxBump
+	// allows, or because they aren't versioned.
+	Skipped []SkippedUpdate `json:"skipped"`
+}
+
+// SkippedUpdate is a package that Update leaves at its locked version.
+type SkippedUpdate struct {
+	Package        string `json:"package"`
+	CurrentVersion string `json:"current_version,omitempty"`
+	NewVersion     string `json:"new_version,omitempty"`
+	Reason         string `json:"reason"`
+}
+
+func (s SkippedUpdate) String() string {
+	return joinNonEmpty([]string{
+		s.Package,
+		describeChange(s.CurrentVersion, s.NewVersion),
+		"(" + s.Reason + ")",
+	}, "  ")
+}
+
+// Text returns the plan as plain text.
+func (p *UpdatePlan) Text() string {
+	buf := &strings.Builder{}
+	if len(p.Changes) > 0 || len(p.Includes) == 0 {
+		buf.WriteString(p.LockDiff.Text())
+	}
+	if len(p.Includes) > 0 {
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("Includes:\n")
+		for _, include := range p.Includes {
+			fmt.Fprintf(buf, "  %s  content changed\n", include.Include)
+		}
+	}
+	if len(p.Skipped) > 0 {
+		buf.WriteString("\nSkipped:\n")
+		for _, s := range p.Skipped {
+			fmt.Fprintf(buf, "  %s\n", s)
+		}
+	}
+	return buf.String()
+}
+
+// PlanUpdate returns the changes that Update would make to devbox.lock with
+// the same options. It resolves packages like Update does, but it doesn't
+// write devbox.lock or install anything.
+func (d *Devbox) PlanUpdate(ctx context.Context, opts devopt.UpdateOpts) (*UpdatePlan, error) {
+	if envir.IsOffline() {
+		return nil, usererr.New(updateOfflineMessage)
+	}
+	if err := checkUpdateOpts(opts); err != nil {
+		return nil, err
+	}
+	defer trace.StartRegion(ctx, "devboxPlanUpdate").End()
+
+	// The plan replaces the messages about each package.
+	stderr := d.stderr
+	d.stderr = io.Discard
+	defer func() { d.stderr = stderr }()
+
+	from, to := cloneLockfile(d.lockfile), cloneLockfile(d.lockfile)
+	plan := &UpdatePlan{Includes: []*plugin.IncludeUpdate{}, Skipped: []SkippedUpdate{}}
+	if relocksIncludes(opts) {
+		// The project is opened without re-pinning its includes, so
+		// devbox.lock still has the hashes that Update would replace.
+		includes, err := plugin.OutdatedIncludes(d.includedPlugins(), d.lockfile)
+		if err != nil {
+			return nil, err
+		}
+		plan.Includes = includes
+	}
+	if updatesNixpkgs(opts) {
+		stdenv := d.Stdenv().String()
+		resolved, err := d.lockfile.FetchResolvedPackage(stdenv, true /*refresh*/)
+		if err != nil {
+			return nil, err
+		}
+		to.Packages[stdenv] = resolved
+	} else if len(opts.Pkgs) == 0 {
+		plan.Skipped = append(plan.Skipped, skippedNixpkgs)
+	}
+	if slices.Equal(opts.Pkgs, []string{"nixpkgs"}) {
+		plan.LockDiff = DiffLockfiles(from, to)
+		return plan, nil
+	}
+	opts.Pkgs = slices.DeleteFunc(opts.Pkgs, func(pkg string) bool { return pkg == "nixpkgs" })
+
+	inputs, err := d.inputsToUpdate(opts)
+	if err != nil {
+		return nil, err
+	}
+	pending := []*devpkg.Package{}
+	for _, pkg := range inputs {
+		switch {
+		case pkg.IsLegacy() && keepsUnversioned(opts):
+			plan.Skipped = append(plan.Skipped, SkippedUpdate{Package: pkg.Raw, Reason: skippedLegacyReason})
+		case pkg.IsLegacy():
+			// Update replaces legacy packages with their @latest version.
+			resolved, err := d.lockfile.FetchResolvedPackage(pkg.LegacyToVersioned(), true /*refresh*/)
+			if err != nil {
+				return nil, err
+			}
+			delete(to.Packages, pkg.Raw)
+			to.Packages[pkg.LegacyToVersioned()] = resolved
+		default:
+			pending = append(pending, pkg)
+		}
+	}
+
+	skipped, err := d.updatePendingPackages(pending, to, opts)
+	if err != nil {
+		return nil, err
+	}
+	plan.Skipped = append(plan.Skipped, skipped...)
+	plan.LockDiff = DiffLockfiles(from, to)
+	return plan, nil
+}
+
+// checkUpdateOpts returns an error if opts asks for an update that can't be
+// made.
+func checkUpdateOpts(opts devopt.UpdateOpts) error {
+	if opts.AsOf != nil && slices.Contains(opts.Pkgs, "nixpkgs") {
+		return usererr.New("nixpkgs isn't versioned, so it can't be updated as of a date")
+	}
+	return nil
+}
+
+// relocksIncludes reports whether an update with opts re-pins the content of
+// remote includes. Only updating everything does, and patch and minor updates
+// only bump packages.
+func relocksIncludes(opts devopt.UpdateOpts) bool {
+	return len(opts.Pkgs) == 0 && !isLimitedBump(opts.MaxBump)
+}
+
+// updatesNixpkgs reports whether an update with opts updates the nixpkgs
+// commit. Updating everything includes nixpkgs unless opts restricts the
+// versions to update to, because nixpkgs isn't versioned.
+func updatesNixpkgs(opts devopt.UpdateOpts) bool {
+	return slices.Contains(opts.Pkgs, "nixpkgs") ||
+		len(opts.Pkgs) == 0 && !keepsUnversioned(opts)
+}
+
+// keepsUnversioned reports whether an update with opts leaves nixpkgs and
+// legacy packages alone. They aren't versioned, so the version bump limit
+// and the as-of date can't be applied to them.
+func keepsUnversioned(opts devopt.UpdateOpts) bool {
+	return isLimitedBump(opts.MaxBump) || opts.AsOf != nil
+}
+
+// isLimitedBump reports whether maxBump rules out some updates. A major bump
+// allows any update.
+func isLimitedBump(maxBump devopt.SemverBump) bool {
+	return maxBump == devopt.BumpPatch || maxBump == devopt.BumpMinor
+}
+
+// constrainedQuery returns the versioned package to resolve so that the
+// search service returns the newest version within maxBump of the locked
+// version. For example, python@latest locked at 3.12.1 is resolved as
+// python@3.12 for a patch bump. A version in devbox.json that is more
+// specific than that is kept.
+func constrainedQuery(raw, lockedVersion string, maxBump devopt.SemverBump) string {
+	if pkgtype.IsRunX(raw) {
+		return raw
+	}
+	name, constraint, ok := searcher.ParseVersionedPackage(raw)
+	prefix := versionPrefix(lockedVersion, maxBump)
+	if !ok || prefix == "" {
+		return raw
+	}
+	if constraint == "latest" || isVersionPrefix(constraint, prefix) {
+		return name + "@" + prefix
+	}
+	return raw
+}
+
+// versionPrefix returns the part of version that a maxBump update keeps, or
+// an empty string if version isn't a semantic version.
+func versionPrefix(version string, maxBump devopt.SemverBump) string {
+	if !semver.IsValid("v" + version) {
+		return ""
+	}
+	parts := strings.SplitN(version, ".", 3)
+	switch {
+	case maxBump == devopt.BumpPatch && len(parts) >= 2:
+		return parts[0] + "." + parts[1]
+	case maxBump == devopt.BumpMinor:
+		return parts[0]
+	}
+	return ""
+}
+
+// isVersionPrefix reports whether version starts with all the components of
+// prefix. 1.2 is a prefix of 1.2.3, but not of 1.23.
+func isVersionPrefix(prefix, version string) bool {
+	return version == prefix || strings.HasPrefix(version, prefix+".")
+}
+
+// bumpExceeded returns why an update from one version to another isn't
+// allowed by maxBump, or an empty string if it is.
+func bumpExceeded(from, to string, maxBump devopt.SemverBump) string {
+	from, to = "v"+from, "v"+to
+	if !semver.IsValid(from) || !semver.IsValid(to) {
+		return "not a semantic version"
+	}
+	var bump devopt.SemverBump
+	switch {
+	case semver.Major(from) != semver.Major(to):
+		bump = devopt.BumpMajor
+	case semver.MajorMinor(from) != semver.MajorMinor(to):
+		bump = devopt.BumpMinor
+	default:
+		bump = devopt.BumpPatch
+	}
+	order := map[devopt.SemverBump]int{devopt.BumpPatch: 1, devopt.BumpMinor: 2, devopt.BumpMajor: 3}
+	if order[bump] > order[maxBump] {
+		return string(bump) + " update"
+	}
+	return ""
+}
+
+// cloneLockfile copies the packages and include pins of a lockfile so that
+// updates to the copy don't change the original.
+func cloneLockfile(f *lock.File) *lock.File {
+	clone := &lock.File{
+		LockFileVersion: f.LockFileVersion,
+		Packages:        make(map[string]*lock.Package, len(f.Packages)),
+	}
+	for name, pkg := range f.Packages {
+		p := *pkg
+		clone.Packages[name] = &p
+	}
+	for key, include := range f.Includes {
+		if clone.Includes == nil {
+			clone.Includes = map[string]*lock.Include{}
+		}
+		i := *include
+		i.Files = maps.Clone(include.Files)
+		clone.Includes[key] = &i
+	}
+	return clone
+}
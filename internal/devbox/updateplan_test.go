@@ -0,0 +1,200 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/devconfig/configfile"
+	"go.jetify.com/devbox/internal/envir"
+	"go.jetify.com/devbox/internal/plugin"
+	"go.jetify.com/devbox/internal/searcher"
+)
+
+func TestPlanUpdate(t *testing.T) {
+	// Newest versions by package and version constraint.
+	versions := map[string]string{
+		"go@1.22":     "1.22.7",
+		"go@1":        "1.23.1",
+		"python@3.11": "3.11.9",
+		"python@3":    "3.12.4",
+	}
+	// The project includes a local plugin that includes a remote plugin
+	// whose content changes after it's pinned.
+	pluginContent := `{"name": "plan-plugin"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/plugin.json" {
+			_, _ = w.Write([]byte(pluginContent))
+			return
+		}
+		name, version := r.URL.Query().Get("name"), r.URL.Query().Get("version")
+		newest, ok := versions[name+"@"+version]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		info := searcher.PackageInfo{
+			CommitHash:  migrateCommit,
+			LastUpdated: 1720000000,
+			AttrPaths:   []string{name},
+			Version:     newest,
+		}
+		_ = json.NewEncoder(w).Encode(searcher.PackageVersion{
+			PackageInfo: info,
+			Name:        name,
+			Systems:     map[string]searcher.PackageInfo{"x86_64-linux": info},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { _ = plugin.Update() })
+	t.Setenv(envir.DevboxSearchHost, srv.URL)
+	t.Setenv("DEVBOX_FEATURE_RESOLVE_V2", "0")
+
+	projectDir := t.TempDir()
+	writeFile(t, projectDir, configfile.DefaultName, `{
+  "packages": {"go": "latest", "python": "3"},
+  "include": ["./wrapper"]
+}`)
+	if err := os.Mkdir(filepath.Join(projectDir, "wrapper"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, projectDir, "wrapper/plugin.json", `{
+  "name": "wrapper",
+  "include": ["`+srv.URL+`/plugin.json"]
+}`)
+	lockfile := `{
+  "lockfile_version": "1",
+  "packages": {
+    "go@latest": {
+      "last_modified": "2024-01-01T00:00:00Z",
+      "resolved": "github:NixOS/nixpkgs/` + migrateCommit + `#go",
+      "version": "1.22.5"
+    },
+    "python@3": {
+      "last_modified": "2024-01-01T00:00:00Z",
+      "resolved": "github:NixOS/nixpkgs/` + migrateCommit + `#python",
+      "version": "3.11.2"
+    }
+  }
+}
+`
+	writeFile(t, projectDir, "devbox.lock", lockfile)
+	box, err := Open(&devopt.Opts{Dir: projectDir, Stderr: io.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pluginContent = `{"name": "plan-plugin", "env": {"CHANGED": "1"}}`
+	// A full update would re-pin the include.
+	if includes, err := plugin.OutdatedIncludes(box.includedPlugins(), box.lockfile); err != nil || len(includes) != 1 {
+		t.Fatalf("OutdatedIncludes = %d updates, %v, want 1 update", len(includes), err)
+	}
+
+	for _, test := range []struct {
+		maxBump devopt.SemverBump
+		want    map[string]string
+	}{
+		{devopt.BumpPatch, map[string]string{"go@latest": "1.22.7", "python@3": "3.11.9"}},
+		{devopt.BumpMinor, map[string]string{"go@latest": "1.23.1", "python@3": "3.12.4"}},
+	} {
+		t.Run(string(test.maxBump), func(t *testing.T) {
+			plan, err := box.PlanUpdate(context.Background(), devopt.UpdateOpts{MaxBump: test.maxBump})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]string{}
+			for _, change := range plan.Changes {
+				if change.Kind != LockChangeUpdated {
+					t.Errorf("got %s change for %s, want %s", change.Kind, change.Package, LockChangeUpdated)
+				}
+				got[change.Package] = change.NewVersion
+			}
+			if len(got) != len(test.want) {
+				t.Errorf("got updates %v, want %v", got, test.want)
+			}
+			for pkg, version := range test.want {
+				if got[pkg] != version {
+					t.Errorf("got %s update to %q, want %q", pkg, got[pkg], version)
+				}
+			}
+			// Limited updates leave nixpkgs and includes alone.
+			if !slices.Contains(plan.Skipped, skippedNixpkgs) {
+				t.Errorf("got skipped %v, want nixpkgs", plan.Skipped)
+			}
+			if len(plan.Includes) != 0 {
+				t.Errorf("got %d include updates, want 0", len(plan.Includes))
+			}
+		})
+	}
+
+	if got := readFile(t, projectDir, "devbox.lock"); got != lockfile {
+		t.Errorf("PlanUpdate changed devbox.lock:\n%s", got)
+	}
+}
+
+func TestUpdatePlanText(t *testing.T) {
+	plan := &UpdatePlan{
+		LockDiff: &LockDiff{},
+		Includes: []*plugin.IncludeUpdate{{Include: "https://example.com/plugin.json"}},
+		Skipped:  []SkippedUpdate{skippedNixpkgs},
+	}
+	want := "Includes:\n" +
+		"  https://example.com/plugin.json  content changed\n" +
+		"\nSkipped:\n" +
+		"  " + skippedNixpkgs.String() + "\n"
+	if got := plan.Text(); got != want {
+		t.Errorf("got text:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestConstrainedQuery(t *testing.T) {
+	for _, test := range []struct {
+		raw, locked string
+		maxBump     devopt.SemverBump
+		want        string
+	}{
+		{"python@latest", "3.12.1", devopt.BumpPatch, "python@3.12"},
+		{"python@latest", "3.12.1", devopt.BumpMinor, "python@3"},
+		{"python@3", "3.12.1", devopt.BumpPatch, "python@3.12"},
+		{"python@3.12.1", "3.12.1", devopt.BumpPatch, "python@3.12.1"},
+		{"python@3.1", "3.1.4", devopt.BumpMinor, "python@3.1"},
+		{"jq@latest", "1.7", devopt.BumpPatch, "jq@1.7"},
+		{"hello@latest", "unstable-2024-01-01", devopt.BumpPatch, "hello@latest"},
+		{"runx:golangci/golangci-lint@latest", "v1.59.1", devopt.BumpPatch, "runx:golangci/golangci-lint@latest"},
+	} {
+		if got := constrainedQuery(test.raw, test.locked, test.maxBump); got != test.want {
+			t.Errorf("constrainedQuery(%q, %q, %q) = %q, want %q",
+				test.raw, test.locked, test.maxBump, got, test.want)
+		}
+	}
+}
+
+func TestBumpExceeded(t *testing.T) {
+	for _, test := range []struct {
+		from, to string
+		maxBump  devopt.SemverBump
+		want     string
+	}{
+		{"1.22.5", "1.22.7", devopt.BumpPatch, ""},
+		{"1.22.5", "1.23.0", devopt.BumpPatch, "minor update"},
+		{"1.22.5", "1.23.0", devopt.BumpMinor, ""},
+		{"1.22.5", "2.0.0", devopt.BumpMinor, "major update"},
+		{"1.22.5", "2.0.0", devopt.BumpMajor, ""},
+		{"1.7", "1.7.1", devopt.BumpPatch, ""},
+		{"2024-01-01", "2024-02-01", devopt.BumpPatch, "not a semantic version"},
+	} {
+		if got := bumpExceeded(test.from, test.to, test.maxBump); got != test.want {
+			t.Errorf("bumpExceeded(%q, %q, %q) = %q, want %q",
+				test.from, test.to, test.maxBump, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+
+	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/devpkg/pkgtype"
+	"go.jetify.com/devbox/internal/plugin"
+)
+
+// Kinds of UpdateCandidate.
+const (
+	UpdateKindPackage = "package"
+	UpdateKindFlake   = "flake"
+	UpdateKindPlugin  = "plugin"
+)
+
+// UpdateCandidate is a package, flake input or remote plugin that has a newer
+// version than the one in devbox.lock.
+type UpdateCandidate struct {
+	// Name is the package as written in devbox.json, "nixpkgs", or the
+	// include of a plugin.
+	Name string
+	Kind string
+	// Change describes the update, such as "1.22.5 -> 1.23.1".
+	Change string
+
+	include *plugin.IncludeUpdate
+}
+
+func (c UpdateCandidate) String() string {
+	return joinNonEmpty([]string{c.Name, "(" + c.Kind + ")", c.Change}, "  ")
+}
+
+// UpdateCandidates returns the updates that Update would make with opts, so
+// that some of them can be applied with ApplyUpdates. Like PlanUpdate, it
+// doesn't write devbox.lock, and it includes remote plugins whose content
+// changed when opts doesn't name any packages or limit the version bump.
+// GitHub and builtin plugins aren't included, because devbox.lock doesn't pin
+// their content: they're updated when their cache expires or devbox is
+// upgraded, whether or not they're picked.
+func (d *Devbox) UpdateCandidates(ctx context.Context, opts devopt.UpdateOpts) ([]UpdateCandidate, error) {
+	plan, err := d.PlanUpdate(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []UpdateCandidate{}
+	stdenv := d.Stdenv().String()
+	for _, change := range plan.Changes {
+		// Legacy packages show up as removed and added because Update
+		// replaces them with their @latest version. Non-interactive
+		// updates still take care of them.
+		if change.Kind != LockChangeUpdated {
+			continue
+		}
+		candidate := UpdateCandidate{
+			Name:   change.Package,
+			Kind:   UpdateKindPackage,
+			Change: joinNonEmpty([]string{change.versionSummary(), change.revSummary()}, "  "),
+		}
+		switch {
+		case change.Package == stdenv:
+			candidate.Name, candidate.Kind = "nixpkgs", UpdateKindFlake
+		case pkgtype.IsFlake(change.Package):
+			candidate.Kind = UpdateKindFlake
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	for _, include := range plan.Includes {
+		candidates = append(candidates, UpdateCandidate{
+			Name:    include.Include,
+			Kind:    UpdateKindPlugin,
+			Change:  "content changed",
+			include: include,
+		})
+	}
+	return candidates, nil
+}
+
+// ApplyUpdates applies updates returned by UpdateCandidates. Packages and
+// flake inputs are updated with Update, and plugins are re-locked to their
+// new content.
+func (d *Devbox) ApplyUpdates(ctx context.Context, updates []UpdateCandidate, opts devopt.UpdateOpts) error {
+	opts.Pkgs = nil
+	for _, update := range updates {
+		if update.include == nil {
+			opts.Pkgs = append(opts.Pkgs, update.Name)
+			continue
+		}
+		if err := update.include.Apply(d.lockfile); err != nil {
+			return err
+		}
+	}
+	if err := d.lockfile.Save(); err != nil {
+		return err
+	}
+	// Update with no packages would update everything.
+	if len(opts.Pkgs) == 0 {
+		return nil
+	}
+	return d.Update(ctx, opts)
+}
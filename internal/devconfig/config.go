@@ -9,6 +9,8 @@
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
@@ -41,6 +43,20 @@ type Config struct {
 
 	pluginData *plugin.PluginOnlyData // pointer by design, to allow for nil
 
+	// environment is the overlay selected with SelectEnvironment. It is nil
+	// when no environment is selected or the selected environment isn't
+	// defined in the config.
+	environment     *configfile.EnvironmentConfig
+	environmentName string
+
+	// groups are the names of the optional package groups selected with
+	// SelectGroups.
+	groups []string
+
+	// local is the devbox.local.json next to the root config, if there is
+	// one. It's merged over everything else.
+	local *configfile.ConfigFile
+
 	included []*Config
 }
 
@@ -142,8 +158,7 @@ func Find(path string) (*Config, error) {
 // searchDir looks for a config file in dir. It does not search parent
 // directories.
 func searchDir(dir string) (*Config, error) {
-	try := []string{configfile.DefaultName}
-	for _, name := range try {
+	for _, name := range configfile.Names {
 		path := filepath.Join(dir, name)
 		slog.Debug("trying config file", "path", path)
 
@@ -156,7 +171,7 @@ func searchDir(dir string) (*Config, error) {
 		if errors.Is(err, os.ErrNotExist) {
 			continue
 		}
-		// Ignore directories named devbox.json.
+		// Ignore directories named like a config.
 		if errors.Is(err, errIsDirectory) {
 			continue
 		}
@@ -187,14 +202,43 @@ func readFromFile(path string) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	config, err := loadBytes(b)
+	root, err := configfile.LoadNamedBytes(path, b)
+	if validationErr := (&configfile.ValidationError{}); errors.As(err, &validationErr) {
+		validationErr.Name = path
+	}
 	if err != nil {
 		return nil, err
 	}
+	config := &Config{Root: *root}
 	config.Root.AbsRootPath, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	config.local, err = readLocalFile(filepath.Join(filepath.Dir(path), configfile.LocalName))
 	return config, err
 }
 
+// readLocalFile reads a devbox.local.json. It returns nil if the file doesn't
+// exist.
+func readLocalFile(path string) (*configfile.ConfigFile, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	local, err := configfile.LoadLocalBytes(b)
+	if validationErr := (&configfile.ValidationError{}); errors.As(err, &validationErr) {
+		validationErr.Name = path
+	}
+	if err != nil {
+		return nil, err
+	}
+	local.AbsRootPath = path
+	return local, nil
+}
+
 func LoadConfigFromURL(ctx context.Context, url string) (*Config, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
@@ -225,7 +269,12 @@ func loadBytes(b []byte) (*Config, error) {
 }
 
 func (c *Config) LoadRecursive(lockfile *lock.File) error {
-	return c.loadRecursive(lockfile, map[string]bool{}, "" /*cyclePath*/)
+	if err := c.loadRecursive(lockfile, map[string]bool{}, "" /*cyclePath*/); err != nil {
+		return err
+	}
+	// Scripts can depend on scripts from plugins, so their dependencies
+	// can only be checked once everything is loaded.
+	return c.Scripts().ValidateDependencies()
 }
 
 // loadRecursive loads all the included plugins and their included plugins, etc.
@@ -266,7 +315,7 @@ func (c *Config) loadRecursive(
 	}
 
 	builtIns, err := plugin.GetBuiltinsForPackages(
-		c.Root.TopLevelPackages(),
+		c.topLevelPackages(),
 		lockfile,
 	)
 	if err != nil {
@@ -290,6 +339,123 @@ func (c *Config) loadRecursive(
 	return nil
 }
 
+// Diagnostic is a schema problem in a config file or in one of the plugins it
+// includes.
+type Diagnostic struct {
+	// File is the path of the config file or the include reference of the
+	// plugin.
+	File string
+	configfile.Diagnostic
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%s", d.File, d.Diagnostic)
+}
+
+// Diagnostics returns the schema problems in the config and its included
+// plugins. It should be called after LoadRecursive to include plugins.
+func (c *Config) Diagnostics() []Diagnostic {
+	var diags []configfile.Diagnostic
+	file := c.Root.AbsRootPath
+	if c.pluginData == nil {
+		diags = c.Root.Validate()
+	} else {
+		diags = c.pluginData.Diagnostics
+		if c.pluginData.Source != nil {
+			file = c.pluginData.Source.LockfileKey()
+		}
+	}
+
+	result := make([]Diagnostic, 0, len(diags))
+	for _, d := range diags {
+		result = append(result, Diagnostic{File: file, Diagnostic: d})
+	}
+	for _, included := range c.included {
+		result = append(result, included.Diagnostics()...)
+	}
+	return result
+}
+
+// SelectEnvironment merges the named environment from the config's
+// "environments" section on top of the base config. Selecting an environment
+// that the config doesn't define is not an error; the base config is used
+// unchanged. It should be called before LoadRecursive so that built-in
+// plugins for the environment's packages are loaded.
+func (c *Config) SelectEnvironment(name string) {
+	c.environmentName = name
+	c.environment = c.Root.Environment(name)
+}
+
+// SelectGroups adds the packages of the named groups from the config's
+// "groups" section to the top-level packages. Like SelectEnvironment, it
+// should be called before LoadRecursive. Callers must check that the groups
+// exist.
+func (c *Config) SelectGroups(names []string) {
+	c.groups = slices.Clone(names)
+	slices.Sort(c.groups)
+	c.groups = slices.Compact(c.groups)
+}
+
+// SelectedGroups returns the sorted names of the selected groups.
+func (c *Config) SelectedGroups() []string {
+	return c.groups
+}
+
+// topLevelPackages returns the packages of the root config with the selected
+// environment's overrides and the selected groups applied.
+func (c *Config) topLevelPackages() []configfile.Package {
+	packages := c.environment.ApplyToPackages(c.Root.TopLevelPackages())
+	for _, name := range c.groups {
+		packages = c.Root.Group(name).ApplyToPackages(packages)
+	}
+	if c.local != nil {
+		packages = configfile.MergePackages(packages, c.local.TopLevelPackages())
+	}
+	return packages
+}
+
+// LocalPackageNames returns the versioned names of the packages that are only
+// declared in devbox.local.json, and not in devbox.json.
+func (c *Config) LocalPackageNames() []string {
+	if c.local == nil {
+		return nil
+	}
+	shared := c.Root.TopLevelPackages()
+	shared = append(shared, c.AllEnvironmentPackages()...)
+	shared = append(shared, c.AllGroupPackages()...)
+	names := []string{}
+	for _, pkg := range c.local.TopLevelPackages() {
+		if !slices.ContainsFunc(shared, func(p configfile.Package) bool {
+			return p.VersionedName() == pkg.VersionedName()
+		}) {
+			names = append(names, pkg.VersionedName())
+		}
+	}
+	return names
+}
+
+// AllEnvironmentPackages returns the top-level packages of every environment,
+// including environments that aren't selected. It is used to keep lockfile
+// entries for those packages so that switching environments doesn't churn
+// devbox.lock.
+func (c *Config) AllEnvironmentPackages() []configfile.Package {
+	packages := []configfile.Package{}
+	for _, name := range c.Root.EnvironmentNames() {
+		packages = append(packages, c.Root.Environment(name).Packages()...)
+	}
+	return packages
+}
+
+// AllGroupPackages returns the packages of every group, including groups that
+// aren't selected. They're locked even when they aren't installed.
+func (c *Config) AllGroupPackages() []configfile.Package {
+	packages := []configfile.Package{}
+	for _, name := range c.Root.GroupNames() {
+		packages = append(packages, c.Root.Group(name).Packages()...)
+	}
+	return packages
+}
+
 func (c *Config) PackageMutator() *configfile.PackagesMutator {
 	return &c.Root.PackagesMutator
 }
@@ -328,7 +494,7 @@ func (c *Config) Packages(
 
 	// Packages to remove in built ins only affect the devbox.json where they are defined.
 	// They should not remove packages that are part of other imports.
-	for _, pkg := range c.Root.TopLevelPackages() {
+	for _, pkg := range c.topLevelPackages() {
 		if !packagesToRemove[pkg.VersionedName()] {
 			packages = append(packages, pkg)
 		}
@@ -350,16 +516,55 @@ func (c *Config) NixPkgsCommitHash() string {
 }
 
 func (c *Config) Env() map[string]string {
+	env := c.PluginEnv()
+	rootConfigEnv := OSExpandIfPossible(c.Root.Env, env)
+	maps.Copy(env, rootConfigEnv)
+	if c.environment != nil {
+		maps.Copy(env, OSExpandIfPossible(c.environment.Env, env))
+	}
+	if c.local != nil {
+		maps.Copy(env, OSExpandIfPossible(c.local.Env, env))
+	}
+	return env
+}
+
+// PluginEnv returns the env variables that the included configs (plugins)
+// set.
+func (c *Config) PluginEnv() map[string]string {
 	env := map[string]string{}
 	for _, i := range c.included {
 		expandedEnvFromPlugin := OSExpandIfPossible(i.Env(), env)
 		maps.Copy(env, expandedEnvFromPlugin)
 	}
-	rootConfigEnv := OSExpandIfPossible(c.Root.Env, env)
-	maps.Copy(env, rootConfigEnv)
 	return env
 }
 
+// EnvRequired returns the required env variables of this config and its
+// included configs (plugins).
+func (c *Config) EnvRequired() map[string]*configfile.RequiredEnv {
+	required := map[string]*configfile.RequiredEnv{}
+	for _, i := range c.included {
+		maps.Copy(required, i.EnvRequired())
+	}
+	maps.Copy(required, c.Root.EnvRequired)
+	return required
+}
+
+// EnvFrom returns the env_from value of the selected environment, or the root
+// config's env_from if the environment doesn't set one.
+func (c *Config) EnvFrom() configfile.EnvFrom {
+	if c.environment != nil && len(c.environment.EnvFrom) > 0 {
+		return c.environment.EnvFrom
+	}
+	return c.Root.EnvFrom
+}
+
+// ParseEnvFrom loads the env variables from the files and commands that
+// EnvFrom lists. Commands are run with execEnv.
+func (c *Config) ParseEnvFrom(execEnv configfile.ExecEnvFunc) (map[string]string, error) {
+	return c.Root.ParseEnvFrom(c.EnvFrom(), execEnv)
+}
+
 func (c *Config) InitHook() *shellcmd.Commands {
 	commands := shellcmd.Commands{}
 	for _, i := range c.included {
@@ -387,6 +592,10 @@ func (c *Config) Scripts() configfile.Scripts {
 		maps.Copy(scripts, i.Scripts())
 	}
 	maps.Copy(scripts, c.Root.Scripts())
+	maps.Copy(scripts, c.Root.EnvironmentScripts(c.environmentName))
+	if c.local != nil {
+		maps.Copy(scripts, c.local.Scripts())
+	}
 	return scripts
 }
 
@@ -404,6 +613,22 @@ func (c *Config) Hash() (string, error) {
 		return "", err
 	}
 	data = append(data, hash...)
+	if c.environment != nil {
+		// Switching environments changes the packages, env and scripts
+		// even though devbox.json itself is unchanged.
+		data = append(data, c.environmentName...)
+	}
+	if len(c.groups) > 0 {
+		// Likewise, selecting groups changes the packages.
+		data = append(data, "groups="+strings.Join(c.groups, ",")...)
+	}
+	if c.local != nil {
+		hash, err := c.local.Hash()
+		if err != nil {
+			return "", err
+		}
+		data = append(data, "local="+hash...)
+	}
 	return cachehash.Bytes(data), nil
 }
 
@@ -413,7 +638,7 @@ func (c *Config) IsJetifyCloudEnvFrom() bool {
 			return true
 		}
 	}
-	return c.Root.IsJetifyCloudEnvFrom()
+	return configfile.IsJetifyCloudEnvFrom(c.EnvFrom())
 }
 
 func createIncludableFromPluginConfig(pluginConfig *plugin.Config) *Config {
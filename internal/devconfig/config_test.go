@@ -9,6 +9,7 @@
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"github.com/tailscale/hujson"
 	"go.jetify.com/devbox/internal/devconfig/configfile"
 	"go.jetify.com/devbox/internal/lock"
@@ -186,6 +187,35 @@ func TestFind(t *testing.T) {
 			t.Errorf("cfg.Root.AbsRootPath = %q, want %q", cfg.Root.AbsRootPath, path)
 		}
 	})
+	for name, content := range map[string]string{
+		"devbox.yaml": "packages:\n  go: latest\n",
+		"devbox.toml": "[packages]\ngo = \"latest\"\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			root, _, nested := mkNestedDirs(t)
+			path := filepath.Join(root, name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Find(nested)
+			if err != nil {
+				t.Fatalf("Find(%q) error: %v", nested, err)
+			}
+			if cfg.Root.AbsRootPath != path {
+				t.Errorf("cfg.Root.AbsRootPath = %q, want %q", cfg.Root.AbsRootPath, path)
+			}
+			if got := cfg.Root.FileName(); got != name {
+				t.Errorf("cfg.Root.FileName() = %q, want %q", got, name)
+			}
+			if _, ok := cfg.Root.GetPackage("go@latest"); !ok {
+				t.Error("cfg.Root.GetPackage(\"go@latest\") = false, want true")
+			}
+			if _, err := Init(root); !errors.Is(err, fs.ErrExist) {
+				t.Errorf("Init(%q) error = %v, want fs.ErrExist", root, err)
+			}
+		})
+	}
 }
 
 func TestFindError(t *testing.T) {
@@ -400,11 +430,14 @@ func TestExampleLocalPluginAliases(t *testing.T) {
 func TestDefault(t *testing.T) {
 	path := filepath.Join(t.TempDir())
 	cfg := DefaultConfig()
-	inBytes := cfg.Root.Bytes()
+	inBytes, err := cfg.Root.Bytes()
+	if err != nil {
+		t.Fatal("got encoding error:", err)
+	}
 	if _, err := hujson.Parse(inBytes); err != nil {
 		t.Fatalf("default config JSON is invalid: %v\n%s", err, inBytes)
 	}
-	err := cfg.Root.SaveTo(path)
+	err = cfg.Root.SaveTo(path)
 	if err != nil {
 		t.Fatal("got save error:", err)
 	}
@@ -421,7 +454,10 @@ func TestDefault(t *testing.T) {
 		t.Errorf("configs not equal (-in +out):\n%s", diff)
 	}
 
-	outBytes := out.Root.Bytes()
+	outBytes, err := out.Root.Bytes()
+	if err != nil {
+		t.Fatal("got encoding error:", err)
+	}
 	if _, err := hujson.Parse(outBytes); err != nil {
 		t.Fatalf("loaded default config JSON is invalid: %v\n%s", err, outBytes)
 	}
@@ -568,4 +604,242 @@ type testLockProject struct {
 func (p *testLockProject) ConfigHash() (string, error)                              { return "", nil }
 func (p *testLockProject) Stdenv() flake.Ref                                        { return flake.Ref{} }
 func (p *testLockProject) AllPackageNamesIncludingRemovedTriggerPackages() []string { return nil }
+func (p *testLockProject) InactivePackageNames() []string                           { return nil }
+func (p *testLockProject) IncludeLockfileKeys() []string                            { return nil }
+func (p *testLockProject) LocalPackageNames() []string                              { return nil }
 func (p *testLockProject) ProjectDir() string                                       { return p.dir }
+
+func TestSelectEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	cfgJSON := `{
+  "packages": {
+    "go": "1.21",
+    "hello": "latest",
+    "postgresql": "15"
+  },
+  "env": {
+    "DB_HOST": "localhost",
+    "LOG_LEVEL": "debug"
+  },
+  "shell": {
+    "scripts": {
+      "test": "go test ./...",
+      "deploy": "echo dev"
+    }
+  },
+  "environments": {
+    "staging": {
+      "env": {"DB_HOST": "staging.internal"},
+      "env_from": "staging.env",
+      "packages": {"go": "1.22", "awscli2": "latest"},
+      "exclude_packages": ["postgresql"],
+      "scripts": {"deploy": "echo staging"}
+    }
+  }
+}`
+	if err := os.WriteFile(filepath.Join(dir, configfile.DefaultName), []byte(cfgJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+
+	t.Run("Unselected", func(t *testing.T) {
+		cfg.SelectEnvironment("dev")
+		got := lo.Map(cfg.Packages(false), func(p configfile.Package, _ int) string { return p.VersionedName() })
+		want := []string{"go@1.21", "hello@latest", "postgresql@15"}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Packages() mismatch (-want +got):\n%s", diff)
+		}
+		if got := cfg.Env()["DB_HOST"]; got != "localhost" {
+			t.Errorf("Env()[DB_HOST] = %q, want %q", got, "localhost")
+		}
+		if got := cfg.EnvFrom().String(); got != "" {
+			t.Errorf("EnvFrom() = %q, want empty", got)
+		}
+	})
+	t.Run("Staging", func(t *testing.T) {
+		cfg.SelectEnvironment("staging")
+		got := lo.Map(cfg.Packages(false), func(p configfile.Package, _ int) string { return p.VersionedName() })
+		want := []string{"hello@latest", "go@1.22", "awscli2@latest"}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Packages() mismatch (-want +got):\n%s", diff)
+		}
+		wantEnv := map[string]string{"DB_HOST": "staging.internal", "LOG_LEVEL": "debug"}
+		if diff := cmp.Diff(wantEnv, cfg.Env()); diff != "" {
+			t.Errorf("Env() mismatch (-want +got):\n%s", diff)
+		}
+		if got := cfg.EnvFrom().String(); got != "staging.env" {
+			t.Errorf("EnvFrom() = %q, want %q", got, "staging.env")
+		}
+		scripts := cfg.Scripts()
+		if got := scripts["deploy"].String(); got != "echo staging" {
+			t.Errorf("Scripts()[deploy] = %q, want %q", got, "echo staging")
+		}
+		if got := scripts["test"].String(); got != "go test ./..." {
+			t.Errorf("Scripts()[test] = %q, want %q", got, "go test ./...")
+		}
+	})
+	t.Run("HashChanges", func(t *testing.T) {
+		cfg.SelectEnvironment("dev")
+		devHash, err := cfg.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.SelectEnvironment("staging")
+		stagingHash, err := cfg.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if devHash == stagingHash {
+			t.Error("Hash() is the same for dev and staging environments, want different")
+		}
+	})
+}
+
+func TestEnvironmentsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"whitespace name": `{"environments": {"my env": {}}}`,
+		"empty script":    `{"environments": {"ci": {"scripts": {"test": ""}}}}`,
+	}
+	for name, cfgJSON := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(
+				filepath.Join(dir, configfile.DefaultName), []byte(cfgJSON), 0o644,
+			); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Open(dir); err == nil {
+				t.Errorf("Open(%q) succeeded, want validation error", cfgJSON)
+			}
+		})
+	}
+}
+
+func TestSelectGroups(t *testing.T) {
+	dir := t.TempDir()
+	cfgJSON := `{
+  "packages": {"go": "1.21", "python": "3.11"},
+  "groups": {
+    "docs": {"packages": ["mdbook@latest"]},
+    "ml": {"packages": {"python": "3.12", "cudatoolkit": "latest"}}
+  }
+}`
+	if err := os.WriteFile(filepath.Join(dir, configfile.DefaultName), []byte(cfgJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+
+	packageNames := func() []string {
+		return lo.Map(cfg.Packages(false), func(p configfile.Package, _ int) string { return p.VersionedName() })
+	}
+	noGroupsHash, err := cfg.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"go@1.21", "python@3.11"}, packageNames()); diff != "" {
+		t.Errorf("Packages() without groups mismatch (-want +got):\n%s", diff)
+	}
+
+	cfg.SelectGroups([]string{"ml", "docs"})
+	want := []string{"go@1.21", "mdbook@latest", "python@3.12", "cudatoolkit@latest"}
+	if diff := cmp.Diff(want, packageNames()); diff != "" {
+		t.Errorf("Packages() with groups mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"docs", "ml"}, cfg.SelectedGroups()); diff != "" {
+		t.Errorf("SelectedGroups() mismatch (-want +got):\n%s", diff)
+	}
+	groupsHash, err := cfg.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groupsHash == noGroupsHash {
+		t.Error("Hash() is the same with and without groups, want different")
+	}
+
+	all := lo.Map(cfg.AllGroupPackages(), func(p configfile.Package, _ int) string { return p.VersionedName() })
+	if diff := cmp.Diff([]string{"mdbook@latest", "python@3.12", "cudatoolkit@latest"}, all); diff != "" {
+		t.Errorf("AllGroupPackages() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLocalConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfgJSON := `{
+  "packages": {"go": "1.21", "python": "3.11"},
+  "env": {"EDITOR": "vi", "LOG_LEVEL": "info"},
+  "shell": {"scripts": {"test": "go test ./..."}}
+}`
+	localJSON := `{
+  // My tools.
+  "packages": ["ripgrep@latest", "python@3.12"],
+  "env": {"EDITOR": "hx"},
+  "shell": {"scripts": {"grep": "rg TODO"}}
+}`
+	if err := os.WriteFile(filepath.Join(dir, configfile.DefaultName), []byte(cfgJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	sharedHash, err := cfg.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, configfile.LocalName), []byte(localJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = Open(dir)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+
+	got := lo.Map(cfg.Packages(false), func(p configfile.Package, _ int) string { return p.VersionedName() })
+	want := []string{"go@1.21", "ripgrep@latest", "python@3.12"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Packages() mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want[1:], cfg.LocalPackageNames()); diff != "" {
+		t.Errorf("LocalPackageNames() mismatch (-want +got):\n%s", diff)
+	}
+	wantEnv := map[string]string{"EDITOR": "hx", "LOG_LEVEL": "info"}
+	if diff := cmp.Diff(wantEnv, cfg.Env()); diff != "" {
+		t.Errorf("Env() mismatch (-want +got):\n%s", diff)
+	}
+	scripts := cfg.Scripts()
+	if scripts["test"] == nil || scripts["grep"] == nil {
+		t.Errorf("Scripts() = %v, want test and grep", lo.Keys(scripts))
+	}
+	localHash, err := cfg.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if localHash == sharedHash {
+		t.Error("Hash() didn't change after adding devbox.local.json")
+	}
+	// The shared config is unchanged.
+	if got := len(cfg.Root.TopLevelPackages()); got != 2 {
+		t.Errorf("got %d packages in devbox.json, want 2", got)
+	}
+}
+
+func TestLocalConfigInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, configfile.DefaultName), []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	localJSON := `{"include": ["plugin:nginx"]}`
+	if err := os.WriteFile(filepath.Join(dir, configfile.LocalName), []byte(localJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir); err == nil {
+		t.Errorf("Open succeeded with %s, want error", localJSON)
+	}
+}
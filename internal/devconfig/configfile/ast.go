@@ -219,31 +219,76 @@ func (c *configAST) appendAllowInsecure(name, fieldName string, whitelist []stri
 
 // removePatch removes the patch field from the named package.
 func (c *configAST) removePatch(name string) {
+	c.removePackageField(name, "patch")
+}
+
+// removePackageField removes a field from the named package. It returns false
+// if the package isn't an object or doesn't have the field.
+func (c *configAST) removePackageField(name, fieldName string) bool {
 	pkgs := c.packagesField(false)
 	obj, ok := pkgs.Value.Value.(*hujson.Object)
 	if !ok {
 		// Packages field is an array.
-		return
+		return false
 	}
 	i := c.memberIndex(obj, name)
 	if i == -1 {
 		// Package not found.
-		return
+		return false
 	}
 
 	obj, ok = obj.Members[i].Value.Value.(*hujson.Object)
 	if !ok {
 		// Package is a string, not an object.
-		return
+		return false
 	}
-	i = c.memberIndex(obj, "patch")
+	i = c.memberIndex(obj, fieldName)
 	if i == -1 {
-		// Patch field doesn't exist.
-		return
+		// Field doesn't exist.
+		return false
 	}
 
 	obj.Members = slices.Delete(obj.Members, i, i+1)
 	c.root.Format()
+	return true
+}
+
+// setPackageVersion sets the version of the named package without changing
+// the format of the packages field.
+func (c *configAST) setPackageVersion(name, version string) {
+	switch pkgs := c.packagesField(false).Value.Value.(type) {
+	case *hujson.Array:
+		i := c.packageElementIndex(pkgs, name)
+		if i == -1 {
+			return
+		}
+		pkgs.Elements[i].Value = hujson.String(joinNameVersion(name, version))
+	case *hujson.Object:
+		i := c.memberIndex(pkgs, name)
+		if i == -1 {
+			return
+		}
+		pkg := &pkgs.Members[i].Value
+		obj, ok := pkg.Value.(*hujson.Object)
+		if !ok {
+			pkg.Value = hujson.String(version)
+			return
+		}
+		if j := c.memberIndex(obj, "version"); j != -1 {
+			obj.Members[j].Value.Value = hujson.String(version)
+			return
+		}
+		obj.Members = slices.Insert(obj.Members, 0, hujson.ObjectMember{
+			Name: hujson.Value{
+				Value:       hujson.String("version"),
+				BeforeExtra: []byte{'\n'},
+			},
+			Value: hujson.Value{Value: hujson.String(version)},
+		})
+		c.root.Format()
+	default:
+		panic("packages field must be an object or array")
+	}
 }
 
 // setPatch sets the patch field of the named package.
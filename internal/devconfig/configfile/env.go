@@ -1,50 +1,226 @@
 package configfile
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/hashicorp/go-envparse"
+	"github.com/pkg/errors"
+	"go.jetify.com/devbox/internal/boxcli/usererr"
+	"go.jetify.com/devbox/internal/cuecfg"
 )
 
+// EnvFrom is a list of sources to load env variables from. Sources are applied
+// in order, so variables from later sources override earlier ones. In
+// devbox.json it can be a single path, or a list of paths and objects:
+//
+//	"env_from": "defaults.env"
+//	"env_from": ["defaults.env", {"path": "local.env", "optional": true}]
+//	"env_from": ["defaults.env", "exec:sops -d secrets.enc.yaml"]
+type EnvFrom []EnvFromSource
+
+// EnvFromSource is a file or command to load env variables from.
+type EnvFromSource struct {
+	// Path is the path to a .env, JSON, YAML or TOML file. A relative path
+	// is relative to the directory containing devbox.json. A path that
+	// starts with "exec:" is a shell command instead, and its output is
+	// parsed as a .env, JSON or YAML file.
+	Path string `json:"path"`
+
+	// Optional skips the source when its file doesn't exist (or its command
+	// fails) instead of returning an error.
+	Optional bool `json:"optional,omitempty"`
+}
+
+// execPrefix is the prefix of env_from sources that run a command.
+const execPrefix = "exec:"
+
+// ExecEnvFunc runs the command of an "exec:" env_from source and returns the
+// env variables it outputs.
+type ExecEnvFunc func(command string) (map[string]string, error)
+
+// envFromExtensions are the file extensions that env_from can load.
+var envFromExtensions = []string{".env", ".json", ".yaml", ".yml", ".toml"}
+
+func (e *EnvFrom) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*e = EnvFrom{{Path: path}}
+		return nil
+	}
+
+	var sources []json.RawMessage
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return err
+	}
+	*e = make(EnvFrom, len(sources))
+	for i, raw := range sources {
+		if err := json.Unmarshal(raw, &(*e)[i].Path); err == nil {
+			continue
+		}
+		if err := json.Unmarshal(raw, &(*e)[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the paths of the sources separated by commas.
+func (e EnvFrom) String() string {
+	paths := make([]string, len(e))
+	for i, source := range e {
+		paths[i] = source.Path
+	}
+	return strings.Join(paths, ", ")
+}
+
 // IsJetifyCloudEnvFrom reports whether env_from points at Jetify Cloud
 // secrets. That feature has been removed, but configs in the wild still set it,
 // so we recognize the value in order to ignore it with a warning rather than
 // fail on it.
 func (c *ConfigFile) IsJetifyCloudEnvFrom() bool {
+	return IsJetifyCloudEnvFrom(c.EnvFrom)
+}
+
+// IsJetifyCloudEnvFrom is like [ConfigFile.IsJetifyCloudEnvFrom] but checks an
+// arbitrary env_from value, such as one set by an environment.
+func IsJetifyCloudEnvFrom(envFrom EnvFrom) bool {
+	return slices.ContainsFunc(envFrom, func(s EnvFromSource) bool {
+		return s.isJetifyCloud()
+	})
+}
+
+// Command returns the command of an "exec:" source.
+func (s EnvFromSource) Command() (string, bool) {
+	command, ok := strings.CutPrefix(s.Path, execPrefix)
+	return strings.TrimSpace(command), ok
+}
+
+func (s EnvFromSource) isJetifyCloud() bool {
 	// envsec and jetpack-cloud are legacy spellings of jetify-cloud.
-	return c.EnvFrom == "envsec" || c.EnvFrom == "jetpack-cloud" || c.EnvFrom == "jetify-cloud"
+	return s.Path == "envsec" || s.Path == "jetpack-cloud" || s.Path == "jetify-cloud"
 }
 
-func (c *ConfigFile) IsdotEnvEnabled() bool {
-	// filename has to end with .env
-	return filepath.Ext(c.EnvFrom) == ".env"
+// ParseEnvFrom loads the env variables from each source in envFrom, with later
+// sources overriding earlier ones. Relative paths are resolved against the
+// directory containing the config file. Jetify Cloud sources are skipped.
+// Commands are run with execEnv, and are an error if execEnv is nil.
+func (c *ConfigFile) ParseEnvFrom(envFrom EnvFrom, execEnv ExecEnvFunc) (map[string]string, error) {
+	env := map[string]string{}
+	for _, source := range envFrom {
+		if source.isJetifyCloud() {
+			continue
+		}
+		if command, ok := source.Command(); ok {
+			sourceEnv, err := execEnvFrom(command, execEnv)
+			if err != nil {
+				if source.Optional {
+					continue
+				}
+				return nil, err
+			}
+			for k, v := range sourceEnv {
+				env[k] = v
+			}
+			continue
+		}
+		path := source.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(c.AbsRootPath), path)
+		}
+		sourceEnv, err := parseEnvFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			if source.Optional {
+				continue
+			}
+			return nil, usererr.New(
+				"env_from file %s does not exist. Set \"optional\": true on the "+
+					"source to skip it when it's missing.",
+				source.Path,
+			)
+		}
+		if err != nil {
+			return nil, usererr.New("failed parsing env_from file %s. Error: %v", source.Path, err)
+		}
+		for k, v := range sourceEnv {
+			env[k] = v
+		}
+	}
+	return env, nil
 }
 
-func (c *ConfigFile) ParseEnvsFromDotEnv() (map[string]string, error) {
-	// This check should never happen because we call IsdotEnvEnabled
-	// before calling this method. But having it makes it more robust
-	// in case if anyone uses this method without the IsdotEnvEnabled
-	if !c.IsdotEnvEnabled() {
-		return nil, fmt.Errorf("env file does not have a .env extension")
+func execEnvFrom(command string, execEnv ExecEnvFunc) (map[string]string, error) {
+	if command == "" {
+		return nil, usererr.New("env_from source %q is missing a command.", execPrefix)
+	}
+	if execEnv == nil {
+		return nil, errors.Errorf("can't run env_from command %q", command)
 	}
-	envFileAbsPath := c.EnvFrom
-	if !filepath.IsAbs(c.EnvFrom) {
-		envFileAbsPath = filepath.Join(filepath.Dir(c.AbsRootPath), c.EnvFrom)
+	return execEnv(command)
+}
+
+func parseEnvFile(path string) (map[string]string, error) {
+	ext := filepath.Ext(path)
+	if !slices.Contains(envFromExtensions, ext) {
+		return nil, fmt.Errorf("unsupported file type %q (must be one of %s)",
+			ext, strings.Join(envFromExtensions, ", "))
 	}
-	file, err := os.Open(envFileAbsPath)
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %s", envFileAbsPath)
+		return nil, err
+	}
+	if ext == ".env" {
+		return envparse.Parse(strings.NewReader(string(data)))
 	}
-	defer file.Close()
 
-	envMap, err := envparse.Parse(file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse env file: %v", err)
+	values := map[string]any{}
+	if err := cuecfg.Unmarshal(data, ext, &values); err != nil {
+		return nil, err
+	}
+	return envFromValues(values)
+}
+
+// ParseEnvOutput parses the output of an env_from command. The output can be
+// in .env format (KEY=VALUE lines) or a JSON or YAML object.
+func ParseEnvOutput(data []byte) (map[string]string, error) {
+	env, envErr := envparse.Parse(bytes.NewReader(data))
+	if envErr == nil {
+		return env, nil
+	}
+	// YAML is a superset of JSON, so this handles both.
+	values := map[string]any{}
+	if err := cuecfg.Unmarshal(data, ".yaml", &values); err != nil {
+		return nil, fmt.Errorf("output is not in .env, JSON or YAML format: %v", envErr)
 	}
+	return envFromValues(values)
+}
 
-	return envMap, nil
+func envFromValues(values map[string]any) (map[string]string, error) {
+	env := make(map[string]string, len(values))
+	for k, v := range values {
+		switch v := v.(type) {
+		case string:
+			env[k] = v
+		case float64:
+			// JSON numbers are float64, which fmt formats with an
+			// exponent when they're large.
+			env[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool, int, int64, uint64:
+			env[k] = fmt.Sprint(v)
+		case nil:
+			env[k] = ""
+		default:
+			return nil, fmt.Errorf("value of %s must be a string, number or boolean", k)
+		}
+	}
+	return env, nil
 }
 
 func (c *ConfigFile) SetEnv(env map[string]string) {
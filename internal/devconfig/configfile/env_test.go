@@ -0,0 +1,142 @@
+package configfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseEnvFrom(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"defaults.env": "DB_HOST=localhost\nDB_PORT=5432\nLOG_LEVEL=info\n",
+		"shared.json":  `{"DB_HOST": "db.internal", "DEBUG": true, "MAX_ROWS": 12345678, "RATIO": 0.25}`,
+		"team.yaml":    "LOG_LEVEL: debug\nWORKERS: 4\nCACHE_BYTES: 1000000\n",
+		"local.toml":   "DB_PORT = \"6543\"\nTIMEOUT_MS = 1000000\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := LoadBytes([]byte(`{
+  "env_from": [
+    "defaults.env",
+    "shared.json",
+    {"path": "team.yaml"},
+    {"path": "local.toml", "optional": true},
+    {"path": "missing.env", "optional": true}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.AbsRootPath = filepath.Join(dir, DefaultName)
+
+	got, err := cfg.ParseEnvFrom(cfg.EnvFrom, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"DB_HOST":     "db.internal",
+		"DB_PORT":     "6543",
+		"DEBUG":       "true",
+		"LOG_LEVEL":   "debug",
+		"WORKERS":     "4",
+		"MAX_ROWS":    "12345678",
+		"RATIO":       "0.25",
+		"CACHE_BYTES": "1000000",
+		"TIMEOUT_MS":  "1000000",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong env (-want +got):\n%s", diff)
+	}
+
+	for _, envFrom := range []EnvFrom{
+		{{Path: "missing.env"}},
+		{{Path: "defaults.txt"}},
+	} {
+		if _, err := cfg.ParseEnvFrom(envFrom, nil); err == nil {
+			t.Errorf("ParseEnvFrom(%s) returned nil error", envFrom)
+		}
+	}
+}
+
+func TestParseEnvFromExec(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`{
+  "env_from": [
+    {"path": "exec:fail", "optional": true},
+    "exec: sops -d secrets.enc.yaml"
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var commands []string
+	execEnv := func(command string) (map[string]string, error) {
+		commands = append(commands, command)
+		if command == "fail" {
+			return nil, errors.New("command failed")
+		}
+		return map[string]string{"API_TOKEN": "secret"}, nil
+	}
+	got, err := cfg.ParseEnvFrom(cfg.EnvFrom, execEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]string{"API_TOKEN": "secret"}, got); diff != "" {
+		t.Errorf("wrong env (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"fail", "sops -d secrets.enc.yaml"}, commands); diff != "" {
+		t.Errorf("wrong commands (-want +got):\n%s", diff)
+	}
+
+	for _, envFrom := range []EnvFrom{
+		{{Path: "exec:fail"}},
+		{{Path: "exec:"}},
+	} {
+		if _, err := cfg.ParseEnvFrom(envFrom, execEnv); err == nil {
+			t.Errorf("ParseEnvFrom(%s) returned nil error", envFrom)
+		}
+	}
+}
+
+func TestParseEnvOutput(t *testing.T) {
+	tests := map[string]string{
+		"dotenv": "API_TOKEN=secret\nexport PORT=8080\n",
+		"json":   `{"API_TOKEN": "secret", "PORT": 8080}`,
+		"yaml":   "API_TOKEN: secret\nPORT: 8080\n",
+	}
+	want := map[string]string{"API_TOKEN": "secret", "PORT": "8080"}
+	for name, output := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseEnvOutput([]byte(output))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("wrong env (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := ParseEnvOutput([]byte("not env")); err == nil {
+		t.Error("ParseEnvOutput returned nil error for invalid output")
+	}
+}
+
+func TestUnmarshalEnvFrom(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`{"env_from": "defaults.env"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := EnvFrom{{Path: "defaults.env"}}
+	if diff := cmp.Diff(want, cfg.EnvFrom); diff != "" {
+		t.Errorf("wrong env_from (-want +got):\n%s", diff)
+	}
+}
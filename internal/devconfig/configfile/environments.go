@@ -0,0 +1,134 @@
+package configfile
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// EnvironmentConfig overrides parts of a devbox.json when devbox runs with
+// --environment set to the environment's name. The overrides are merged on
+// top of the base config by devconfig.Config.
+type EnvironmentConfig struct {
+	// Env is merged over the base config's env. Keys set here win.
+	Env map[string]string `json:"env,omitempty"`
+
+	// EnvFrom replaces the base config's env_from when it's set.
+	EnvFrom EnvFrom `json:"env_from,omitempty"`
+
+	// PackagesMutator contains packages that are added to the base config's
+	// packages. A package with the same name as a base package replaces it.
+	// It uses the same formats as the top-level packages field.
+	PackagesMutator PackagesMutator `json:"packages"`
+
+	// ExcludePackages is a list of package names (without versions) that
+	// are removed from the base config's packages.
+	ExcludePackages []string `json:"exclude_packages,omitempty"`
+
+	// Scripts are merged over the base config's scripts.
+	Scripts map[string]*ScriptConfig `json:"scripts,omitempty"`
+}
+
+// EnvironmentNames returns the sorted names of the environments defined in
+// the config.
+func (c *ConfigFile) EnvironmentNames() []string {
+	if c == nil {
+		return nil
+	}
+	names := make([]string, 0, len(c.Environments))
+	for name := range c.Environments {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Environment returns the environment with the given name, or nil if the
+// config doesn't define it.
+func (c *ConfigFile) Environment(name string) *EnvironmentConfig {
+	if c == nil {
+		return nil
+	}
+	return c.Environments[name]
+}
+
+// EnvironmentScripts returns the scripts that the named environment adds or
+// overrides. It does not include the base config's scripts.
+func (c *ConfigFile) EnvironmentScripts(name string) Scripts {
+	env := c.Environment(name)
+	if env == nil {
+		return nil
+	}
+	result := make(Scripts, len(env.Scripts))
+	for scriptName, config := range env.Scripts {
+		comments := ""
+		if c.ast != nil {
+			comments = string(c.ast.beforeComment("environments", name, "scripts", scriptName))
+		}
+		result[scriptName] = &script{
+			ScriptConfig: *config,
+			Comments:     comments,
+		}
+	}
+	return result
+}
+
+// Packages returns the packages that the environment adds to the base config.
+func (e *EnvironmentConfig) Packages() []Package {
+	if e == nil {
+		return nil
+	}
+	return e.PackagesMutator.collection
+}
+
+// ApplyToPackages returns base with the environment's excluded packages
+// removed and its packages added. Packages in the environment replace base
+// packages with the same name.
+func (e *EnvironmentConfig) ApplyToPackages(base []Package) []Package {
+	if e == nil {
+		return base
+	}
+	result := slices.DeleteFunc(slices.Clone(base), func(p Package) bool {
+		if slices.Contains(e.ExcludePackages, p.Name) {
+			return true
+		}
+		return slices.ContainsFunc(e.Packages(), func(o Package) bool {
+			return o.Name == p.Name
+		})
+	})
+	return append(result, e.Packages()...)
+}
+
+func validateEnvironments(cfg *ConfigFile) error {
+	for name, env := range cfg.Environments {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("cannot have environment with empty name in devbox.json")
+		}
+		if whitespace.MatchString(name) {
+			return errors.Errorf(
+				"cannot have environment name with whitespace in devbox.json: %s", name)
+		}
+		if env == nil {
+			continue
+		}
+		for k, script := range env.Scripts {
+			if strings.TrimSpace(k) == "" {
+				return errors.Errorf(
+					"cannot have script with empty name in environment %s in devbox.json", name)
+			}
+			if whitespace.MatchString(k) {
+				return errors.Errorf(
+					"cannot have script name with whitespace in environment %s in devbox.json: %s", name, k)
+			}
+			if script == nil || strings.TrimSpace(script.String()) == "" && len(script.DependsOn) == 0 {
+				return errors.Errorf(
+					"cannot have an empty script body in environment %s in devbox.json: %s", name, k)
+			}
+			if err := validateScript(k, script); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
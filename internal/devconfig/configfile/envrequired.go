@@ -0,0 +1,66 @@
+package configfile
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RequiredEnv describes an env variable that must be set for devbox shell and
+// devbox run to start. In devbox.json it's either an object or a string with
+// only the description.
+type RequiredEnv struct {
+	// Description says what the variable is for and how to get it. It's
+	// shown when the variable is missing.
+	Description string `json:"description,omitempty"`
+
+	// Pattern is an optional regular expression that the value must match.
+	// It's not anchored, so use ^ and $ to match the whole value.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// requiredEnvObject is the object form of a RequiredEnv. It doesn't have
+// RequiredEnv's UnmarshalJSON method.
+type requiredEnvObject RequiredEnv
+
+func (r *RequiredEnv) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		*r = RequiredEnv{}
+		return json.Unmarshal(data, &r.Description)
+	}
+	return json.Unmarshal(data, (*requiredEnvObject)(r))
+}
+
+// Check returns a description of the problem with value, or an empty string
+// if it's a valid value for the variable.
+func (r *RequiredEnv) Check(value string) string {
+	if value == "" {
+		return "not set"
+	}
+	if r == nil || r.Pattern == "" {
+		return ""
+	}
+	// Patterns are checked when the config is loaded.
+	if re, err := regexp.Compile(r.Pattern); err == nil && !re.MatchString(value) {
+		return "doesn't match " + r.Pattern
+	}
+	return ""
+}
+
+func validateEnvRequired(cfg *ConfigFile) error {
+	for name, required := range cfg.EnvRequired {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, "= \t\n") {
+			return errors.Errorf("invalid env variable name in env_required in devbox.json: %q", name)
+		}
+		if required == nil || required.Pattern == "" {
+			continue
+		}
+		if _, err := regexp.Compile(required.Pattern); err != nil {
+			return errors.Errorf(
+				"invalid pattern for %s in env_required in devbox.json: %v", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package configfile
+
+import (
+	"testing"
+)
+
+func TestEnvRequired(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`{
+  "env_required": {
+    "API_KEY": "Create one at https://example.com/keys",
+    "PORT": {"description": "Port of the dev server.", "pattern": "^[0-9]+$"}
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cfg.EnvRequired["API_KEY"].Description; got != "Create one at https://example.com/keys" {
+		t.Errorf("got API_KEY description %q", got)
+	}
+	tests := []struct {
+		name, value, want string
+	}{
+		{"API_KEY", "", "not set"},
+		{"API_KEY", "secret", ""},
+		{"PORT", "8080", ""},
+		{"PORT", "http", "doesn't match ^[0-9]+$"},
+	}
+	for _, test := range tests {
+		if got := cfg.EnvRequired[test.name].Check(test.value); got != test.want {
+			t.Errorf("%s.Check(%q) = %q, want %q", test.name, test.value, got, test.want)
+		}
+	}
+	if diags := cfg.Validate(); len(diags) > 0 {
+		t.Errorf("got diagnostics for valid env_required: %v", diags)
+	}
+}
+
+func TestEnvRequiredInvalid(t *testing.T) {
+	for _, config := range []string{
+		`{"env_required": {"PORT": {"pattern": "[0-9"}}}`,
+		`{"env_required": {"MY VAR": "has a space"}}`,
+	} {
+		if _, err := LoadBytes([]byte(config)); err == nil {
+			t.Errorf("LoadBytes(%s) succeeded, want error", config)
+		}
+	}
+}
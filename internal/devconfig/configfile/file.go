@@ -29,6 +29,9 @@ type ConfigFile struct {
 	// it will not be set for github plugins.
 	AbsRootPath string `json:"-"`
 
+	// Schema is the URL of the JSON schema for editors. Devbox ignores it.
+	Schema string `json:"$schema,omitempty"`
+
 	Name        string `json:"name,omitempty"`
 	Description string `json:"description,omitempty"`
 
@@ -39,8 +42,12 @@ type ConfigFile struct {
 	// Env allows specifying env variables
 	Env map[string]string `json:"env,omitempty"`
 
-	// EnvFrom is the path to a .env file to load env variables from.
-	EnvFrom string `json:"env_from,omitempty"`
+	// EnvFrom lists the files to load env variables from.
+	EnvFrom EnvFrom `json:"env_from,omitempty"`
+
+	// EnvRequired lists the env variables that must be set, from any
+	// source, before devbox shell or devbox run starts.
+	EnvRequired map[string]*RequiredEnv `json:"env_required,omitempty"`
 
 	// Shell configures the devbox shell environment.
 	Shell *shellConfig `json:"shell,omitempty"`
@@ -55,20 +62,34 @@ type ConfigFile struct {
 	// Deprecated: Versioned packages don't need this
 	Nixpkgs *NixpkgsConfig `json:"nixpkgs,omitempty"`
 
-	// Reserved to allow including other config files. Proposed format is:
+	// Include lists other config files (plugins) to include. The format is
+	// similar to nix inputs:
 	// path: for local files
-	// https:// for remote files
+	// github: and git+ for plugins in git repositories
+	// https:// (or file+https://, tarball+https://) for remote files. Their
+	// content hash is pinned in devbox.lock.
 	// plugin: for built-in plugins
-	// This is a similar format to nix inputs
 	Include []string `json:"include,omitempty"`
 
+	// Environments contains named overlays that are merged on top of this
+	// config when devbox runs with --environment set to their name. Only the
+	// root devbox.json's environments are used; plugins can't define them.
+	Environments map[string]*EnvironmentConfig `json:"environments,omitempty"`
+
+	// Groups contains named sets of optional packages. They're locked but
+	// only installed when devbox runs with --with set to their name.
+	Groups map[string]*GroupConfig `json:"groups,omitempty"`
+
 	ast *configAST
+
+	// doc is the original YAML or TOML document. It's nil for JSON configs.
+	doc *document
 }
 
 type shellConfig struct {
 	// InitHook contains commands that will run at shell startup.
-	InitHook *shellcmd.Commands            `json:"init_hook,omitempty"`
-	Scripts  map[string]*shellcmd.Commands `json:"scripts,omitempty"`
+	InitHook *shellcmd.Commands       `json:"init_hook,omitempty"`
+	Scripts  map[string]*ScriptConfig `json:"scripts,omitempty"`
 }
 
 type NixpkgsConfig struct {
@@ -80,9 +101,24 @@ type Stage struct {
 	Command string `json:"command"`
 }
 
-func (c *ConfigFile) Bytes() []byte {
+// Bytes returns the config encoded in the format of the file that it was
+// loaded from.
+func (c *ConfigFile) Bytes() ([]byte, error) {
+	if c.doc != nil {
+		root := c.ast.root.Clone()
+		root.Standardize()
+		return c.doc.encode(root.Pack())
+	}
 	b := c.ast.root.Pack()
-	return bytes.ReplaceAll(b, []byte("\t"), []byte("  "))
+	return bytes.ReplaceAll(b, []byte("\t"), []byte("  ")), nil
+}
+
+// FileName returns the name of the file that the config is saved to.
+func (c *ConfigFile) FileName() string {
+	if c.doc == nil {
+		return DefaultName
+	}
+	return c.doc.name
 }
 
 func (c *ConfigFile) Hash() (string, error) {
@@ -116,7 +152,11 @@ func (c *ConfigFile) InitHook() *shellcmd.Commands {
 
 // SaveTo writes the config to a file.
 func (c *ConfigFile) SaveTo(path string) error {
-	return os.WriteFile(filepath.Join(path, DefaultName), c.Bytes(), 0o644)
+	b, err := c.Bytes()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(path, c.FileName()), b, 0o644)
 }
 
 // TODO: Can we remove SaveTo and just use Save()?
@@ -155,16 +195,46 @@ func LoadBytes(b []byte) (*ConfigFile, error) {
 		ast:             ast,
 	}
 	if err := json.Unmarshal(jsonb, cfg); err != nil {
+		// Report where the problem is instead of the json package's
+		// error, which only has the Go field name.
+		if diags, _ := Validate(b, ConfigSchema()); len(diags) > 0 {
+			return nil, &ValidationError{Name: DefaultName, Diagnostics: diags}
+		}
 		return nil, err
 	}
 	return cfg, validateConfig(cfg)
 }
 
+// LoadNamedBytes loads a config from b, using the extension of name to pick
+// between JSON, YAML and TOML. Validation errors report positions in b.
+func LoadNamedBytes(name string, b []byte) (*ConfigFile, error) {
+	if formatOf(name) == formatJSON {
+		return LoadBytes(b)
+	}
+	doc, jsonb, err := parseDocument(name, b)
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "Failed to parse %s.", filepath.Base(name))
+	}
+	cfg, err := LoadBytes(jsonb)
+	if validationErr := (&ValidationError{}); errors.As(err, &validationErr) {
+		validationErr.Name = doc.name
+		doc.remapDiagnostics(validationErr.Diagnostics)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg.doc = doc
+	return cfg, nil
+}
+
 func validateConfig(cfg *ConfigFile) error {
 	fns := []func(cfg *ConfigFile) error{
 		ValidateNixpkg,
 		validateScripts,
 		validateAliases,
+		validateEnvironments,
+		validateGroups,
+		validateEnvRequired,
 	}
 
 	for _, fn := range fns {
@@ -179,7 +249,7 @@ func validateConfig(cfg *ConfigFile) error {
 
 func validateScripts(cfg *ConfigFile) error {
 	scripts := cfg.Scripts()
-	for k := range scripts {
+	for k, s := range scripts {
 		if strings.TrimSpace(k) == "" {
 			return errors.New("cannot have script with empty name in devbox.json")
 		}
@@ -187,9 +257,8 @@ func validateScripts(cfg *ConfigFile) error {
 			return errors.Errorf(
 				"cannot have script name with whitespace in devbox.json: %s", k)
 		}
-		if strings.TrimSpace(scripts[k].String()) == "" {
-			return errors.Errorf(
-				"cannot have an empty script body in devbox.json: %s", k)
+		if err := validateScript(k, &s.ScriptConfig); err != nil {
+			return err
 		}
 	}
 	return nil
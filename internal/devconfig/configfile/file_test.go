@@ -50,6 +50,17 @@ func parseConfigTxtarTest(t *testing.T, test string) (in *ConfigFile, want []byt
 	return in, want
 }
 
+// configBytes returns c.Bytes() and fails the test if it returns an error.
+func configBytes(t *testing.T, c *ConfigFile) []byte {
+	t.Helper()
+
+	b, err := c.Bytes()
+	if err != nil {
+		t.Fatalf("got error encoding config: %v", err)
+	}
+	return b
+}
+
 func optBytesToStrings() cmp.Option {
 	return cmp.Transformer("bytesToStrings", func(b []byte) string {
 		return string(b)
@@ -78,10 +89,10 @@ func TestNoChanges(t *testing.T) {
 -- want --
 { "packages": { "go": "latest" } }`)
 
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -98,10 +109,10 @@ func TestAddPackageEmptyConfig(t *testing.T) {
 }`)
 
 	in.PackagesMutator.Add("go@latest")
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -120,10 +131,10 @@ func TestAddPackageEmptyConfigWhitespace(t *testing.T) {
 }`)
 
 	in.PackagesMutator.Add("go@latest")
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -142,10 +153,10 @@ func TestAddPackageEmptyConfigComment(t *testing.T) {
 }`)
 
 	in.PackagesMutator.Add("go@latest")
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -162,10 +173,10 @@ func TestAddPackageNull(t *testing.T) {
 }`)
 
 	in.PackagesMutator.Add("go@latest")
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -187,10 +198,10 @@ func TestAddPackageObject(t *testing.T) {
 }`)
 
 	in.PackagesMutator.Add("python@3.10")
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -214,10 +225,10 @@ func TestAddPackageObjectComment(t *testing.T) {
 }`)
 
 	in.PackagesMutator.Add("python@3.10")
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -234,10 +245,10 @@ func TestAddPackageEmptyArray(t *testing.T) {
 }`)
 
 	in.PackagesMutator.Add("go@latest")
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -257,10 +268,10 @@ func TestAddPackageOneLineArray(t *testing.T) {
 }`)
 
 	in.PackagesMutator.Add("python@3.10")
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -282,10 +293,10 @@ func TestAddPackageMultiLineArray(t *testing.T) {
 }`)
 
 	in.PackagesMutator.Add("python@3.10")
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -315,10 +326,10 @@ func TestAddPackageArrayComments(t *testing.T) {
 }`)
 
 	in.PackagesMutator.Add("hello@latest")
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -340,10 +351,10 @@ func TestRemovePackageObject(t *testing.T) {
 }`)
 
 	in.PackagesMutator.Remove("go@latest")
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -364,10 +375,10 @@ func TestRemovePackageLastMember(t *testing.T) {
 }`)
 
 	in.PackagesMutator.Remove("go@latest")
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes(), optBytesToStrings()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optBytesToStrings()); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -384,10 +395,10 @@ func TestRemovePackageArray(t *testing.T) {
 }`)
 
 	in.PackagesMutator.Remove("go@latest")
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -410,10 +421,10 @@ func TestRemovePackageLastElement(t *testing.T) {
 }`)
 
 	in.PackagesMutator.Remove("go@latest")
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -477,10 +488,10 @@ func TestAddPlatforms(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -513,10 +524,10 @@ func TestAddPlatformsMigrateArray(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -550,10 +561,10 @@ func TestAddPlatformsMigrateArrayComments(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -582,10 +593,10 @@ func TestExcludePlatforms(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -614,10 +625,10 @@ func TestSetOutputs(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -644,10 +655,10 @@ func TestSetOutputsMigrateArray(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -676,10 +687,129 @@ func TestSetAllowInsecure(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
+		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
+		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
+	}
+}
+
+func TestSetVersionArray(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": [
+    // A comment.
+    "hello",
+    "go@1.22"
+  ]
+}
+-- want --
+{
+  "packages": [
+    // A comment.
+    "hello@latest",
+    "go@1.22"
+  ]
+}`)
+
+	if err := in.PackagesMutator.SetVersion("hello", "latest"); err != nil {
+		t.Error(err)
+	}
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
+		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
+		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
+	}
+}
+
+func TestSetVersionObject(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": {
+    "hello": "",
+    "python": {
+      "outputs": ["out"]
+    }
+  }
+}
+-- want --
+{
+  "packages": {
+    "hello": "latest",
+    "python": {
+      "version": "latest",
+      "outputs": ["out"]
+    }
+  }
+}`)
+
+	if err := in.PackagesMutator.SetVersion("hello", "latest"); err != nil {
+		t.Error(err)
+	}
+	if err := in.PackagesMutator.SetVersion("python", "latest"); err != nil {
+		t.Error(err)
+	}
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
+		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
+		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
+	}
+}
+
+func TestMigratePatchGlibc(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": {
+    "python": {
+      "version": "3.12",
+      // Needed for the wheels we build.
+      "patch_glibc": true
+    },
+    "ruby": {
+      "version":     "3.3",
+      "patch_glibc": false
+    },
+    "go": {
+      "version":     "1.22",
+      "patch_glibc": true,
+      "patch":       "never"
+    },
+    "hello": "latest"
+  }
+}
+-- want --
+{
+  "packages": {
+    "python": {
+      "version": "3.12",
+      // Needed for the wheels we build.
+      "patch": "always",
+    },
+    "ruby": {
+      "version": "3.3",
+    },
+    "go": {
+      "version": "1.22",
+      "patch":   "never",
+    },
+    "hello": "latest",
+  },
+}`)
+
+	migrated := in.PackagesMutator.MigratePatchGlibc()
+	if diff := cmp.Diff([]string{"python@3.12", "ruby@3.3", "go@1.22"}, migrated); diff != "" {
+		t.Errorf("wrong migrated packages (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -700,10 +830,10 @@ func TestSetEnv(t *testing.T) {
 		"FOO": "bar",
 		"BAZ": "qux",
 	})
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -726,10 +856,10 @@ func TestSetEnvExisting(t *testing.T) {
 	in.SetEnv(map[string]string{
 		"FOO": "bar",
 	})
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
@@ -748,10 +878,10 @@ func TestSetEnvClear(t *testing.T) {
 }`)
 
 	in.SetEnv(map[string]string{})
-	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
 		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
 	}
-	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
 		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
 	}
 }
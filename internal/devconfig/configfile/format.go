@@ -0,0 +1,373 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Names are the file names that devbox looks for when it searches a directory
+// for a config, in order of preference.
+var Names = []string{DefaultName, "devbox.yaml", "devbox.toml"}
+
+// IsConfigName reports whether name is the file name of a devbox config.
+func IsConfigName(name string) bool {
+	return slices.Contains(Names, name)
+}
+
+type format int
+
+const (
+	formatJSON format = iota
+	formatYAML
+	formatTOML
+)
+
+func formatOf(name string) format {
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".toml":
+		return formatTOML
+	default:
+		return formatJSON
+	}
+}
+
+// document is a devbox config in YAML or TOML format. Devbox edits every
+// config through the hujson AST of its JSON equivalent (see configAST), so a
+// document keeps the original syntax tree to write those edits back in its
+// own format.
+//
+// Both formats are kept as a yaml.Node tree because it preserves key order
+// and comments. When the config is saved, the tree is updated to match the
+// edited JSON (see syncNode). Nodes that are still in the config keep their
+// comments and style, so edits like `devbox add` only change the parts of the
+// file that they touch.
+type document struct {
+	name   string
+	format format
+	root   *yaml.Node
+
+	// tomlHeaders are the TOML tables that have a [table] header.
+	tomlHeaders map[*yaml.Node]bool
+}
+
+// parseDocument parses the YAML or TOML config b and returns it as a
+// document and as the equivalent JSON.
+func parseDocument(name string, b []byte) (*document, []byte, error) {
+	doc := &document{name: filepath.Base(name), format: formatOf(name)}
+	switch doc.format {
+	case formatYAML:
+		root := &yaml.Node{}
+		if err := yaml.Unmarshal(b, root); err != nil {
+			return nil, nil, err
+		}
+		if root.Kind == 0 {
+			// The file is empty.
+			root = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{emptyMapping()}}
+		}
+		doc.root = root.Content[0]
+	case formatTOML:
+		root, headers, err := parseTOML(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		doc.root, doc.tomlHeaders = root, headers
+	default:
+		return nil, nil, errors.Errorf("%s is not a YAML or TOML file", name)
+	}
+	if doc.root.Kind != yaml.MappingNode {
+		return nil, nil, errors.Errorf("%s must contain an object at the top level", doc.name)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeNodeJSON(buf, doc.root); err != nil {
+		return nil, nil, err
+	}
+	return doc, buf.Bytes(), nil
+}
+
+// encode updates the document to match the JSON config b and returns its
+// bytes.
+func (d *document) encode(b []byte) ([]byte, error) {
+	updated := &yaml.Node{}
+	if err := yaml.Unmarshal(b, updated); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	d.root = syncNode(d.root, updated.Content[0], d.format)
+
+	if d.format == formatTOML {
+		return encodeTOML(d.root, d.tomlHeaders), nil
+	}
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(d.root); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// position returns the line and column of the value at keys, which are in
+// the same format as the path of a [Diagnostic].
+func (d *document) position(keys []string) (line, column int, ok bool) {
+	node := d.root
+	for k, key := range keys {
+		switch node.Kind {
+		case yaml.MappingNode:
+			i := mappingIndex(node, key)
+			if i == -1 {
+				return 0, 0, false
+			}
+			if k == len(keys)-1 {
+				// Point at the key, which is where unknown fields are.
+				node = node.Content[i]
+			} else {
+				node = node.Content[i+1]
+			}
+		case yaml.SequenceNode:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node.Content) {
+				return 0, 0, false
+			}
+			node = node.Content[i]
+		default:
+			return 0, 0, false
+		}
+	}
+	return node.Line, node.Column, node.Line > 0
+}
+
+// remapDiagnostics changes the positions of diags, which are in the JSON
+// equivalent of the document, to their positions in the document itself.
+func (d *document) remapDiagnostics(diags []Diagnostic) {
+	for i := range diags {
+		keys, err := SplitPath(diags[i].Path)
+		if err != nil || diags[i].Path == "" {
+			keys = nil
+		}
+		line, column, ok := d.position(keys)
+		if !ok {
+			line, column = 1, 1
+		}
+		diags[i].Line, diags[i].Column = line, column
+	}
+}
+
+// syncNode updates old to have the same value as updated, keeping the
+// comments and style of the parts of old that didn't change. It returns the
+// updated node, which is old unless its kind changed.
+func syncNode(old, updated *yaml.Node, f format) *yaml.Node {
+	if old == nil || old.Kind != updated.Kind {
+		node := newNode(updated, f, false)
+		if old != nil {
+			node.HeadComment = old.HeadComment
+			node.LineComment = old.LineComment
+			node.FootComment = old.FootComment
+		}
+		return node
+	}
+
+	switch updated.Kind {
+	case yaml.ScalarNode:
+		if old.Value == updated.Value && isNumber(old) {
+			// The number was read as a string (see writeNodeJSON).
+			return old
+		}
+		if old.Tag != updated.Tag {
+			old.Tag = updated.Tag
+			old.Style = 0
+		}
+		old.Value = updated.Value
+	case yaml.MappingNode:
+		content := make([]*yaml.Node, 0, len(updated.Content))
+		for i := 0; i+1 < len(updated.Content); i += 2 {
+			key, value := updated.Content[i], updated.Content[i+1]
+			if j := mappingIndex(old, key.Value); j != -1 {
+				content = append(content, old.Content[j], syncNode(old.Content[j+1], value, f))
+			} else {
+				content = append(content, newNode(key, f, false), newNode(value, f, false))
+			}
+		}
+		old.Content = content
+	case yaml.SequenceNode:
+		old.Content = syncSequence(old.Content, updated.Content, f)
+	}
+	return old
+}
+
+// syncSequence matches the elements of a sequence to its updated elements.
+// Elements that are equal to an updated element, in the same order, are kept
+// as-is. Other updated elements are synced with an unmatched old element in
+// the same position, if there is one.
+func syncSequence(old, updated []*yaml.Node, f format) []*yaml.Node {
+	match := make([]int, len(updated))
+	next := 0
+	for i, u := range updated {
+		match[i] = -1
+		for j := next; j < len(old); j++ {
+			if nodesEqual(old[j], u) {
+				match[i] = j
+				next = j + 1
+				break
+			}
+		}
+	}
+
+	content := make([]*yaml.Node, len(updated))
+	next = 0
+	for i, u := range updated {
+		if match[i] != -1 {
+			content[i] = old[match[i]]
+			next = match[i] + 1
+			continue
+		}
+		// Only reuse an old element that comes before the next match
+		// so that the order stays the same.
+		limit := len(old)
+		for _, j := range match[i+1:] {
+			if j != -1 {
+				limit = j
+				break
+			}
+		}
+		if next < limit {
+			content[i] = syncNode(old[next], u, f)
+			next++
+		} else {
+			content[i] = newNode(u, f, false)
+		}
+	}
+	return content
+}
+
+// newNode returns a copy of a node that was parsed from JSON, in the style
+// of the document format. In YAML, mappings and sequences use block style.
+// In TOML, sequences are inline arrays unless they contain tables.
+func newNode(n *yaml.Node, f format, inline bool) *yaml.Node {
+	node := &yaml.Node{Kind: n.Kind, Tag: n.Tag, Value: n.Value}
+	switch {
+	case n.Kind == yaml.SequenceNode && f == formatTOML:
+		hasTables := len(n.Content) > 0 && !slices.ContainsFunc(n.Content, func(n *yaml.Node) bool {
+			return n.Kind != yaml.MappingNode
+		})
+		if inline || !hasTables {
+			node.Style = yaml.FlowStyle
+			inline = true
+		}
+	case n.Kind == yaml.MappingNode && inline:
+		node.Style = yaml.FlowStyle
+	}
+	for _, c := range n.Content {
+		node.Content = append(node.Content, newNode(c, f, inline))
+	}
+	return node
+}
+
+func emptyMapping() *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+}
+
+// mappingIndex returns the index of the key node in a mapping's content.
+func mappingIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func isNumber(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && (n.ShortTag() == "!!int" || n.ShortTag() == "!!float")
+}
+
+func nodesEqual(a, b *yaml.Node) bool {
+	if a.Kind == yaml.ScalarNode && b.Kind == yaml.ScalarNode &&
+		(isNumber(a) || isNumber(b)) {
+		return a.Value == b.Value
+	}
+	var va, vb any
+	if a.Decode(&va) != nil || b.Decode(&vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// writeNodeJSON writes a node as JSON, keeping the order of mapping keys.
+func writeNodeJSON(buf *bytes.Buffer, n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		return writeNodeJSON(buf, n.Content[0])
+	case yaml.AliasNode:
+		return writeNodeJSON(buf, n.Alias)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			var key string
+			if err := n.Content[i].Decode(&key); err != nil {
+				return errors.WithStack(err)
+			}
+			b, err := json.Marshal(key)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			buf.Write(b)
+			buf.WriteByte(':')
+			if err := writeNodeJSON(buf, n.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, c := range n.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeNodeJSON(buf, c); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		if isNumber(n) {
+			// Devbox configs don't have numeric fields, so numbers are
+			// strings that don't need quotes in YAML and TOML, such as
+			// versions ("go: 1.20") and env values ("PORT: 8080").
+			// Keep them exactly as written.
+			b, err := json.Marshal(n.Value)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			buf.Write(b)
+			return nil
+		}
+		var v any
+		if err := n.Decode(&v); err != nil {
+			return errors.WithStack(err)
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		buf.Write(b)
+	}
+	return nil
+}
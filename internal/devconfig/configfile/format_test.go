@@ -0,0 +1,214 @@
+package configfile
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/txtar"
+)
+
+// parseFormatTxtarTest is like parseConfigTxtarTest, but the input file is
+// named after the config it's loaded as, such as "devbox.yaml".
+func parseFormatTxtarTest(t *testing.T, test string) (in *ConfigFile, want []byte) {
+	t.Helper()
+
+	ar := txtar.Parse([]byte(test))
+	for _, f := range ar.Files {
+		if f.Name == "want" {
+			want = f.Data
+			continue
+		}
+		var err error
+		in, err = LoadNamedBytes(f.Name, f.Data)
+		if err != nil {
+			t.Fatalf("input %s is invalid: %v\n%s", f.Name, err, f.Data)
+		}
+	}
+	return in, want
+}
+
+func TestYAMLAddPackage(t *testing.T) {
+	in, want := parseFormatTxtarTest(t, `
+-- devbox.yaml --
+# Project tools.
+packages:
+  go: 1.22 # The version in go.mod.
+  # For scripts.
+  python: latest
+env:
+  PORT: 8080
+shell:
+  scripts:
+    test: go test ./...
+-- want --
+# Project tools.
+packages:
+  go: 1.22 # The version in go.mod.
+  # For scripts.
+  python: latest
+  hello: latest
+env:
+  PORT: 8080
+shell:
+  scripts:
+    test: go test ./...
+`)
+
+	in.PackagesMutator.Add("hello@latest")
+	if diff := cmp.Diff(want, configBytes(t, in), optBytesToStrings()); diff != "" {
+		t.Errorf("wrong devbox.yaml (-want +got):\n%s", diff)
+	}
+	if got := in.Env["PORT"]; got != "8080" {
+		t.Errorf("got env PORT = %q, want %q", got, "8080")
+	}
+	if got := in.FileName(); got != "devbox.yaml" {
+		t.Errorf("got FileName() = %q, want %q", got, "devbox.yaml")
+	}
+}
+
+func TestYAMLRemovePackage(t *testing.T) {
+	in, want := parseFormatTxtarTest(t, `
+-- devbox.yaml --
+packages:
+  - go@1.22 # The version in go.mod.
+  # For scripts.
+  - python@latest
+  - hello@latest
+-- want --
+packages:
+  - go@1.22 # The version in go.mod.
+  # For scripts.
+  - python@latest
+`)
+
+	in.PackagesMutator.Remove("hello@latest")
+	if diff := cmp.Diff(want, configBytes(t, in), optBytesToStrings()); diff != "" {
+		t.Errorf("wrong devbox.yaml (-want +got):\n%s", diff)
+	}
+}
+
+func TestTOMLAddPackage(t *testing.T) {
+	in, want := parseFormatTxtarTest(t, `
+-- devbox.toml --
+# Project tools.
+[packages]
+go = "1.22" # The version in go.mod.
+# For scripts.
+python = "latest"
+
+[env]
+PORT = 8080
+
+[shell.scripts]
+test = ["go vet ./...", "go test ./..."]
+-- want --
+# Project tools.
+[packages]
+go = "1.22" # The version in go.mod.
+# For scripts.
+python = "latest"
+hello = "latest"
+
+[env]
+PORT = 8080
+
+[shell.scripts]
+test = ["go vet ./...", "go test ./..."]
+`)
+
+	in.PackagesMutator.Add("hello@latest")
+	if diff := cmp.Diff(want, configBytes(t, in), optBytesToStrings()); diff != "" {
+		t.Errorf("wrong devbox.toml (-want +got):\n%s", diff)
+	}
+	if got := in.Env["PORT"]; got != "8080" {
+		t.Errorf("got env PORT = %q, want %q", got, "8080")
+	}
+}
+
+func TestTOMLRemovePackage(t *testing.T) {
+	in, want := parseFormatTxtarTest(t, `
+-- devbox.toml --
+packages = [
+  "go@1.22", # The version in go.mod.
+  # For scripts.
+  "python@latest",
+  "hello@latest",
+]
+-- want --
+packages = [
+  "go@1.22", # The version in go.mod.
+  # For scripts.
+  "python@latest",
+]
+`)
+
+	in.PackagesMutator.Remove("hello@latest")
+	if diff := cmp.Diff(want, configBytes(t, in), optBytesToStrings()); diff != "" {
+		t.Errorf("wrong devbox.toml (-want +got):\n%s", diff)
+	}
+}
+
+func TestTOMLSetPackageField(t *testing.T) {
+	in, want := parseFormatTxtarTest(t, `
+-- devbox.toml --
+[packages]
+# The C toolchain.
+gcc = "latest"
+-- want --
+[packages]
+
+# The C toolchain.
+[packages.gcc]
+version = "latest"
+outputs = ["out", "man"]
+`)
+
+	if err := in.PackagesMutator.SetOutputs(io.Discard, "gcc@latest", []string{"out", "man"}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, configBytes(t, in), optBytesToStrings()); diff != "" {
+		t.Errorf("wrong devbox.toml (-want +got):\n%s", diff)
+	}
+
+	// The new table must load back as the same config.
+	reloaded, err := LoadNamedBytes("devbox.toml", configBytes(t, in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.Equals(in) {
+		t.Errorf("reloaded config is different:\n%s", configBytes(t, reloaded))
+	}
+}
+
+func TestFormatDiagnostics(t *testing.T) {
+	tests := []struct {
+		name, config string
+		line, column int
+	}{
+		{"devbox.yaml", "packages: []\nenv:\n  PORT: [8080]\n", 3, 3},
+		{"devbox.toml", "packages = []\n\n[env]\nPORT = [8080]\n", 4, 1},
+	}
+	for _, test := range tests {
+		t.Run(filepath.Ext(test.name), func(t *testing.T) {
+			_, err := LoadNamedBytes(test.name, []byte(test.config))
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("got error %v, want a *ValidationError", err)
+			}
+			if validationErr.Name != test.name {
+				t.Errorf("got Name = %q, want %q", validationErr.Name, test.name)
+			}
+			if len(validationErr.Diagnostics) != 1 {
+				t.Fatalf("got diagnostics %v, want 1", validationErr.Diagnostics)
+			}
+			diag := validationErr.Diagnostics[0]
+			if diag.Line != test.line || diag.Column != test.column {
+				t.Errorf("got diagnostic at %d:%d, want %d:%d",
+					diag.Line, diag.Column, test.line, test.column)
+			}
+		})
+	}
+}
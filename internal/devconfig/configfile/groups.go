@@ -0,0 +1,80 @@
+package configfile
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GroupConfig is an optional set of packages. Group packages are locked in
+// devbox.lock like the top-level packages, but they're only installed when
+// the group is selected with --with.
+type GroupConfig struct {
+	// PackagesMutator contains the packages of the group. It uses the same
+	// formats as the top-level packages field.
+	PackagesMutator PackagesMutator `json:"packages"`
+}
+
+// GroupNames returns the sorted names of the groups defined in the config.
+func (c *ConfigFile) GroupNames() []string {
+	if c == nil {
+		return nil
+	}
+	names := make([]string, 0, len(c.Groups))
+	for name := range c.Groups {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Group returns the group with the given name, or nil if the config doesn't
+// define it.
+func (c *ConfigFile) Group(name string) *GroupConfig {
+	if c == nil {
+		return nil
+	}
+	return c.Groups[name]
+}
+
+// Packages returns the packages of the group.
+func (g *GroupConfig) Packages() []Package {
+	if g == nil {
+		return nil
+	}
+	return g.PackagesMutator.collection
+}
+
+// ApplyToPackages returns base with the group's packages added. Packages in
+// the group replace base packages with the same name.
+func (g *GroupConfig) ApplyToPackages(base []Package) []Package {
+	if g == nil {
+		return base
+	}
+	return MergePackages(base, g.Packages())
+}
+
+// MergePackages returns base with packages added. Packages replace base
+// packages with the same name.
+func MergePackages(base, packages []Package) []Package {
+	result := slices.DeleteFunc(slices.Clone(base), func(p Package) bool {
+		return slices.ContainsFunc(packages, func(o Package) bool {
+			return o.Name == p.Name
+		})
+	})
+	return append(result, packages...)
+}
+
+func validateGroups(cfg *ConfigFile) error {
+	for name := range cfg.Groups {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("cannot have group with empty name in devbox.json")
+		}
+		if whitespace.MatchString(name) || strings.Contains(name, ",") {
+			return errors.Errorf(
+				"cannot have group name with whitespace or commas in devbox.json: %s", name)
+		}
+	}
+	return nil
+}
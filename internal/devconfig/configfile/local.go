@@ -0,0 +1,46 @@
+package configfile
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/tailscale/hujson"
+	"go.jetify.com/devbox/internal/boxcli/usererr"
+)
+
+// LocalName is the name of the per-user config file that is merged over
+// devbox.json. It's meant to be gitignored.
+const LocalName = "devbox.local.json"
+
+// LoadLocalBytes loads a devbox.local.json. It has the same format as
+// devbox.json, but can only set packages, env and shell scripts.
+func LoadLocalBytes(b []byte) (*ConfigFile, error) {
+	cfg, err := LoadBytes(b)
+	if validationErr := (&ValidationError{}); errors.As(err, &validationErr) {
+		validationErr.Name = LocalName
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	jsonb, err := hujson.Standardize(slices.Clone(b))
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(jsonb, &fields); err != nil {
+		return nil, err
+	}
+	for name := range fields {
+		if !slices.Contains([]string{"$schema", "packages", "env", "shell"}, name) {
+			return nil, usererr.New(
+				"%s can only set packages, env and shell.scripts, but it sets %q.", LocalName, name)
+		}
+	}
+	if len(cfg.InitHook().Cmds) > 0 {
+		return nil, usererr.New(
+			"%s can only set packages, env and shell.scripts, but it sets %q.", LocalName, "shell.init_hook")
+	}
+	return cfg, nil
+}
@@ -177,6 +177,39 @@ func (pkgs *PackagesMutator) SetPatch(versionedName string, mode PatchMode) erro
 	return nil
 }
 
+// SetVersion changes the version of a package and keeps the rest of its
+// definition.
+func (pkgs *PackagesMutator) SetVersion(versionedName, version string) error {
+	name, oldVersion := parseVersionedName(versionedName)
+	i := pkgs.index(name, oldVersion)
+	if i == -1 {
+		return errors.Errorf("package %s not found", versionedName)
+	}
+	pkgs.collection[i].Version = version
+	pkgs.ast.setPackageVersion(name, version)
+	return nil
+}
+
+// MigratePatchGlibc replaces the deprecated patch_glibc field of each package
+// with the equivalent patch field. It returns the versioned names of the
+// packages that it changed.
+func (pkgs *PackagesMutator) MigratePatchGlibc() []string {
+	migrated := []string{}
+	for i := range pkgs.collection {
+		pkg := &pkgs.collection[i]
+		if pkg.PatchGlibc {
+			// Patch is already "always" unless the package also sets
+			// patch, which takes precedence.
+			pkgs.ast.setPatch(pkg.Name, pkg.Patch)
+		} else if !pkgs.ast.removePackageField(pkg.Name, "patch_glibc") {
+			continue
+		}
+		pkg.PatchGlibc = false
+		migrated = append(migrated, pkg.VersionedName())
+	}
+	return migrated
+}
+
 func (pkgs *PackagesMutator) SetDisablePlugin(versionedName string, v bool) error {
 	name, version := parseVersionedName(versionedName)
 	i := pkgs.index(name, version)
@@ -308,7 +341,12 @@ func NewVersionOnlyPackage(name, version string) Package {
 // If the package has a list of excluded platforms, it is enabled on all platforms
 // except those.
 func (p *Package) IsEnabledOnPlatform() bool {
-	platform := nix.System()
+	return p.IsEnabledOnSystem(nix.System())
+}
+
+// IsEnabledOnSystem reports whether the package's platforms and
+// excluded_platforms allow it on a Nix system, such as "x86_64-linux".
+func (p *Package) IsEnabledOnSystem(platform string) bool {
 	if len(p.Platforms) > 0 {
 		for _, plt := range p.Platforms {
 			if plt == platform {
@@ -216,7 +216,7 @@ func TestJsonifyConfigPackages(t *testing.T) {
 				t.Errorf("got wrong packages (-want +got):\n%s", diff)
 			}
 
-			got, err := hujson.Minimize(config.Bytes())
+			got, err := hujson.Minimize(configBytes(t, config))
 			if err != nil {
 				t.Fatal(err)
 			}
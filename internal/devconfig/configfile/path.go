@@ -0,0 +1,305 @@
+package configfile
+
+import (
+	"bytes"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tailscale/hujson"
+	"go.jetify.com/devbox/internal/boxcli/usererr"
+)
+
+// SplitPath splits a dot-separated config path such as "shell.scripts.test"
+// into its keys. A literal dot in a key can be escaped with a backslash, as in
+// "packages.github:org/repo/v1\.2".
+func SplitPath(path string) ([]string, error) {
+	if path == "" {
+		return nil, usererr.New("config path cannot be empty")
+	}
+	keys := []string{}
+	var key strings.Builder
+	escaped := false
+	for _, r := range path {
+		switch {
+		case escaped:
+			key.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '.':
+			keys = append(keys, key.String())
+			key.Reset()
+		default:
+			key.WriteRune(r)
+		}
+	}
+	keys = append(keys, key.String())
+	if slices.Contains(keys, "") {
+		return nil, usererr.New("config path %q has an empty key", path)
+	}
+	return keys, nil
+}
+
+// GetPath returns the JSON value at a dot-separated path in the config, such
+// as "shell.scripts.test" or "include.0". Comments are omitted. The second
+// return value is false if nothing is set at that path.
+func (c *ConfigFile) GetPath(path string) ([]byte, bool, error) {
+	keys, err := SplitPath(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Look up packages in a copy of the AST that uses the object format so
+	// that paths like "packages.go.version" also work with the legacy
+	// array format.
+	ast := &configAST{root: c.ast.root.Clone()}
+	ast.expandPackage(keys)
+	val := ast.lookup(keys)
+	if val == nil {
+		return nil, false, nil
+	}
+	v := val.Clone()
+	v.Standardize()
+	v.Format()
+	b := bytes.ReplaceAll(v.Pack(), []byte("\t"), []byte("  "))
+	return bytes.TrimSpace(b), true, nil
+}
+
+// SetPath sets the value at a dot-separated path in the config to the given
+// JSON value, creating any missing parent objects along the way. Comments and
+// formatting in the rest of the file are preserved. The edit is rejected if
+// the resulting config is invalid.
+func (c *ConfigFile) SetPath(path string, value []byte) error {
+	keys, err := SplitPath(path)
+	if err != nil {
+		return err
+	}
+	newVal, err := hujson.Parse(value)
+	if err != nil {
+		return usererr.New("invalid JSON value %q: %v", value, err)
+	}
+
+	ast := &configAST{root: c.ast.root.Clone()}
+	if err := ast.setPath(keys, newVal); err != nil {
+		return err
+	}
+	return c.replaceAST(ast)
+}
+
+// UnsetPath removes the value at a dot-separated path in the config. It
+// returns false if nothing was set at that path.
+func (c *ConfigFile) UnsetPath(path string) (bool, error) {
+	keys, err := SplitPath(path)
+	if err != nil {
+		return false, err
+	}
+
+	ast := &configAST{root: c.ast.root.Clone()}
+	if found, ok := ast.unsetArrayPackage(keys); ok {
+		if !found {
+			return false, nil
+		}
+		ast.root.Format()
+		return true, c.replaceAST(ast)
+	}
+	ast.expandPackage(keys)
+	parent := ast.lookup(keys[:len(keys)-1])
+	if parent == nil {
+		return false, nil
+	}
+	last := keys[len(keys)-1]
+	switch comp := parent.Value.(type) {
+	case *hujson.Object:
+		i := ast.memberIndex(comp, last)
+		if i == -1 {
+			return false, nil
+		}
+		comp.Members = slices.Delete(comp.Members, i, i+1)
+	case *hujson.Array:
+		i, err := strconv.Atoi(last)
+		if err != nil || i < 0 || i >= len(comp.Elements) {
+			return false, nil
+		}
+		comp.Elements = slices.Delete(comp.Elements, i, i+1)
+	default:
+		return false, nil
+	}
+	ast.root.Format()
+	return true, c.replaceAST(ast)
+}
+
+// replaceAST re-parses the config from an edited AST so that the ConfigFile
+// fields stay in sync with it.
+func (c *ConfigFile) replaceAST(ast *configAST) error {
+	updated, err := LoadBytes(ast.root.Pack())
+	if err != nil {
+		return usererr.WithUserMessage(err, "The change would make devbox.json invalid.")
+	}
+	updated.AbsRootPath = c.AbsRootPath
+	updated.doc = c.doc
+	*c = *updated
+	return nil
+}
+
+// expandPackage converts the packages field to the object format if keys
+// address a package, and the package itself to an object if keys address one
+// of its fields.
+func (c *configAST) expandPackage(keys []string) {
+	if keys[0] != "packages" || len(keys) < 2 {
+		return
+	}
+	pkgs, ok := c.packagesField(true).Value.Value.(*hujson.Object)
+	if !ok || len(keys) < 3 {
+		return
+	}
+	if i := c.memberIndex(pkgs, keys[1]); i != -1 {
+		c.convertVersionToObject(&pkgs.Members[i].Value)
+	}
+}
+
+// arrayPackageVersion returns the package and version that keys and newVal
+// set if they only set the version of a package in the legacy array format,
+// such as "packages.go" or "packages.go.version" set to "1.22". Versions are
+// edited in place in that format, so that the array isn't converted to an
+// object.
+func (c *configAST) arrayPackageVersion(keys []string, newVal *hujson.Value) (*hujson.Array, string, bool) {
+	if keys[0] != "packages" || len(keys) < 2 || len(keys) > 3 ||
+		len(keys) == 3 && keys[2] != "version" {
+		return nil, "", false
+	}
+	pkgs := c.lookup(keys[:1])
+	if pkgs == nil {
+		return nil, "", false
+	}
+	arr, ok := pkgs.Value.(*hujson.Array)
+	if !ok {
+		return nil, "", false
+	}
+	if newVal == nil {
+		return arr, "", true
+	}
+	version, ok := newVal.Value.(hujson.Literal)
+	if !ok || version.Kind() != '"' {
+		return nil, "", false
+	}
+	return arr, version.String(), true
+}
+
+// setArrayPackage sets the version of a package in the legacy array format.
+// It returns false if keys and newVal don't only set a version.
+func (c *configAST) setArrayPackage(keys []string, newVal hujson.Value) bool {
+	arr, version, ok := c.arrayPackageVersion(keys, &newVal)
+	if !ok {
+		return false
+	}
+	name := keys[1]
+	i := c.packageElementIndex(arr, name)
+	if i == -1 {
+		c.appendPackageToArray(arr, joinNameVersion(name, version))
+		return true
+	}
+	// Keep any comments attached to the old element.
+	arr.Elements[i].Value = hujson.String(joinNameVersion(name, version))
+	return true
+}
+
+// unsetArrayPackage removes a package, or only its version, from the legacy
+// array format. The second return value is false if keys don't address a
+// package or its version in that format.
+func (c *configAST) unsetArrayPackage(keys []string) (found, ok bool) {
+	arr, _, ok := c.arrayPackageVersion(keys, nil)
+	if !ok {
+		return false, false
+	}
+	name := keys[1]
+	i := c.packageElementIndex(arr, name)
+	if i == -1 {
+		return false, true
+	}
+	if len(keys) == 2 {
+		c.removePackageElement(arr, name)
+		return true, true
+	}
+	_, version := parseVersionedName(arr.Elements[i].Value.(hujson.Literal).String())
+	arr.Elements[i].Value = hujson.String(name)
+	return version != "", true
+}
+
+// lookup returns the value at keys, or nil if it doesn't exist. Array
+// elements are addressed by their index.
+func (c *configAST) lookup(keys []string) *hujson.Value {
+	val := &c.root
+	for _, key := range keys {
+		switch comp := val.Value.(type) {
+		case *hujson.Object:
+			i := c.memberIndex(comp, key)
+			if i == -1 {
+				return nil
+			}
+			val = &comp.Members[i].Value
+		case *hujson.Array:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(comp.Elements) {
+				return nil
+			}
+			val = &comp.Elements[i]
+		default:
+			return nil
+		}
+	}
+	return val
+}
+
+func (c *configAST) setPath(keys []string, newVal hujson.Value) error {
+	if c.setArrayPackage(keys, newVal) {
+		c.root.Format()
+		return nil
+	}
+	// Other package fields can only be edited in the object format.
+	c.expandPackage(keys)
+
+	val := &c.root
+	for depth, key := range keys {
+		last := depth == len(keys)-1
+
+		switch comp := val.Value.(type) {
+		case *hujson.Object:
+			i := c.memberIndex(comp, key)
+			if i == -1 {
+				comp.Members = append(comp.Members, hujson.ObjectMember{
+					Name: hujson.Value{
+						Value:       hujson.String(key),
+						BeforeExtra: []byte{'\n'},
+					},
+					Value: hujson.Value{Value: &hujson.Object{}},
+				})
+				i = len(comp.Members) - 1
+			}
+			val = &comp.Members[i].Value
+		case *hujson.Array:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i > len(comp.Elements) {
+				return usererr.New(
+					"cannot set %q: %q is not a valid index for an array of length %d",
+					strings.Join(keys, "."), key, len(comp.Elements))
+			}
+			if i == len(comp.Elements) {
+				// Setting the index one past the end appends.
+				comp.Elements = append(comp.Elements, hujson.Value{Value: &hujson.Object{}})
+			}
+			val = &comp.Elements[i]
+		default:
+			return usererr.New(
+				"cannot set %q: %q is not an object or array",
+				strings.Join(keys, "."), strings.Join(keys[:depth], "."))
+		}
+
+		if last {
+			// Keep any comments attached to the old value.
+			val.Value = newVal.Value
+		}
+	}
+	c.root.Format()
+	return nil
+}
@@ -0,0 +1,205 @@
+//nolint:varnamelen
+package configfile
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSetPath(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  // Comment about packages.
+  "packages": ["go@1.22"],
+  "shell": {
+    // Comment about scripts.
+    "scripts": {
+      "build": "go build ./..."
+    }
+  }
+}
+-- want --
+{
+  // Comment about packages.
+  "packages": {
+    "go": {
+      "version": "1.22",
+      "outputs": ["out", "man"],
+    },
+  },
+  "shell": {
+    // Comment about scripts.
+    "scripts": {
+      "build": "go build ./...",
+      "test":  "go test ./...",
+    },
+  },
+  "env": {
+    "FOO": "bar",
+  },
+  "include": ["plugin:nginx"],
+}`)
+
+	edits := []struct{ path, value string }{
+		{"shell.scripts.test", `"go test ./..."`},
+		{"env.FOO", `"bar"`},
+		{"packages.go.outputs", `["out", "man"]`},
+		{"include", `["plugin:nginx"]`},
+	}
+	for _, edit := range edits {
+		if err := in.SetPath(edit.path, []byte(edit.value)); err != nil {
+			t.Fatalf("SetPath(%q, %s) error: %v", edit.path, edit.value, err)
+		}
+	}
+	if diff := cmp.Diff(want, configBytes(t, in), optParseHujson()); diff != "" {
+		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
+		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
+	}
+	if got := in.Env["FOO"]; got != "bar" {
+		t.Errorf("got Env[FOO] = %q, want %q", got, "bar")
+	}
+}
+
+func TestSetPathArrayPackages(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": [
+    // Comment about go.
+    "go@1.22",
+    "nodejs@20",
+    "python@3.12"
+  ]
+}
+-- want --
+{
+  "packages": [
+    // Comment about go.
+    "go@1.23",
+    "nodejs",
+    "ripgrep@latest",
+  ],
+}`)
+
+	if err := in.SetPath("packages.go", []byte(`"1.23"`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.SetPath("packages.ripgrep.version", []byte(`"latest"`)); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"packages.nodejs.version", "packages.python"} {
+		ok, err := in.UnsetPath(path)
+		if err != nil || !ok {
+			t.Fatalf("UnsetPath(%q) = %v, %v, want true, nil", path, ok, err)
+		}
+	}
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
+		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
+	}
+	if ok, _ := in.UnsetPath("packages.nodejs.version"); ok {
+		t.Error("UnsetPath(packages.nodejs.version) = true for a package without a version")
+	}
+}
+
+func TestSetPathInvalid(t *testing.T) {
+	in, _ := parseConfigTxtarTest(t, `
+-- in --
+{
+  "env": {
+    "FOO": "bar"
+  }
+}`)
+
+	if err := in.SetPath("env.FOO", []byte("1")); err == nil {
+		t.Error("got nil error when setting an env var to a number")
+	}
+	if err := in.SetPath("env.FOO.BAR", []byte(`"baz"`)); err == nil {
+		t.Error("got nil error when setting a field of a string")
+	}
+	if got := in.Env["FOO"]; got != "bar" {
+		t.Errorf("got Env[FOO] = %q after invalid edits, want %q", got, "bar")
+	}
+}
+
+func TestGetPath(t *testing.T) {
+	in, _ := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": ["go@1.22"],
+  "env": {
+    // Comment.
+    "FOO": "bar"
+  },
+  "include": ["plugin:nginx", "path:./plugins/redis"]
+}`)
+
+	tests := map[string]string{
+		"env.FOO":             `"bar"`,
+		"packages.go.version": `"1.22"`,
+		"include.1":           `"path:./plugins/redis"`,
+	}
+	for path, want := range tests {
+		got, ok, err := in.GetPath(path)
+		if err != nil || !ok {
+			t.Errorf("GetPath(%q) = _, %v, %v, want a value", path, ok, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("GetPath(%q) = %s, want %s", path, got, want)
+		}
+	}
+	if _, ok, _ := in.GetPath("env.MISSING"); ok {
+		t.Error("GetPath(env.MISSING) found a value")
+	}
+}
+
+func TestUnsetPath(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "env": {
+    // Comment about FOO.
+    "FOO": "bar",
+    "BAZ": "qux"
+  },
+  "include": ["plugin:nginx", "plugin:redis"]
+}
+-- want --
+{
+  "env": {
+    "BAZ": "qux"
+  },
+  "include": ["plugin:redis"]
+}`)
+
+	for _, path := range []string{"env.FOO", "include.0"} {
+		ok, err := in.UnsetPath(path)
+		if err != nil || !ok {
+			t.Fatalf("UnsetPath(%q) = %v, %v, want true, nil", path, ok, err)
+		}
+	}
+	if diff := cmp.Diff(want, configBytes(t, in)); diff != "" {
+		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
+	}
+	if ok, _ := in.UnsetPath("env.MISSING"); ok {
+		t.Error("UnsetPath(env.MISSING) = true for a missing path")
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	got, err := SplitPath(`packages.github:org/repo/v1\.2.outputs`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"packages", "github:org/repo/v1.2", "outputs"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong keys (-want +got):\n%s", diff)
+	}
+	if _, err := SplitPath("env..FOO"); err == nil {
+		t.Error("got nil error for a path with an empty key")
+	}
+}
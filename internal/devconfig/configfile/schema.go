@@ -0,0 +1,169 @@
+package configfile
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.jetify.com/devbox/internal/devbox/shellcmd"
+)
+
+// Schema is the subset of JSON Schema that is needed to describe devbox.json
+// and plugin.json files. Schemas are generated from the Go types that the
+// files are unmarshalled into with [GenerateSchema], so they can't drift from
+// what devbox actually reads.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+
+	// Not is only used to disallow unknown object properties with
+	// {"additionalProperties": {"not": {}}}, which is the same as
+	// {"additionalProperties": false}.
+	Not *Schema `json:"not,omitempty"`
+}
+
+// noSchema doesn't match any value.
+var noSchema = &Schema{Not: &Schema{}}
+
+// ConfigSchema returns the schema of a devbox.json file.
+var ConfigSchema = sync.OnceValue(func() *Schema {
+	return GenerateSchema(reflect.TypeFor[ConfigFile]())
+})
+
+// GenerateSchema generates a schema for values that encoding/json can
+// unmarshal into t. Struct fields are named by their json tags and unknown
+// fields are disallowed. Types with custom JSON unmarshalling that accept more
+// than one format must be handled by schemaOverride, otherwise they accept
+// any value.
+func GenerateSchema(t reflect.Type) *Schema {
+	if override, ok := schemaOverride(t); ok {
+		return override
+	}
+	if reflect.PointerTo(t).Implements(reflect.TypeFor[json.Unmarshaler]()) {
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return GenerateSchema(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: GenerateSchema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: GenerateSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		return &Schema{}
+	}
+}
+
+func structSchema(t reflect.Type) *Schema {
+	schema := &Schema{
+		Type:                 "object",
+		Properties:           map[string]*Schema{},
+		AdditionalProperties: noSchema,
+	}
+	addStructProperties(schema, t)
+	return schema
+}
+
+func addStructProperties(schema *Schema, t reflect.Type) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+
+		// Like encoding/json, promote the fields of untagged embedded
+		// structs.
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			addStructProperties(schema, ft)
+			continue
+		}
+		if !field.IsExported() || ft.Kind() == reflect.Interface {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = GenerateSchema(field.Type)
+	}
+}
+
+// schemaOverride returns the schema of types that implement json.Unmarshaler
+// or that only allow some values.
+func schemaOverride(t reflect.Type) (*Schema, bool) {
+	switch t {
+	case reflect.TypeFor[shellcmd.Commands]():
+		return &Schema{AnyOf: []*Schema{
+			{Type: "string"},
+			{Type: "array", Items: &Schema{Type: "string"}},
+		}}, true
+	case reflect.TypeFor[ScriptConfig]():
+		return &Schema{AnyOf: []*Schema{
+			{Type: "string"},
+			{Type: "array", Items: &Schema{Type: "string"}},
+			structSchema(reflect.TypeFor[scriptObject]()),
+		}}, true
+	case reflect.TypeFor[RequiredEnv]():
+		return &Schema{AnyOf: []*Schema{
+			{Type: "string"},
+			structSchema(reflect.TypeFor[requiredEnvObject]()),
+		}}, true
+	case reflect.TypeFor[PatchMode]():
+		// An empty patch mode defaults to PatchAuto.
+		return &Schema{
+			Type: "string",
+			Enum: []string{"", string(PatchAuto), string(PatchAlways), string(PatchNever)},
+		}, true
+	case reflect.TypeFor[ScriptArgType]():
+		// An empty type defaults to ScriptArgString.
+		return &Schema{
+			Type: "string",
+			Enum: []string{
+				"", string(ScriptArgString), string(ScriptArgInt),
+				string(ScriptArgBool), string(ScriptArgEnum),
+			},
+		}, true
+	case reflect.TypeFor[Package]():
+		// A package can be a version string or an object. Its name
+		// comes from its key in the packages object.
+		obj := structSchema(t)
+		delete(obj.Properties, "Name")
+		return &Schema{AnyOf: []*Schema{{Type: "string"}, obj}}, true
+	case reflect.TypeFor[EnvFrom]():
+		return &Schema{AnyOf: []*Schema{
+			{Type: "string"},
+			{Type: "array", Items: &Schema{AnyOf: []*Schema{
+				{Type: "string"},
+				structSchema(reflect.TypeFor[EnvFromSource]()),
+			}}},
+		}}, true
+	case reflect.TypeFor[PackagesMutator]():
+		return &Schema{AnyOf: []*Schema{
+			{Type: "array", Items: &Schema{Type: "string"}},
+			{Type: "object", AdditionalProperties: GenerateSchema(reflect.TypeFor[Package]())},
+		}}, true
+	}
+	return nil, false
+}
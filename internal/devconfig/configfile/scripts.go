@@ -1,13 +1,291 @@
 package configfile
 
 import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/pkg/errors"
+	"go.jetify.com/devbox/internal/boxcli/usererr"
+	"go.jetify.com/devbox/internal/cuecfg"
 	"go.jetify.com/devbox/internal/devbox/shellcmd"
 )
 
-type script struct {
+// ScriptConfig is a script in devbox.json. It can be a string or an array of
+// commands, or an object that also describes the script:
+//
+//	"test": "go test ./..."
+//	"build": {
+//	  "cmds": ["go build -o bin/ ./cmd/$target"],
+//	  "description": "Build a binary",
+//	  "depends_on": ["generate"],
+//	  "args": [{"name": "target", "type": "enum", "choices": ["server", "cli"], "default": "server"}]
+//	}
+type ScriptConfig struct {
 	shellcmd.Commands
+
+	// Description is shown when listing scripts.
+	Description string
+
+	// Cwd is the directory that the script runs in. A relative path is
+	// relative to the directory containing devbox.json.
+	Cwd string
+
+	// Env is exported before the script runs. Values can reference other
+	// variables, as in "$HOME/bin".
+	Env map[string]string
+
+	// Args are the arguments that the script accepts, in order. Each one is
+	// exported as an env var with the argument's name.
+	Args []ScriptArg
+
+	// DependsOn lists the scripts that run before this one. Each of them
+	// runs once, even if several scripts depend on it.
+	DependsOn []string
+
+	// MarshalAsObject determines whether MarshalJSON encodes the script as
+	// an object. UnmarshalJSON sets it automatically so that scripts marshal
+	// back to their original format.
+	MarshalAsObject bool
+}
+
+// ScriptArg is an argument of a script.
+type ScriptArg struct {
+	// Name is the name of the env var that holds the argument's value.
+	Name string `json:"name"`
+
+	// Description is shown when the argument is missing.
+	Description string `json:"description,omitempty"`
+
+	// Default is the value of an argument that isn't passed. An argument
+	// without a default is required.
+	Default *string `json:"default,omitempty"`
+
+	// Type is the type of the argument's value. An empty type is
+	// ScriptArgString.
+	Type ScriptArgType `json:"type,omitempty"`
+
+	// Choices are the allowed values of a ScriptArgEnum argument.
+	Choices []string `json:"choices,omitempty"`
+}
+
+// ScriptArgType is the type of a script argument's value.
+type ScriptArgType string
+
+const (
+	// ScriptArgString accepts any value.
+	ScriptArgString ScriptArgType = "string"
+
+	// ScriptArgInt accepts integers.
+	ScriptArgInt ScriptArgType = "int"
+
+	// ScriptArgBool accepts the values that strconv.ParseBool does, such
+	// as "1", "t" or "true". The script gets "true" or "false".
+	ScriptArgBool ScriptArgType = "bool"
+
+	// ScriptArgEnum accepts the values listed in the argument's choices.
+	ScriptArgEnum ScriptArgType = "enum"
+)
+
+func (a *ScriptArg) validateType() error {
+	switch a.Type {
+	case "", ScriptArgString, ScriptArgInt, ScriptArgBool, ScriptArgEnum:
+		return nil
+	default:
+		return fmt.Errorf("argument %q has invalid type %q (must be %s, %s, %s or %s)",
+			a.Name, a.Type, ScriptArgString, ScriptArgInt, ScriptArgBool, ScriptArgEnum)
+	}
+}
+
+// parse checks that value is valid for the argument's type and returns the
+// value that the script gets.
+func (a *ScriptArg) parse(value string) (string, error) {
+	switch a.Type {
+	case "", ScriptArgString:
+		return value, nil
+	case ScriptArgInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return "", fmt.Errorf("argument %q must be an integer, got %q", a.Name, value)
+		}
+		return value, nil
+	case ScriptArgBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("argument %q must be true or false, got %q", a.Name, value)
+		}
+		return strconv.FormatBool(b), nil
+	case ScriptArgEnum:
+		if !slices.Contains(a.Choices, value) {
+			return "", fmt.Errorf("argument %q must be one of %s, got %q",
+				a.Name, strings.Join(a.Choices, ", "), value)
+		}
+		return value, nil
+	default:
+		return "", a.validateType()
+	}
+}
+
+// scriptObject is the object form of a script in devbox.json.
+type scriptObject struct {
+	Cmds        *shellcmd.Commands `json:"cmds,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Cwd         string             `json:"cwd,omitempty"`
+	Env         map[string]string  `json:"env,omitempty"`
+	Args        []ScriptArg        `json:"args,omitempty"`
+	DependsOn   []string           `json:"depends_on,omitempty"`
+}
+
+func (s *ScriptConfig) UnmarshalJSON(data []byte) error {
+	if len(data) == 0 || data[0] != '{' {
+		*s = ScriptConfig{}
+		return s.Commands.UnmarshalJSON(data)
+	}
+	obj := scriptObject{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	*s = ScriptConfig{
+		Description:     obj.Description,
+		Cwd:             obj.Cwd,
+		Env:             obj.Env,
+		Args:            obj.Args,
+		DependsOn:       obj.DependsOn,
+		MarshalAsObject: true,
+	}
+	if obj.Cmds != nil {
+		s.Commands = *obj.Cmds
+	}
+	return nil
+}
+
+func (s ScriptConfig) MarshalJSON() ([]byte, error) {
+	if !s.MarshalAsObject {
+		return s.Commands.MarshalJSON()
+	}
+	obj := scriptObject{
+		Description: s.Description,
+		Cwd:         s.Cwd,
+		Env:         s.Env,
+		Args:        s.Args,
+		DependsOn:   s.DependsOn,
+	}
+	if len(s.Cmds) > 0 {
+		obj.Cmds = &s.Commands
+	}
+	return cuecfg.MarshalJSON(obj)
+}
+
+// ParseArgs returns the env vars that hold the values of the script's
+// arguments. Arguments that aren't in args get their default value. It
+// returns an error if a value doesn't match its argument's type.
+func (s *ScriptConfig) ParseArgs(name string, args []string) (map[string]string, error) {
+	if len(s.Args) == 0 {
+		// Scripts that don't declare arguments get them as "$@".
+		return nil, nil
+	}
+	if len(args) > len(s.Args) {
+		return nil, usererr.New(
+			"script %q takes at most %d argument(s) but got %d. Usage: devbox run %s",
+			name, len(s.Args), len(args), s.Usage(name),
+		)
+	}
+	env := make(map[string]string, len(s.Args))
+	for i, arg := range s.Args {
+		switch {
+		case i < len(args):
+			value, err := arg.parse(args[i])
+			if err != nil {
+				return nil, usererr.New(
+					"script %q: %v. Usage: devbox run %s", name, err, s.Usage(name))
+			}
+			env[arg.Name] = value
+		case arg.Default != nil:
+			env[arg.Name] = *arg.Default
+		default:
+			msg := fmt.Sprintf("script %q is missing argument %q", name, arg.Name)
+			if arg.Description != "" {
+				msg += " (" + arg.Description + ")"
+			}
+			return nil, usererr.New("%s. Usage: devbox run %s", msg, s.Usage(name))
+		}
+	}
+	return env, nil
+}
+
+// Usage returns the script's name followed by its arguments, with optional
+// ones in brackets.
+func (s *ScriptConfig) Usage(name string) string {
+	usage := name
+	for _, arg := range s.Args {
+		if arg.Default == nil {
+			usage += " <" + arg.Name + ">"
+		} else {
+			usage += " [" + arg.Name + "]"
+		}
+	}
+	return usage
+}
+
+func validateScript(name string, s *ScriptConfig) error {
+	if strings.TrimSpace(s.String()) == "" && len(s.DependsOn) == 0 {
+		return errors.Errorf("cannot have an empty script body in devbox.json: %s", name)
+	}
+	for k := range s.Env {
+		if !envNameRegexp.MatchString(k) {
+			return errors.Errorf(
+				"script %s in devbox.json has an invalid env var name: %q", name, k)
+		}
+	}
+	seen := map[string]bool{}
+	optional := false
+	for _, arg := range s.Args {
+		if !envNameRegexp.MatchString(arg.Name) {
+			return errors.Errorf(
+				"script %s in devbox.json has an invalid argument name: %q. Argument "+
+					"names are used as env var names, so they must match %s",
+				name, arg.Name, envNameRegexp)
+		}
+		if seen[arg.Name] {
+			return errors.Errorf(
+				"script %s in devbox.json has more than one argument named %s", name, arg.Name)
+		}
+		seen[arg.Name] = true
+		if arg.Type == ScriptArgEnum && len(arg.Choices) == 0 {
+			return errors.Errorf(
+				"script %s in devbox.json has enum argument %s without choices", name, arg.Name)
+		}
+		if arg.Type != ScriptArgEnum && len(arg.Choices) > 0 {
+			return errors.Errorf(
+				"script %s in devbox.json has choices for argument %s, which isn't an enum",
+				name, arg.Name)
+		}
+		if err := arg.validateType(); err != nil {
+			return errors.Errorf("script %s in devbox.json: %v", name, err)
+		}
+		if arg.Default != nil {
+			if _, err := arg.parse(*arg.Default); err != nil {
+				return errors.Errorf("script %s in devbox.json has an invalid default: %v", name, err)
+			}
+		}
+		if arg.Default != nil {
+			optional = true
+		} else if optional {
+			return errors.Errorf(
+				"script %s in devbox.json has required argument %s after an argument "+
+					"with a default", name, arg.Name)
+		}
+	}
+	return nil
+}
+
+// envNameRegexp matches valid env var names.
+var envNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+type script struct {
+	ScriptConfig
 	Comments string
 }
 
@@ -18,14 +296,14 @@ func (c *ConfigFile) Scripts() Scripts {
 		return nil
 	}
 	result := make(Scripts)
-	for name, commands := range c.Shell.Scripts {
+	for name, config := range c.Shell.Scripts {
 		comments := ""
 		if c.ast != nil {
 			comments = string(c.ast.beforeComment("shell", "scripts", name))
 		}
 		result[name] = &script{
-			Commands: *commands,
-			Comments: comments,
+			ScriptConfig: *config,
+			Comments:     comments,
 		}
 	}
 
@@ -42,10 +320,94 @@ func (s Scripts) WithRelativePaths(projectDir string) Scripts {
 				strings.ReplaceAll(c, projectDir, "."),
 			)
 		}
+		config := s.ScriptConfig
+		config.Commands = commandsWithRelativePaths
 		result[name] = &script{
-			Commands: commandsWithRelativePaths,
-			Comments: s.Comments,
+			ScriptConfig: config,
+			Comments:     s.Comments,
 		}
 	}
 	return result
 }
+
+// ValidateDependencies checks that every script in depends_on exists and that
+// no script depends on itself, directly or through other scripts.
+func (s Scripts) ValidateDependencies() error {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	// Sort for a deterministic error when there are several cycles.
+	slices.Sort(names)
+
+	done := map[string]bool{}
+	var visiting []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		if done[name] {
+			return nil
+		}
+		if i := slices.Index(visiting, name); i != -1 {
+			cycle := append(slices.Clone(visiting[i:]), name)
+			return usererr.New(
+				"scripts in devbox.json depend on each other in a cycle: %s",
+				strings.Join(cycle, " -> "),
+			)
+		}
+		visiting = append(visiting, name)
+		for _, dep := range s[name].DependsOn {
+			if _, ok := s[dep]; !ok {
+				return usererr.New(
+					"script %q in devbox.json depends on script %q, which doesn't exist",
+					name, dep,
+				)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		visiting = visiting[:len(visiting)-1]
+		done[name] = true
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DependencyLevels returns the scripts that the named script depends on,
+// directly or indirectly, grouped so that the scripts in a group only depend
+// on scripts in earlier groups. The scripts in a group can run in parallel.
+// The named script itself isn't included. The dependencies must be valid (see
+// ValidateDependencies).
+func (s Scripts) DependencyLevels(name string) [][]string {
+	// level is the length of the longest dependency chain below a script.
+	level := map[string]int{}
+	var visit func(name string) int
+	visit = func(name string) int {
+		if l, ok := level[name]; ok {
+			return l
+		}
+		l := 0
+		for _, dep := range s[name].DependsOn {
+			l = max(l, visit(dep)+1)
+		}
+		level[name] = l
+		return l
+	}
+	top := visit(name)
+
+	levels := make([][]string, top)
+	for dep, l := range level {
+		if dep != name {
+			levels[l] = append(levels[l], dep)
+		}
+	}
+	for _, l := range levels {
+		slices.Sort(l)
+	}
+	return levels
+}
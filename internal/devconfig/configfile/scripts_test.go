@@ -0,0 +1,199 @@
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScriptDependsOn(t *testing.T) {
+	in := `{
+  "shell": {
+    "scripts": {
+      "build":    {"cmds": ["go build ./..."], "depends_on": ["generate", "deps"]},
+      "build-all": {"depends_on": ["build", "test"]},
+      "deps":     "go mod download",
+      "generate": {"cmds": "go generate ./...", "depends_on": ["deps"]},
+      "test":     ["go test ./..."]
+    }
+  }
+}`
+	cfg, err := LoadBytes([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scripts := cfg.Scripts()
+	if err := scripts.ValidateDependencies(); err != nil {
+		t.Fatal(err)
+	}
+	if got := scripts["generate"].String(); got != "go generate ./..." {
+		t.Errorf("got generate commands %q, want %q", got, "go generate ./...")
+	}
+
+	want := [][]string{{"deps", "test"}, {"generate"}, {"build"}}
+	if diff := cmp.Diff(want, scripts.DependencyLevels("build-all")); diff != "" {
+		t.Errorf("wrong dependency levels (-want +got):\n%s", diff)
+	}
+	if got := scripts.DependencyLevels("deps"); len(got) != 0 {
+		t.Errorf("got dependency levels %v for script without dependencies", got)
+	}
+
+	// Scripts marshal back to the format they were written in.
+	b, err := cfg.Shell.Scripts["generate"].MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := bytes.Buffer{}
+	if err := json.Compact(&got, b); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"cmds":"go generate ./...","depends_on":["deps"]}`; got.String() != want {
+		t.Errorf("got marshalled script %s, want %s", got.String(), want)
+	}
+}
+
+func TestScriptDependsOnInvalid(t *testing.T) {
+	tests := map[string]string{
+		`{"a": {"cmds": "echo a", "depends_on": ["b"]}, "b": {"cmds": "echo b", "depends_on": ["a"]}}`: "a -> b -> a",
+		`{"a": {"cmds": "echo a", "depends_on": ["a"]}}`:                                               "a -> a",
+		`{"a": {"cmds": "echo a", "depends_on": ["missing"]}}`:                                         `"missing"`,
+	}
+	for scripts, wantErr := range tests {
+		cfg, err := LoadBytes([]byte(`{"shell": {"scripts": ` + scripts + `}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cfg.Scripts().ValidateDependencies()
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("got error %v for scripts %s, want error containing %s", err, scripts, wantErr)
+		}
+	}
+
+	if _, err := LoadBytes([]byte(`{"shell": {"scripts": {"a": {"depends_on": []}}}}`)); err == nil {
+		t.Error("LoadBytes accepted a script without commands or dependencies")
+	}
+}
+
+func TestScriptArgs(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`{
+  "shell": {
+    "scripts": {
+      "deploy": {
+        "cmds": ["./deploy.sh"],
+        "description": "Deploy the app",
+        "cwd": "ops",
+        "env": {"LOG_LEVEL": "debug"},
+        "args": [
+          {"name": "service", "description": "the service to deploy"},
+          {"name": "target", "default": "dev"}
+        ]
+      }
+    }
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deploy := cfg.Scripts()["deploy"]
+	if deploy.Description != "Deploy the app" || deploy.Cwd != "ops" || deploy.Env["LOG_LEVEL"] != "debug" {
+		t.Errorf("got script %+v, want description, cwd and env to be set", deploy.ScriptConfig)
+	}
+	if got, want := deploy.Usage("deploy"), "deploy <service> [target]"; got != want {
+		t.Errorf("got usage %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		args    []string
+		want    map[string]string
+		wantErr string
+	}{
+		{args: []string{"api"}, want: map[string]string{"service": "api", "target": "dev"}},
+		{args: []string{"api", "prod"}, want: map[string]string{"service": "api", "target": "prod"}},
+		{args: nil, wantErr: `missing argument "service" (the service to deploy)`},
+		{args: []string{"api", "prod", "extra"}, wantErr: "at most 2 argument(s)"},
+	}
+	for _, test := range tests {
+		got, err := deploy.ParseArgs("deploy", test.args)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("ParseArgs(%q) got error %v, want error containing %q", test.args, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseArgs(%q) got error: %v", test.args, err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("ParseArgs(%q) wrong env (-want +got):\n%s", test.args, diff)
+		}
+	}
+}
+
+func TestScriptArgTypes(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`{
+  "shell": {
+    "scripts": {
+      "serve": {
+        "cmds": ["./serve.sh"],
+        "args": [
+          {"name": "env", "type": "enum", "choices": ["dev", "prod"]},
+          {"name": "port", "type": "int", "default": "8080"},
+          {"name": "debug", "type": "bool", "default": "false"}
+        ]
+      }
+    }
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := cfg.Scripts()["serve"]
+
+	tests := []struct {
+		args    []string
+		want    map[string]string
+		wantErr string
+	}{
+		{args: []string{"dev"}, want: map[string]string{"env": "dev", "port": "8080", "debug": "false"}},
+		{args: []string{"prod", "443", "1"}, want: map[string]string{"env": "prod", "port": "443", "debug": "true"}},
+		{args: []string{"staging"}, wantErr: `argument "env" must be one of dev, prod, got "staging"`},
+		{args: []string{"dev", "http"}, wantErr: `argument "port" must be an integer, got "http"`},
+		{args: []string{"dev", "80", "yes"}, wantErr: `argument "debug" must be true or false, got "yes"`},
+	}
+	for _, test := range tests {
+		got, err := serve.ParseArgs("serve", test.args)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("ParseArgs(%q) got error %v, want error containing %q", test.args, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseArgs(%q) got error: %v", test.args, err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("ParseArgs(%q) wrong env (-want +got):\n%s", test.args, diff)
+		}
+	}
+}
+
+func TestScriptArgsInvalid(t *testing.T) {
+	for _, script := range []string{
+		`{"cmds": "echo", "args": [{"name": "not-valid"}]}`,
+		`{"cmds": "echo", "args": [{"name": "a"}, {"name": "a"}]}`,
+		`{"cmds": "echo", "args": [{"name": "a", "default": ""}, {"name": "b"}]}`,
+		`{"cmds": "echo", "env": {"//": "comment"}}`,
+		`{"cmds": "echo", "args": [{"name": "a", "type": "float"}]}`,
+		`{"cmds": "echo", "args": [{"name": "a", "type": "enum"}]}`,
+		`{"cmds": "echo", "args": [{"name": "a", "choices": ["x"]}]}`,
+		`{"cmds": "echo", "args": [{"name": "a", "type": "int", "default": "many"}]}`,
+	} {
+		_, err := LoadBytes([]byte(`{"shell": {"scripts": {"s": ` + script + `}}}`))
+		if err == nil {
+			t.Errorf("LoadBytes accepted invalid script %s", script)
+		}
+	}
+}
@@ -0,0 +1,564 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// parseTOML parses a TOML document into a yaml.Node tree so that it can be
+// edited in the same way as a YAML document (see document). Comments on their
+// own line become the head comment of the key, table or array element that
+// follows them. Comments at the end of a line become the line comment of the
+// value on that line.
+//
+// It also returns the tables that have a [table] header, so that encodeTOML
+// can keep them even if they only contain subtables.
+func parseTOML(b []byte) (*yaml.Node, map[*yaml.Node]bool, error) {
+	t := &tomlParser{
+		parser:  unstable.Parser{KeepComments: true},
+		headers: map[*yaml.Node]bool{},
+	}
+	t.parser.Reset(b)
+	root, err := t.parse()
+	if perr := (&unstable.ParserError{}); errors.As(err, &perr) {
+		shape := t.parser.Shape(t.parser.Range(perr.Highlight))
+		return nil, nil, fmt.Errorf("line %d, column %d: %s",
+			shape.Start.Line, shape.Start.Column, perr.Message)
+	}
+	return root, t.headers, err
+}
+
+type tomlParser struct {
+	parser  unstable.Parser
+	headers map[*yaml.Node]bool
+
+	// comments are the comments that precede the next expression.
+	comments []string
+}
+
+func (t *tomlParser) parse() (*yaml.Node, error) {
+	root := emptyMapping()
+	table := root
+	for t.parser.NextExpression() {
+		expr := t.parser.Expression()
+		var err error
+		switch expr.Kind {
+		case unstable.Comment:
+			t.comments = append(t.comments, string(expr.Data))
+			continue
+		case unstable.KeyValue:
+			err = t.keyValue(table, expr)
+		case unstable.Table:
+			table, err = t.table(root, expr)
+		case unstable.ArrayTable:
+			table, err = t.arrayTable(root, expr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		t.comments = nil
+	}
+	if err := t.parser.Error(); err != nil {
+		return nil, err
+	}
+	root.FootComment = strings.Join(t.comments, "\n")
+	return root, nil
+}
+
+func (t *tomlParser) keyValue(table *yaml.Node, expr *unstable.Node) error {
+	keys, keyEnd := t.keys(expr.Key())
+	parent, err := t.descend(table, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	key := keys[len(keys)-1]
+	if mappingIndex(parent, key.Value) != -1 {
+		return t.errorf(key, "duplicate key %q", key.Value)
+	}
+	key.HeadComment = strings.Join(t.comments, "\n")
+
+	value, err := t.value(expr.Value(), keyEnd)
+	if err != nil {
+		return err
+	}
+	if comment := expr.Next(); comment != nil && comment.Kind == unstable.Comment {
+		value.LineComment = string(comment.Data)
+	}
+	parent.Content = append(parent.Content, key, value)
+	return nil
+}
+
+// table handles a [table] header and returns the table's mapping.
+func (t *tomlParser) table(root *yaml.Node, expr *unstable.Node) (*yaml.Node, error) {
+	keys, _ := t.keys(expr.Key())
+	parent, err := t.descend(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	key := keys[len(keys)-1]
+	if i := mappingIndex(parent, key.Value); i != -1 {
+		// The table was already created by a dotted key or a
+		// subtable header.
+		if parent.Content[i+1].Kind != yaml.MappingNode {
+			return nil, t.errorf(key, "key %q is already defined", key.Value)
+		}
+		key = parent.Content[i]
+		if len(t.comments) > 0 {
+			key.HeadComment = strings.Join(t.comments, "\n")
+		}
+		key.LineComment = lineComment(expr)
+		t.headers[parent.Content[i+1]] = true
+		return parent.Content[i+1], nil
+	}
+	key.HeadComment = strings.Join(t.comments, "\n")
+	key.LineComment = lineComment(expr)
+	table := emptyMapping()
+	parent.Content = append(parent.Content, key, table)
+	t.headers[table] = true
+	return table, nil
+}
+
+// arrayTable handles an [[array.of.tables]] header and returns the new
+// table's mapping.
+func (t *tomlParser) arrayTable(root *yaml.Node, expr *unstable.Node) (*yaml.Node, error) {
+	keys, _ := t.keys(expr.Key())
+	parent, err := t.descend(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	key := keys[len(keys)-1]
+	var array *yaml.Node
+	if i := mappingIndex(parent, key.Value); i != -1 {
+		array = parent.Content[i+1]
+		if array.Kind != yaml.SequenceNode || array.Style == yaml.FlowStyle {
+			return nil, t.errorf(key, "key %q is already defined", key.Value)
+		}
+	} else {
+		array = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		parent.Content = append(parent.Content, key, array)
+	}
+	table := emptyMapping()
+	table.HeadComment = strings.Join(t.comments, "\n")
+	table.LineComment = lineComment(expr)
+	array.Content = append(array.Content, table)
+	return table, nil
+}
+
+// descend returns the table at keys below table, creating missing tables.
+// The last table of an array of tables is used for an array.
+func (t *tomlParser) descend(table *yaml.Node, keys []*yaml.Node) (*yaml.Node, error) {
+	for _, key := range keys {
+		i := mappingIndex(table, key.Value)
+		if i == -1 {
+			next := emptyMapping()
+			table.Content = append(table.Content, key, next)
+			table = next
+			continue
+		}
+		next := table.Content[i+1]
+		if next.Kind == yaml.SequenceNode && next.Style != yaml.FlowStyle && len(next.Content) > 0 {
+			next = next.Content[len(next.Content)-1]
+		}
+		if next.Kind != yaml.MappingNode {
+			return nil, t.errorf(key, "key %q is already defined", key.Value)
+		}
+		table = next
+	}
+	return table, nil
+}
+
+// keys returns the parts of a dotted key and the offset of the end of the
+// key in the document.
+func (t *tomlParser) keys(it unstable.Iterator) (keys []*yaml.Node, end int) {
+	for it.Next() {
+		raw := it.Node().Raw
+		keys = append(keys, t.scalar(it.Node(), "!!str"))
+		end = int(raw.Offset + raw.Length)
+	}
+	return keys, end
+}
+
+// value converts a TOML value. keyEnd is the offset of the end of the
+// value's key, or -1 if the value is in an array or inline table.
+func (t *tomlParser) value(n *unstable.Node, keyEnd int) (*yaml.Node, error) {
+	switch n.Kind {
+	case unstable.String:
+		return t.scalar(n, "!!str"), nil
+	case unstable.Bool:
+		return t.scalar(n, "!!bool"), nil
+	case unstable.Integer:
+		return t.scalar(n, "!!int"), nil
+	case unstable.Float:
+		return t.scalar(n, "!!float"), nil
+	case unstable.LocalDate, unstable.LocalTime, unstable.LocalDateTime, unstable.DateTime:
+		return t.scalar(n, "!!timestamp"), nil
+	case unstable.InlineTable:
+		table := emptyMapping()
+		table.Style = yaml.FlowStyle
+		it := n.Children()
+		for it.Next() {
+			kv := it.Node()
+			keys, _ := t.keys(kv.Key())
+			parent, err := t.descend(table, keys[:len(keys)-1])
+			if err != nil {
+				return nil, err
+			}
+			parent.Style = yaml.FlowStyle
+			value, err := t.value(kv.Value(), -1)
+			if err != nil {
+				return nil, err
+			}
+			parent.Content = append(parent.Content, keys[len(keys)-1], value)
+		}
+		return table, nil
+	case unstable.Array:
+		return t.array(n, keyEnd)
+	default:
+		return nil, errors.Errorf("unsupported TOML value %s", n.Kind)
+	}
+}
+
+func (t *tomlParser) array(n *unstable.Node, keyEnd int) (*yaml.Node, error) {
+	array := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}
+	var head []string
+	hasComments := false
+	end := 0
+	it := n.Children()
+	for it.Next() {
+		child := it.Node()
+		if child.Kind != unstable.Comment {
+			elem, err := t.value(child, -1)
+			if err != nil {
+				return nil, err
+			}
+			elem.HeadComment = strings.Join(head, "\n")
+			head = nil
+			array.Content = append(array.Content, elem)
+			if child.Raw.Length > 0 {
+				end = max(end, int(child.Raw.Offset+child.Raw.Length))
+			}
+			continue
+		}
+
+		// A comment node is followed by the comments on the lines
+		// below it.
+		hasComments = true
+		for comment := child; comment != nil; comment = nextComment(comment, child) {
+			last := len(array.Content) - 1
+			if last >= 0 && len(head) == 0 && !t.startsLine(comment) {
+				array.Content[last].LineComment = string(comment.Data)
+			} else {
+				head = append(head, string(comment.Data))
+			}
+		}
+	}
+	if len(head) > 0 {
+		array.FootComment = strings.Join(head, "\n")
+	}
+
+	// Keep arrays that span several lines in that format. Nested arrays
+	// are always written on one line.
+	if hasComments || (keyEnd != -1 && end > keyEnd &&
+		bytes.ContainsRune(t.parser.Data()[keyEnd:end], '\n')) {
+		array.Style = 0
+	}
+	return array, nil
+}
+
+// nextComment returns the comment after comment in a group of comments that
+// starts with first.
+func nextComment(comment, first *unstable.Node) *unstable.Node {
+	if comment == first {
+		return first.Child()
+	}
+	return comment.Next()
+}
+
+// startsLine reports whether only whitespace and commas precede a comment
+// on its line.
+func (t *tomlParser) startsLine(comment *unstable.Node) bool {
+	data := t.parser.Data()
+	for i := int(comment.Raw.Offset) - 1; i >= 0; i-- {
+		switch data[i] {
+		case ' ', '\t', ',':
+			continue
+		case '\n', '[':
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (t *tomlParser) scalar(n *unstable.Node, tag string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: string(n.Data)}
+	if n.Raw.Length > 0 {
+		shape := t.parser.Shape(n.Raw)
+		node.Line, node.Column = shape.Start.Line, shape.Start.Column
+	}
+	return node
+}
+
+func (t *tomlParser) errorf(key *yaml.Node, format string, a ...any) error {
+	return fmt.Errorf("line %d, column %d: %s", key.Line, key.Column, fmt.Sprintf(format, a...))
+}
+
+func lineComment(expr *unstable.Node) string {
+	if comment := expr.Next(); comment != nil && comment.Kind == unstable.Comment {
+		return string(comment.Data)
+	}
+	return ""
+}
+
+// encodeTOML writes a yaml.Node tree that was parsed by parseTOML, and then
+// edited, as TOML. Mappings are written as tables unless they have flow style
+// or are in an array, and sequences of tables are written as arrays of
+// tables. Tables that only contain subtables get a header if they're in
+// headers.
+func encodeTOML(root *yaml.Node, headers map[*yaml.Node]bool) []byte {
+	w := &tomlWriter{headers: headers}
+	w.table(nil, root)
+	if root.FootComment != "" {
+		w.blankLine()
+		w.comment(root.FootComment, "")
+	}
+	return w.buf.Bytes()
+}
+
+type tomlWriter struct {
+	buf     bytes.Buffer
+	headers map[*yaml.Node]bool
+}
+
+func (w *tomlWriter) table(path []string, table *yaml.Node) {
+	// Key/value pairs must come before the subtables.
+	var subtables []int
+	for i := 0; i+1 < len(table.Content); i += 2 {
+		key, value := table.Content[i], table.Content[i+1]
+		if isTOMLTable(value) || isTOMLArrayOfTables(value) {
+			subtables = append(subtables, i)
+			continue
+		}
+		if value.ShortTag() == "!!null" {
+			// TOML has no null, so leave the key out.
+			continue
+		}
+		w.comment(key.HeadComment, "")
+		w.buf.WriteString(tomlKey(key.Value))
+		w.buf.WriteString(" = ")
+		w.value(value, "")
+		w.lineComment(value.LineComment)
+		w.buf.WriteByte('\n')
+	}
+
+	for _, i := range subtables {
+		key, value := table.Content[i], table.Content[i+1]
+		subpath := append(slices.Clone(path), key.Value)
+		header := tomlHeader(subpath)
+		if isTOMLArrayOfTables(value) {
+			for j, elem := range value.Content {
+				w.blankLine()
+				if j == 0 {
+					w.comment(key.HeadComment, "")
+				}
+				w.comment(elem.HeadComment, "")
+				w.buf.WriteString("[[" + header + "]]")
+				w.lineComment(elem.LineComment)
+				w.buf.WriteByte('\n')
+				w.table(subpath, elem)
+			}
+			continue
+		}
+		// Tables that only contain other tables don't need a header.
+		implicit := len(value.Content) > 0 && !w.headers[value] &&
+			key.HeadComment == "" && key.LineComment == "" &&
+			!slices.ContainsFunc(pairValues(value), func(v *yaml.Node) bool {
+				return !isTOMLTable(v) && !isTOMLArrayOfTables(v)
+			})
+		if !implicit {
+			w.blankLine()
+			w.comment(key.HeadComment, "")
+			w.buf.WriteString("[" + header + "]")
+			w.lineComment(key.LineComment)
+			w.buf.WriteByte('\n')
+		}
+		w.table(subpath, value)
+	}
+}
+
+func (w *tomlWriter) value(n *yaml.Node, indent string) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		w.buf.WriteByte('{')
+		first := true
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i+1].ShortTag() == "!!null" {
+				continue
+			}
+			if !first {
+				w.buf.WriteByte(',')
+			}
+			first = false
+			w.buf.WriteString(" " + tomlKey(n.Content[i].Value) + " = ")
+			w.value(n.Content[i+1], indent)
+		}
+		if !first {
+			w.buf.WriteByte(' ')
+		}
+		w.buf.WriteByte('}')
+	case yaml.SequenceNode:
+		elems := slices.DeleteFunc(slices.Clone(n.Content), func(n *yaml.Node) bool {
+			return n.ShortTag() == "!!null"
+		})
+		if n.Style == yaml.FlowStyle || len(elems) == 0 {
+			w.buf.WriteByte('[')
+			for i, elem := range elems {
+				if i > 0 {
+					w.buf.WriteString(", ")
+				}
+				w.value(elem, indent)
+			}
+			w.buf.WriteByte(']')
+			return
+		}
+		w.buf.WriteString("[\n")
+		for _, elem := range elems {
+			w.comment(elem.HeadComment, indent+"  ")
+			w.buf.WriteString(indent + "  ")
+			w.value(elem, indent+"  ")
+			w.buf.WriteByte(',')
+			w.lineComment(elem.LineComment)
+			w.buf.WriteByte('\n')
+		}
+		w.comment(n.FootComment, indent+"  ")
+		w.buf.WriteString(indent + "]")
+	case yaml.AliasNode:
+		w.value(n.Alias, indent)
+	default:
+		switch n.ShortTag() {
+		case "!!bool", "!!int", "!!float", "!!timestamp":
+			w.buf.WriteString(n.Value)
+		default:
+			w.buf.WriteString(tomlString(n.Value))
+		}
+	}
+}
+
+// comment writes the lines of a head comment, adding "#" to lines that don't
+// have it.
+func (w *tomlWriter) comment(text, indent string) {
+	if text == "" {
+		return
+	}
+	for line := range strings.SplitSeq(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			line = "# " + line
+		}
+		if line != "" {
+			w.buf.WriteString(indent)
+		}
+		w.buf.WriteString(line + "\n")
+	}
+}
+
+func (w *tomlWriter) lineComment(text string) {
+	if text == "" {
+		return
+	}
+	if !strings.HasPrefix(text, "#") {
+		text = "# " + text
+	}
+	w.buf.WriteString(" " + text)
+}
+
+// blankLine separates tables from what comes before them.
+func (w *tomlWriter) blankLine() {
+	if w.buf.Len() > 0 && !bytes.HasSuffix(w.buf.Bytes(), []byte("\n\n")) {
+		w.buf.WriteByte('\n')
+	}
+}
+
+func isTOMLTable(n *yaml.Node) bool {
+	return n.Kind == yaml.MappingNode && n.Style != yaml.FlowStyle
+}
+
+func isTOMLArrayOfTables(n *yaml.Node) bool {
+	return n.Kind == yaml.SequenceNode && n.Style != yaml.FlowStyle && len(n.Content) > 0 &&
+		!slices.ContainsFunc(n.Content, func(n *yaml.Node) bool { return !isTOMLTable(n) })
+}
+
+func pairValues(mapping *yaml.Node) []*yaml.Node {
+	values := make([]*yaml.Node, 0, len(mapping.Content)/2)
+	for i := 1; i < len(mapping.Content); i += 2 {
+		values = append(values, mapping.Content[i])
+	}
+	return values
+}
+
+var bareKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(key string) string {
+	if bareKeyRegexp.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+func tomlHeader(path []string) string {
+	keys := make([]string, len(path))
+	for i, key := range path {
+		keys[i] = tomlKey(key)
+	}
+	return strings.Join(keys, ".")
+}
+
+// tomlString quotes s as a TOML basic string, or as a multi-line basic
+// string if it has line breaks.
+func tomlString(s string) string {
+	multiline := strings.Contains(s, "\n")
+	buf := strings.Builder{}
+	if multiline {
+		// A line break right after the delimiter is trimmed.
+		buf.WriteString(`"""` + "\n")
+	} else {
+		buf.WriteByte('"')
+	}
+	for _, r := range s {
+		switch {
+		case r == '"':
+			buf.WriteString(`\"`)
+		case r == '\\':
+			buf.WriteString(`\\`)
+		case r == '\n' && multiline:
+			buf.WriteRune(r)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&buf, `\u%04X`, r)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	if multiline {
+		buf.WriteString(`"""`)
+	} else {
+		buf.WriteByte('"')
+	}
+	return buf.String()
+}
@@ -0,0 +1,262 @@
+package configfile
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tailscale/hujson"
+)
+
+// Diagnostic is a problem found when validating a config file against its
+// schema.
+type Diagnostic struct {
+	// Path is the dot-separated path to the problematic value, in the same
+	// format as `devbox config get`.
+	Path string `json:"path"`
+
+	// Line and Column are the 1-based position of the problematic value (or
+	// the key, for unknown fields) in the file.
+	Line   int `json:"line"`
+	Column int `json:"column"`
+
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s", d.Line, d.Column, d.Message)
+}
+
+// ValidationError is returned when a config file can't be loaded because it
+// doesn't match its schema.
+type ValidationError struct {
+	// Name is the name of the invalid file, such as devbox.json.
+	Name        string
+	Diagnostics []Diagnostic
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		lines[i] = d.String()
+	}
+	return fmt.Sprintf("invalid %s:\n%s", e.Name, strings.Join(lines, "\n"))
+}
+
+// Validate returns the problems found in the config file when checking it
+// against [ConfigSchema]. Unknown fields are reported even though they don't
+// prevent the config from loading, because they're usually typos.
+func (c *ConfigFile) Validate() []Diagnostic {
+	if c.ast == nil {
+		return nil
+	}
+	diags, _ := Validate(c.ast.root.Pack(), ConfigSchema())
+	if c.doc != nil {
+		c.doc.remapDiagnostics(diags)
+	}
+	return diags
+}
+
+// Validate returns the problems found in a hujson file when checking it
+// against schema. It only returns an error if b isn't valid hujson.
+func Validate(b []byte, schema *Schema) ([]Diagnostic, error) {
+	root, err := hujson.Parse(b)
+	if err != nil {
+		return nil, err
+	}
+	v := &validator{src: b}
+	v.validate(&root, schema, nil)
+	return v.diagnostics, nil
+}
+
+type validator struct {
+	src         []byte
+	diagnostics []Diagnostic
+}
+
+func (v *validator) validate(val *hujson.Value, schema *Schema, path []string) {
+	kind := val.Value.Kind()
+	if kind == 'n' {
+		// encoding/json ignores nulls.
+		return
+	}
+	if len(schema.AnyOf) > 0 {
+		for _, s := range schema.AnyOf {
+			if s.Type == "" || s.Type == jsonType(val.Value) {
+				v.validate(val, s, path)
+				return
+			}
+		}
+		types := make([]string, len(schema.AnyOf))
+		for i, s := range schema.AnyOf {
+			types[i] = article(s.Type)
+		}
+		v.report(val, path, "%s must be %s, not %s",
+			describePath(path), joinOr(types), article(jsonType(val.Value)))
+		return
+	}
+	if schema.Type != "" && schema.Type != jsonType(val.Value) {
+		if schema.Type != "number" || jsonType(val.Value) != "integer" {
+			v.report(val, path, "%s must be %s, not %s",
+				describePath(path), article(schema.Type), article(jsonType(val.Value)))
+			return
+		}
+	}
+	if len(schema.Enum) > 0 {
+		lit, _ := val.Value.(hujson.Literal)
+		if !slices.Contains(schema.Enum, lit.String()) {
+			quoted := make([]string, len(schema.Enum))
+			for i, e := range schema.Enum {
+				quoted[i] = strconv.Quote(e)
+			}
+			v.report(val, path, "%s must be %s, not %q",
+				describePath(path), joinOr(quoted), lit.String())
+		}
+		return
+	}
+
+	switch comp := val.Value.(type) {
+	case *hujson.Object:
+		for i := range comp.Members {
+			m := &comp.Members[i]
+			name := m.Name.Value.(hujson.Literal).String()
+			memberPath := append(slices.Clone(path), name)
+			propSchema, ok := schema.Properties[name]
+			if !ok {
+				propSchema = schema.AdditionalProperties
+			}
+			if propSchema == nil {
+				continue
+			}
+			if propSchema.Not != nil {
+				msg := fmt.Sprintf("unknown field %q", name)
+				if len(path) > 0 {
+					msg += " in " + describePath(path)
+				}
+				if suggestion := closestName(name, schema.Properties); suggestion != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", suggestion)
+				}
+				v.report(&m.Name, memberPath, "%s", msg)
+				continue
+			}
+			v.validate(&m.Value, propSchema, memberPath)
+		}
+	case *hujson.Array:
+		if schema.Items == nil {
+			return
+		}
+		for i := range comp.Elements {
+			v.validate(&comp.Elements[i], schema.Items, append(slices.Clone(path), strconv.Itoa(i)))
+		}
+	}
+}
+
+func (v *validator) report(val *hujson.Value, path []string, format string, a ...any) {
+	line, col := position(v.src, val.StartOffset)
+	v.diagnostics = append(v.diagnostics, Diagnostic{
+		Path:    joinPath(path),
+		Line:    line,
+		Column:  col,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// position converts a byte offset into a 1-based line and column.
+func position(src []byte, offset int) (line, col int) {
+	offset = min(offset, len(src))
+	before := src[:offset]
+	line = bytes.Count(before, []byte{'\n'}) + 1
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	return line, utf8.RuneCount(before[lineStart:]) + 1
+}
+
+func jsonType(v hujson.ValueTrimmed) string {
+	switch v.Kind() {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "boolean"
+	case '0':
+		if _, err := strconv.ParseInt(v.(hujson.Literal).String(), 10, 64); err == nil {
+			return "integer"
+		}
+		return "number"
+	default:
+		return "null"
+	}
+}
+
+// joinPath joins keys into a path that SplitPath can parse.
+func joinPath(keys []string) string {
+	escaped := make([]string, len(keys))
+	for i, k := range keys {
+		escaped[i] = strings.ReplaceAll(k, ".", `\.`)
+	}
+	return strings.Join(escaped, ".")
+}
+
+func describePath(path []string) string {
+	if len(path) == 0 {
+		return "the config"
+	}
+	return strconv.Quote(joinPath(path))
+}
+
+func article(typ string) string {
+	switch typ {
+	case "array", "object", "integer":
+		return "an " + typ
+	case "":
+		return "any value"
+	default:
+		return "a " + typ
+	}
+}
+
+func joinOr(s []string) string {
+	if len(s) <= 1 {
+		return strings.Join(s, "")
+	}
+	return strings.Join(s[:len(s)-1], ", ") + " or " + s[len(s)-1]
+}
+
+// closestName returns the property name that is most likely what the user
+// meant to type, or an empty string if none are close enough.
+func closestName(name string, properties map[string]*Schema) string {
+	best, bestDist := "", 3
+	for prop := range properties {
+		dist := editDistance(strings.ToLower(name), strings.ToLower(prop))
+		if dist < bestDist || (dist == bestDist && prop < best) {
+			best, bestDist = prop, dist
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,133 @@
+package configfile
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want []Diagnostic
+	}{
+		{
+			name: "valid",
+			json: `{
+  "$schema": "https://example.com/devbox.schema.json",
+  "packages": {"go": "latest", "python": {"version": "3.12", "patch": "never"}},
+  "env": {"FOO": "bar"},
+  "shell": {
+    "init_hook": "echo hi",
+    "scripts": {"test": ["go test ./..."], "ci": {"cmds": "echo ci", "description": "Run CI", "args": [{"name": "TARGET", "default": "all"}], "depends_on": ["test"]}}
+  },
+  "include": ["plugin:nginx"]
+}`,
+		},
+		{
+			name: "legacy-packages",
+			json: `{"packages": ["go@latest", "python"]}`,
+		},
+		{
+			name: "nulls",
+			json: `{"packages": null, "shell": {"init_hook": null}}`,
+		},
+		{
+			name: "misspelled-field",
+			json: `{
+  "shell": {
+    // Comment.
+    "init_hooks": ["echo hi"]
+  }
+}`,
+			want: []Diagnostic{{
+				Path:    "shell.init_hooks",
+				Line:    4,
+				Column:  5,
+				Message: `unknown field "init_hooks" in "shell" (did you mean "init_hook"?)`,
+			}},
+		},
+		{
+			name: "unknown-top-level-field",
+			json: `{"pakages": {}}`,
+			want: []Diagnostic{{
+				Path:    "pakages",
+				Line:    1,
+				Column:  2,
+				Message: `unknown field "pakages" (did you mean "packages"?)`,
+			}},
+		},
+		{
+			name: "wrong-types",
+			json: `{
+  "env": {"PORT": 8080},
+  "packages": {"go": ["1.22"]},
+  "include": "plugin:nginx"
+}`,
+			want: []Diagnostic{
+				{
+					Path:    "env.PORT",
+					Line:    2,
+					Column:  19,
+					Message: `"env.PORT" must be a string, not an integer`,
+				},
+				{
+					Path:    "packages.go",
+					Line:    3,
+					Column:  22,
+					Message: `"packages.go" must be a string or an object, not an array`,
+				},
+				{
+					Path:    "include",
+					Line:    4,
+					Column:  14,
+					Message: `"include" must be an array, not a string`,
+				},
+			},
+		},
+		{
+			name: "invalid-enum",
+			json: `{"packages": {"go": {"patch": "sometimes"}}}`,
+			want: []Diagnostic{{
+				Path:    "packages.go.patch",
+				Line:    1,
+				Column:  31,
+				Message: `"packages.go.patch" must be "", "auto", "always" or "never", not "sometimes"`,
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Validate([]byte(tt.json), ConfigSchema())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("wrong diagnostics (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadBytesValidationError(t *testing.T) {
+	_, err := LoadBytes([]byte(`{
+  "env": {
+    "PORT": 8080
+  }
+}`))
+	validationErr := &ValidationError{}
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("got error %v, want a *ValidationError", err)
+	}
+	want := []Diagnostic{{
+		Path:    "env.PORT",
+		Line:    3,
+		Column:  13,
+		Message: `"env.PORT" must be a string, not an integer`,
+	}}
+	if diff := cmp.Diff(want, validationErr.Diagnostics); diff != "" {
+		t.Errorf("wrong diagnostics (-want +got):\n%s", diff)
+	}
+}
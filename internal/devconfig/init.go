@@ -4,6 +4,7 @@
 package devconfig
 
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
 
@@ -11,6 +12,20 @@
 )
 
 func Init(dir string) (*Config, error) {
+	// Don't create a devbox.json next to a devbox.yaml or devbox.toml.
+	for _, name := range configfile.Names {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrExist}
+		}
+	}
+
+	newConfig := DefaultConfig()
+	b, err := newConfig.Root.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
 	file, err := os.OpenFile(
 		filepath.Join(dir, configfile.DefaultName),
 		os.O_RDWR|os.O_CREATE|os.O_EXCL,
@@ -25,8 +40,7 @@ func Init(dir string) (*Config, error) {
 		}
 	}()
 
-	newConfig := DefaultConfig()
-	_, err = file.Write(newConfig.Root.Bytes())
+	_, err = file.Write(b)
 	defer file.Close()
 	if err != nil {
 		return nil, err
@@ -8,14 +8,15 @@
 	"time"
 
 	"go.jetify.com/devbox/internal/debug"
+	"go.jetify.com/devbox/internal/envir"
 	"go.jetify.com/devbox/internal/lock"
 	"go.jetify.com/devbox/internal/nix"
 	"golang.org/x/sync/errgroup"
 )
 
-// binaryCache is the store from which to fetch this package's binaries.
+// BinaryCache is the store from which to fetch this package's binaries.
 // It is used as FromStore in builtins.fetchClosure.
-const binaryCache = "https://cache.nixos.org"
+const BinaryCache = "https://cache.nixos.org"
 
 // useDefaultOutputs is a special value for the outputName parameter of
 // fetchNarInfoStatusOnce, which indicates that the default outputs should be
@@ -64,6 +65,9 @@ func FillNarInfoCache(ctx context.Context, packages ...*Package) error {
 	if len(eligiblePackages) == 0 {
 		return nil
 	}
+	if envir.IsOffline() {
+		return fillLocalStoreCache(ctx, eligiblePackages)
+	}
 
 	group, _ := errgroup.WithContext(ctx)
 	for _, p := range eligiblePackages {
@@ -123,14 +127,20 @@ func (p *Package) fetchNarInfoStatusOnce(
 	}
 
 	for _, output := range outputs {
-		pathParts := nix.NewStorePathParts(output.Path)
-		hash := pathParts.Hash
-		inCache, err := fetchNarInfoStatusFromHTTP(ctx, binaryCache, hash)
+		var inCache bool
+		if envir.IsOffline() {
+			// fetchClosure uses outputs that are already in the local store
+			// without downloading them.
+			inCache, err = isInLocalStore(ctx, output.Path)
+		} else {
+			pathParts := nix.NewStorePathParts(output.Path)
+			inCache, err = fetchNarInfoStatusFromHTTP(ctx, BinaryCache, pathParts.Hash)
+		}
 		if err != nil {
 			return nil, err
 		}
 		if inCache {
-			outputToCache[output.Name] = binaryCache
+			outputToCache[output.Name] = BinaryCache
 		}
 	}
 
@@ -230,3 +240,51 @@ func() (bool, error) {
 	))
 	return fetch.(func() (bool, error))()
 }
+
+// localStoreCache maps store paths to whether they're in the local store. It's
+// used instead of the binary cache in offline mode.
+var localStoreCache = sync.Map{}
+
+// fillLocalStoreCache checks which outputs of packages are in the local store
+// with a single nix command.
+func fillLocalStoreCache(ctx context.Context, packages []*Package) error {
+	paths := []string{}
+	for _, pkg := range packages {
+		outputNames, err := pkg.GetOutputNames()
+		if err != nil {
+			return err
+		}
+		for _, name := range outputNames {
+			outputs, err := pkg.outputsForOutputName(name)
+			if err != nil {
+				return err
+			}
+			for _, output := range outputs {
+				paths = append(paths, output.Path)
+			}
+		}
+	}
+	return cacheLocalStorePaths(ctx, paths...)
+}
+
+func isInLocalStore(ctx context.Context, path string) (bool, error) {
+	if inStore, ok := localStoreCache.Load(path); ok {
+		return inStore.(bool), nil
+	}
+	if err := cacheLocalStorePaths(ctx, path); err != nil {
+		return false, err
+	}
+	inStore, _ := localStoreCache.Load(path)
+	return inStore.(bool), nil
+}
+
+func cacheLocalStorePaths(ctx context.Context, paths ...string) error {
+	inStore, err := nix.StorePathsAreInStore(ctx, paths)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		localStoreCache.Store(path, inStore[path])
+	}
+	return nil
+}
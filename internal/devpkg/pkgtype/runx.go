@@ -3,10 +3,16 @@
 import (
 	"context"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 
 	"go.jetify.com/pkg/runx/impl/registry"
 	"go.jetify.com/pkg/runx/impl/runx"
+	"go.jetify.com/pkg/runx/impl/types"
+
+	"go.jetify.com/devbox/internal/boxcli/usererr"
+	"go.jetify.com/devbox/internal/envir"
 )
 
 const (
@@ -29,6 +35,33 @@ func RunXClient() *runx.RunX {
 	}
 }
 
+// RunXInstall installs a resolved runx package and returns its paths. In
+// offline mode the package must already be in runx's cache, because
+// downloading it uses GitHub.
+func RunXInstall(ctx context.Context, resolved string) ([]string, error) {
+	if !envir.IsOffline() {
+		return RunXClient().Install(ctx, resolved)
+	}
+
+	ref, err := types.NewPkgRef(resolved)
+	if err != nil {
+		return nil, err
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	// The same layout as runx's local registry.
+	path := filepath.Join(cacheDir, "runx", "pkgs", ref.Owner, ref.Repo, ref.Version, runtime.GOOS, runtime.GOARCH)
+	if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
+		return nil, usererr.New(
+			"runx package %s is not in the runx cache and can't be downloaded from GitHub in offline mode",
+			resolved,
+		)
+	}
+	return []string{path}, nil
+}
+
 func RunXRegistry(ctx context.Context) (*registry.Registry, error) {
 	if cachedRegistry == nil {
 		var err error
@@ -13,9 +13,18 @@
 	DevboxGateway = "DEVBOX_GATEWAY"
 	// DevboxLatestVersion is the latest version available of the devbox CLI binary.
 	// NOTE: it should NOT start with v (like 0.4.8)
-	DevboxLatestVersion  = "DEVBOX_LATEST_VERSION"
-	DevboxRegion         = "DEVBOX_REGION"
-	DevboxSearchHost     = "DEVBOX_SEARCH_HOST"
+	DevboxLatestVersion = "DEVBOX_LATEST_VERSION"
+	// DevboxOffline disables every network access that devbox makes itself,
+	// such as searching for packages, fetching plugins and checking for
+	// updates, and passes --offline to nix. Packages are installed from
+	// devbox.lock and the local nix store.
+	DevboxOffline    = "DEVBOX_OFFLINE"
+	DevboxRegion     = "DEVBOX_REGION"
+	DevboxSearchHost = "DEVBOX_SEARCH_HOST"
+	// DevboxSearchIndex is the path of a local search index file, built
+	// with `devbox search-index build`, to use instead of the search
+	// service.
+	DevboxSearchIndex    = "DEVBOX_SEARCH_INDEX"
 	DevboxShellEnabled   = "DEVBOX_SHELL_ENABLED"
 	DevboxShellStartTime = "DEVBOX_SHELL_START_TIME"
 	DevboxVM             = "DEVBOX_VM"
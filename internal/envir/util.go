@@ -35,6 +35,13 @@ func IsCI() bool {
 	return ci && err == nil
 }
 
+// IsOffline returns true if devbox shouldn't use the network. See
+// DevboxOffline.
+func IsOffline() bool {
+	offline, _ := strconv.ParseBool(os.Getenv(DevboxOffline))
+	return offline
+}
+
 // GetValueOrDefault gets the value of an environment variable.
 // If it's empty, it will return the given default value instead.
 func GetValueOrDefault(key, def string) string {
@@ -0,0 +1,75 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// Include pins the content of a remote (https://, tarball or file) include.
+type Include struct {
+	// Resolved is the URL that the include is fetched from.
+	Resolved string `json:"resolved"`
+	// Hash is the SRI hash of the include's content.
+	Hash string `json:"hash"`
+	// Files are the SRI hashes of the files that a remote file include
+	// fetches besides its plugin.json, keyed by their path relative to it.
+	Files map[string]string `json:"files,omitempty"`
+}
+
+// IncludeHash returns the SRI hash (such as "sha256-...") that the lockfile
+// uses to pin the content of a remote include.
+func IncludeHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// PinnedIncludeHash returns the content hash recorded for the remote include
+// with the given lockfile key, or an empty string if it isn't pinned yet.
+func (f *File) PinnedIncludeHash(key string) string {
+	if locked := f.Includes[key]; locked != nil {
+		return locked.Hash
+	}
+	return ""
+}
+
+// PinInclude records the content hash of a remote include. Like Resolve, it
+// only updates the in-memory lockfile.
+func (f *File) PinInclude(key, url, hash string) {
+	if f.Includes == nil {
+		f.Includes = map[string]*Include{}
+	}
+	f.Includes[key] = &Include{Resolved: url, Hash: hash}
+}
+
+// PinnedIncludeFiles returns the content hashes recorded for the files of the
+// remote include with the given lockfile key, keyed by their path relative to
+// its plugin.json.
+func (f *File) PinnedIncludeFiles(key string) map[string]string {
+	if locked := f.Includes[key]; locked != nil {
+		return locked.Files
+	}
+	return nil
+}
+
+// PinIncludeFile records the content hash of a file of a remote include that
+// is already pinned with PinInclude. Like Resolve, it only updates the
+// in-memory lockfile.
+func (f *File) PinIncludeFile(key, subpath, hash string) {
+	locked := f.Includes[key]
+	if locked == nil {
+		return
+	}
+	if locked.Files == nil {
+		locked.Files = map[string]string{}
+	}
+	locked.Files[subpath] = hash
+}
+
+// UnpinIncludes removes the content hashes of all remote includes so that
+// they are re-pinned the next time they're fetched.
+func (f *File) UnpinIncludes() {
+	clear(f.Includes)
+}
@@ -9,6 +9,15 @@ type devboxProject interface {
 	ConfigHash() (string, error)
 	Stdenv() flake.Ref
 	AllPackageNamesIncludingRemovedTriggerPackages() []string
+	// InactivePackageNames returns packages that are declared in the config
+	// but aren't part of the current environment. Tidy keeps their entries.
+	InactivePackageNames() []string
+	// IncludeLockfileKeys returns the lockfile keys of the plugins that the
+	// config includes. Tidy keeps their include pins.
+	IncludeLockfileKeys() []string
+	// LocalPackageNames returns the packages that are only declared in
+	// devbox.local.json. Their entries are saved in devbox.local.lock.
+	LocalPackageNames() []string
 	ProjectDir() string
 }
 
@@ -7,6 +7,7 @@
 	"context"
 	"io/fs"
 	"maps"
+	"os"
 	"path/filepath"
 	"slices"
 	"strings"
@@ -32,6 +33,10 @@ type File struct {
 
 	// Packages is keyed by "canonicalName@version"
 	Packages map[string]*Package `json:"packages"`
+
+	// Includes pins the content of remote includes. It is keyed by their
+	// lockfile key.
+	Includes map[string]*Include `json:"includes,omitempty"`
 }
 
 func GetFile(project devboxProject) (*File, error) {
@@ -41,13 +46,23 @@ func GetFile(project devboxProject) (*File, error) {
 		LockFileVersion: lockFileVersion,
 		Packages:        map[string]*Package{},
 	}
-	err := cuecfg.ParseFile(lockFilePath(project.ProjectDir()), lockFile)
-	if errors.Is(err, fs.ErrNotExist) {
-		return lockFile, nil
+	err := cuecfg.ParseFile(FilePath(project.ProjectDir()), lockFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
 	}
-	if err != nil {
+
+	// Entries for the packages of devbox.local.json are kept in their own
+	// lockfile. Shared entries win if both have the same package.
+	localFile := &File{}
+	err = cuecfg.ParseFile(LocalFilePath(project.ProjectDir()), localFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return nil, err
 	}
+	for name, pkg := range localFile.Packages {
+		if _, ok := lockFile.Packages[name]; !ok {
+			lockFile.Packages[name] = pkg
+		}
+	}
 
 	// If the lockfile has legacy StorePath fields, we need to convert them to the new format
 	ensurePackagesHaveOutputs(lockFile.Packages)
@@ -124,6 +139,66 @@ func (f *File) Save() error {
 		return nil
 	}
 
+	data, err := f.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(FilePath(f.devboxProject.ProjectDir()), data, 0o644); err != nil {
+		return errors.WithStack(err)
+	}
+	return f.saveLocal()
+}
+
+// saveLocal writes the entries of local packages to devbox.local.lock, or
+// removes it if there are none.
+func (f *File) saveLocal() error {
+	path := LocalFilePath(f.devboxProject.ProjectDir())
+	local := f.localPackages()
+	if len(local) == 0 {
+		err := os.Remove(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+	data, err := (&File{LockFileVersion: f.LockFileVersion, Packages: local}).Bytes()
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(os.WriteFile(path, data, 0o644))
+}
+
+// localPackages returns the entries of the packages that are only declared
+// in devbox.local.json.
+func (f *File) localPackages() map[string]*Package {
+	local := map[string]*Package{}
+	for _, name := range f.devboxProject.LocalPackageNames() {
+		if pkg, ok := f.Packages[name]; ok {
+			local[name] = pkg
+		}
+	}
+	return local
+}
+
+// Bytes returns the lockfile as it would be written to disk by Save. It
+// doesn't include the entries of local packages, which are written to
+// devbox.local.lock.
+func (f *File) Bytes() ([]byte, error) {
+	if f.devboxProject != nil {
+		if local := f.localPackages(); len(local) > 0 {
+			shared := &File{
+				LockFileVersion: f.LockFileVersion,
+				Packages:        maps.Clone(f.Packages),
+				Includes:        f.Includes,
+			}
+			maps.DeleteFunc(shared.Packages, func(name string, _ *Package) bool {
+				_, ok := local[name]
+				return ok
+			})
+			return shared.Bytes()
+		}
+	}
+
 	// In SystemInfo, preserve legacy StorePath field and clear out modern Outputs before writing
 	// Reason: We want to update `devbox.lock` file only upon a user action
 	// such as `devbox update` or `devbox add` or `devbox remove`.
@@ -138,7 +213,11 @@ func (f *File) Save() error {
 	// users of the `lock.File` struct will have the correct data.
 	defer ensurePackagesHaveOutputs(f.Packages)
 
-	return cuecfg.WriteFile(lockFilePath(f.devboxProject.ProjectDir()), f)
+	data, err := cuecfg.MarshalJSON(f)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
 }
 
 func (f *File) UpdateStdenv() error {
@@ -204,11 +283,32 @@ func IsLegacyPackage(pkg string) bool {
 // Tidy ensures that the lockfile has the set of packages corresponding to the devbox.json config.
 // It gets rid of older packages that are no longer needed.
 func (f *File) Tidy() {
+	for _, key := range f.StaleKeys() {
+		delete(f.Packages, key)
+		delete(f.Includes, key)
+	}
+}
+
+// StaleKeys returns the sorted keys of the package and include entries that
+// Tidy would remove because the config no longer needs them.
+func (f *File) StaleKeys() []string {
 	keep := f.devboxProject.AllPackageNamesIncludingRemovedTriggerPackages()
+	keep = append(keep, f.devboxProject.InactivePackageNames()...)
 	keep = append(keep, f.devboxProject.Stdenv().String())
-	maps.DeleteFunc(f.Packages, func(key string, pkg *Package) bool {
-		return !slices.Contains(keep, key)
-	})
+	stale := []string{}
+	for key := range f.Packages {
+		if !slices.Contains(keep, key) {
+			stale = append(stale, key)
+		}
+	}
+	includes := f.devboxProject.IncludeLockfileKeys()
+	for key := range f.Includes {
+		if !slices.Contains(includes, key) {
+			stale = append(stale, key)
+		}
+	}
+	slices.Sort(stale)
+	return stale
 }
 
 // IsUpToDateAndInstalled returns true if the lockfile is up to date and the
@@ -232,6 +332,15 @@ func (f *File) IsUpToDateAndInstalled(isFish bool) (bool, error) {
 }
 
 func (f *File) SetOutputsForPackage(pkg string, outputs []Output) error {
+	if err := f.SetOutputsForSystem(pkg, nix.System(), outputs); err != nil {
+		return err
+	}
+	return f.Save()
+}
+
+// SetOutputsForSystem sets the outputs of a package for system without saving
+// the lockfile.
+func (f *File) SetOutputsForSystem(pkg, system string, outputs []Output) error {
 	p, err := f.Resolve(pkg)
 	if err != nil {
 		return err
@@ -239,11 +348,11 @@ func (f *File) SetOutputsForPackage(pkg string, outputs []Output) error {
 	if p.Systems == nil {
 		p.Systems = map[string]*SystemInfo{}
 	}
-	if p.Systems[nix.System()] == nil {
-		p.Systems[nix.System()] = &SystemInfo{}
+	if p.Systems[system] == nil {
+		p.Systems[system] = &SystemInfo{}
 	}
-	p.Systems[nix.System()].Outputs = outputs
-	return f.Save()
+	p.Systems[system].Outputs = outputs
+	return nil
 }
 
 func (f *File) isDirty() (bool, error) {
@@ -262,10 +371,18 @@ func (f *File) isDirty() (bool, error) {
 	return currentHash != filesystemHash, nil
 }
 
-func lockFilePath(projectDir string) string {
+// FilePath returns the path of the lockfile of the project in projectDir.
+func FilePath(projectDir string) string {
 	return filepath.Join(projectDir, "devbox.lock")
 }
 
+// LocalFilePath returns the path of the lockfile for the packages of
+// devbox.local.json in projectDir. Like devbox.local.json, it shouldn't be
+// committed.
+func LocalFilePath(projectDir string) string {
+	return filepath.Join(projectDir, "devbox.local.lock")
+}
+
 func ResolveRunXPackage(ctx context.Context, pkg string) (types.PkgRef, error) {
 	ref, err := types.NewPkgRef(strings.TrimPrefix(pkg, pkgtype.RunXPrefix))
 	if err != nil {
@@ -0,0 +1,222 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.jetify.com/devbox/internal/cuecfg"
+)
+
+// ReadFile reads a lockfile that isn't part of a project, such as one of the
+// versions of devbox.lock that git passes to a merge driver. An empty file is
+// read as a lockfile without packages.
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	f, err := LoadBytes(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse lockfile %s", path)
+	}
+	return f, nil
+}
+
+// LoadBytes parses a lockfile that isn't part of a project. Empty data is
+// parsed as a lockfile without packages.
+func LoadBytes(data []byte) (*File, error) {
+	f := &File{LockFileVersion: lockFileVersion, Packages: map[string]*Package{}}
+	if len(data) == 0 {
+		return f, nil
+	}
+	if err := cuecfg.Unmarshal(data, ".json", f); err != nil {
+		return nil, err
+	}
+	if f.Packages == nil {
+		f.Packages = map[string]*Package{}
+	}
+	ensurePackagesHaveOutputs(f.Packages)
+	return f, nil
+}
+
+// MergeConflict is a package that both sides of a merge updated to different
+// versions, or a remote include that they pinned to different content.
+type MergeConflict struct {
+	Package string
+	Ours    string
+	Theirs  string
+}
+
+func (c MergeConflict) String() string {
+	return fmt.Sprintf("%s was updated to %s in ours and %s in theirs", c.Package, c.Ours, c.Theirs)
+}
+
+// Merge does a three-way merge of the packages in two lockfiles that were
+// changed from a common base.
+//
+// A package that only changed on one side takes that side's entry. When both
+// sides changed the same package, the side that updated its version wins, or
+// the newer resolution if they have the same version. The systems of both
+// sides are merged when they have the same resolution. It's only a conflict
+// when both sides updated a package to different versions, in which case the
+// merged lockfile keeps our entry.
+//
+// Remote include pins are merged the same way, except that both sides
+// pinning an include to different content is always a conflict.
+func Merge(base, ours, theirs *File) (*File, []MergeConflict) {
+	merged := &File{
+		LockFileVersion: max(ours.LockFileVersion, theirs.LockFileVersion),
+		Packages:        map[string]*Package{},
+	}
+	names := slices.Concat(
+		slices.Collect(maps.Keys(ours.Packages)),
+		slices.Collect(maps.Keys(theirs.Packages)),
+	)
+	slices.Sort(names)
+
+	var conflicts []MergeConflict
+	for _, name := range slices.Compact(names) {
+		pkg, conflict := mergePackage(base.Packages[name], ours.Packages[name], theirs.Packages[name])
+		if conflict {
+			conflicts = append(conflicts, MergeConflict{
+				Package: name,
+				Ours:    ours.Packages[name].Version,
+				Theirs:  theirs.Packages[name].Version,
+			})
+		}
+		if pkg != nil {
+			merged.Packages[name] = pkg
+		}
+	}
+
+	keys := slices.Concat(
+		slices.Collect(maps.Keys(ours.Includes)),
+		slices.Collect(maps.Keys(theirs.Includes)),
+	)
+	slices.Sort(keys)
+	for _, key := range slices.Compact(keys) {
+		include, conflict := mergeInclude(base.Includes[key], ours.Includes[key], theirs.Includes[key])
+		if conflict {
+			conflicts = append(conflicts, MergeConflict{
+				Package: key,
+				Ours:    ours.Includes[key].Hash,
+				Theirs:  theirs.Includes[key].Hash,
+			})
+		}
+		if include != nil {
+			if merged.Includes == nil {
+				merged.Includes = map[string]*Include{}
+			}
+			merged.Includes[key] = include
+		}
+	}
+	return merged, conflicts
+}
+
+func mergeInclude(base, ours, theirs *Include) (merged *Include, conflict bool) {
+	switch {
+	case reflect.DeepEqual(ours, theirs), reflect.DeepEqual(base, theirs):
+		return ours, false
+	case reflect.DeepEqual(base, ours):
+		return theirs, false
+	case ours == nil:
+		return theirs, false
+	case theirs == nil:
+		return ours, false
+	case ours.Hash != theirs.Hash:
+		return ours, true
+	}
+
+	// Both sides pinned the same content, but fetched different files of
+	// it.
+	result := *ours
+	result.Files = maps.Clone(ours.Files)
+	if result.Files == nil {
+		result.Files = map[string]string{}
+	}
+	for subpath, hash := range theirs.Files {
+		if pinned, ok := result.Files[subpath]; ok && pinned != hash {
+			return ours, true
+		}
+		result.Files[subpath] = hash
+	}
+	return &result, false
+}
+
+func mergePackage(base, ours, theirs *Package) (merged *Package, conflict bool) {
+	switch {
+	case reflect.DeepEqual(ours, theirs), reflect.DeepEqual(base, theirs):
+		return ours, false
+	case reflect.DeepEqual(base, ours):
+		return theirs, false
+	case ours == nil:
+		// Removed on our side but updated on theirs. Keep the update,
+		// the next install removes it if devbox.json doesn't need it.
+		return theirs, false
+	case theirs == nil:
+		return ours, false
+	}
+
+	oursUpdated := base == nil || ours.Version != base.Version
+	theirsUpdated := base == nil || theirs.Version != base.Version
+	newer, older := ours, theirs
+	switch {
+	case ours.Version == theirs.Version:
+		if theirs.lastModified().After(ours.lastModified()) {
+			newer, older = theirs, ours
+		}
+	case oursUpdated && theirsUpdated:
+		return ours, true
+	case theirsUpdated:
+		newer, older = theirs, ours
+	}
+
+	result := *newer
+	if newer.Resolved == older.Resolved {
+		var baseSystems map[string]*SystemInfo
+		if base != nil && base.Resolved == newer.Resolved {
+			baseSystems = base.Systems
+		}
+		result.Systems = mergeSystems(baseSystems, newer.Systems, older.Systems)
+	}
+	return &result, false
+}
+
+// mergeSystems does a three-way merge of the systems of a package that has
+// the same resolution on both sides. Systems that changed on both sides take
+// the preferred side's entry.
+func mergeSystems(base, preferred, other map[string]*SystemInfo) map[string]*SystemInfo {
+	if len(preferred) == 0 && len(other) == 0 {
+		return preferred
+	}
+	merged := maps.Clone(preferred)
+	if merged == nil {
+		merged = map[string]*SystemInfo{}
+	}
+	for system, info := range other {
+		if merged[system] == nil || reflect.DeepEqual(base[system], merged[system]) {
+			merged[system] = info
+		}
+	}
+	for system, info := range base {
+		// Remove systems that one side removed and the other didn't change.
+		if reflect.DeepEqual(info, preferred[system]) && other[system] == nil ||
+			reflect.DeepEqual(info, other[system]) && preferred[system] == nil {
+			delete(merged, system)
+		}
+	}
+	return merged
+}
+
+func (p *Package) lastModified() time.Time {
+	t, _ := time.Parse(time.RFC3339, p.LastModified)
+	return t
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestMerge(t *testing.T) {
+	linux := &SystemInfo{Outputs: []Output{{Name: "out", Path: "/nix/store/linux-go", Default: true}}}
+	darwin := &SystemInfo{Outputs: []Output{{Name: "out", Path: "/nix/store/darwin-go", Default: true}}}
+	base := &File{LockFileVersion: "1", Packages: map[string]*Package{
+		"go@latest":      {Version: "1.22.0", Resolved: "go-1.22.0", LastModified: "2024-01-01T00:00:00Z"},
+		"hello@latest":   {Version: "2.12", Resolved: "hello-2.12"},
+		"python@latest":  {Version: "3.11", Resolved: "python-3.11"},
+		"ripgrep@latest": {Version: "14.0", Resolved: "ripgrep-14.0"},
+	}}
+	ours := &File{LockFileVersion: "1", Packages: map[string]*Package{
+		// Both sides updated go to the same version, but we have
+		// linux store paths and they have darwin store paths.
+		"go@latest": {
+			Version: "1.23.0", Resolved: "go-1.23.0", LastModified: "2024-06-01T00:00:00Z",
+			Systems: map[string]*SystemInfo{"x86_64-linux": linux},
+		},
+		"hello@latest":   {Version: "2.12", Resolved: "hello-2.12"},
+		"python@latest":  {Version: "3.12", Resolved: "python-3.12"},
+		"ripgrep@latest": {Version: "14.0", Resolved: "ripgrep-14.0"},
+		"jq@latest":      {Version: "1.7", Resolved: "jq-1.7"},
+	}}
+	theirs := &File{LockFileVersion: "1", Packages: map[string]*Package{
+		"go@latest": {
+			Version: "1.23.0", Resolved: "go-1.23.0", LastModified: "2024-06-01T00:00:00Z",
+			Systems: map[string]*SystemInfo{"aarch64-darwin": darwin},
+		},
+		"hello@latest":   {Version: "2.12.1", Resolved: "hello-2.12.1"},
+		"python@latest":  {Version: "3.13", Resolved: "python-3.13"},
+		"gnumake@latest": {Version: "4.4", Resolved: "gnumake-4.4"},
+	}}
+
+	merged, conflicts := Merge(base, ours, theirs)
+	want := map[string]*Package{
+		"go@latest": {
+			Version: "1.23.0", Resolved: "go-1.23.0", LastModified: "2024-06-01T00:00:00Z",
+			Systems: map[string]*SystemInfo{"x86_64-linux": linux, "aarch64-darwin": darwin},
+		},
+		"hello@latest":   {Version: "2.12.1", Resolved: "hello-2.12.1"},
+		"python@latest":  {Version: "3.12", Resolved: "python-3.12"},
+		"jq@latest":      {Version: "1.7", Resolved: "jq-1.7"},
+		"gnumake@latest": {Version: "4.4", Resolved: "gnumake-4.4"},
+	}
+	if diff := cmp.Diff(want, merged.Packages, cmpopts.IgnoreUnexported(SystemInfo{})); diff != "" {
+		t.Errorf("wrong merged packages (-want +got):\n%s", diff)
+	}
+	wantConflicts := []MergeConflict{{Package: "python@latest", Ours: "3.12", Theirs: "3.13"}}
+	if diff := cmp.Diff(wantConflicts, conflicts); diff != "" {
+		t.Errorf("wrong conflicts (-want +got):\n%s", diff)
+	}
+}
+
+func TestMergeNewerResolution(t *testing.T) {
+	base := &File{Packages: map[string]*Package{
+		"go@1.22": {Version: "1.22.0", Resolved: "nixpkgs-a#go", LastModified: "2024-01-01T00:00:00Z"},
+	}}
+	ours := &File{Packages: map[string]*Package{
+		"go@1.22": {Version: "1.22.0", Resolved: "nixpkgs-b#go", LastModified: "2024-03-01T00:00:00Z"},
+	}}
+	theirs := &File{Packages: map[string]*Package{
+		"go@1.22": {Version: "1.22.0", Resolved: "nixpkgs-c#go", LastModified: "2024-05-01T00:00:00Z"},
+	}}
+
+	merged, conflicts := Merge(base, ours, theirs)
+	if len(conflicts) != 0 {
+		t.Errorf("got conflicts %v, want none", conflicts)
+	}
+	if got := merged.Packages["go@1.22"].Resolved; got != "nixpkgs-c#go" {
+		t.Errorf("got resolved %q, want the newer resolution %q", got, "nixpkgs-c#go")
+	}
+}
+
+func TestMergeIncludes(t *testing.T) {
+	base := &File{Packages: map[string]*Package{}, Includes: map[string]*Include{
+		"https://example.com/a.json": {Resolved: "https://example.com/a.json", Hash: "sha256-a1"},
+		"https://example.com/b.json": {Resolved: "https://example.com/b.json", Hash: "sha256-b1"},
+		"https://example.com/c.json": {Resolved: "https://example.com/c.json", Hash: "sha256-c1"},
+	}}
+	ours := &File{Packages: map[string]*Package{}, Includes: map[string]*Include{
+		"https://example.com/a.json": {Resolved: "https://example.com/a.json", Hash: "sha256-a2"},
+		"https://example.com/b.json": {Resolved: "https://example.com/b.json", Hash: "sha256-b1"},
+		"https://example.com/c.json": {
+			Resolved: "https://example.com/c.json", Hash: "sha256-c1",
+			Files: map[string]string{"conf/a.conf": "sha256-ca"},
+		},
+	}}
+	theirs := &File{Packages: map[string]*Package{}, Includes: map[string]*Include{
+		"https://example.com/a.json": {Resolved: "https://example.com/a.json", Hash: "sha256-a3"},
+		"https://example.com/b.json": {Resolved: "https://example.com/b.json", Hash: "sha256-b2"},
+		"https://example.com/c.json": {
+			Resolved: "https://example.com/c.json", Hash: "sha256-c1",
+			Files: map[string]string{"conf/b.conf": "sha256-cb"},
+		},
+	}}
+
+	merged, conflicts := Merge(base, ours, theirs)
+	if len(merged.Packages) != 0 {
+		t.Errorf("merged include pins into packages: %v", merged.Packages)
+	}
+	want := map[string]*Include{
+		"https://example.com/a.json": {Resolved: "https://example.com/a.json", Hash: "sha256-a2"},
+		"https://example.com/b.json": {Resolved: "https://example.com/b.json", Hash: "sha256-b2"},
+		"https://example.com/c.json": {
+			Resolved: "https://example.com/c.json", Hash: "sha256-c1",
+			Files: map[string]string{"conf/a.conf": "sha256-ca", "conf/b.conf": "sha256-cb"},
+		},
+	}
+	if diff := cmp.Diff(want, merged.Includes); diff != "" {
+		t.Errorf("wrong merged includes (-want +got):\n%s", diff)
+	}
+	wantConflicts := []MergeConflict{{Package: "https://example.com/a.json", Ours: "sha256-a2", Theirs: "sha256-a3"}}
+	if diff := cmp.Diff(wantConflicts, conflicts); diff != "" {
+		t.Errorf("wrong conflicts (-want +got):\n%s", diff)
+	}
+}
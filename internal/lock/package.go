@@ -50,6 +50,16 @@ type Output struct {
 	// Default indicates if Nix installs this output by
 	// default.
 	Default bool `json:"default,omitempty"`
+
+	// NARHash is the SRI hash of the output's NAR serialization.
+	// Devbox records it when the output is first installed so
+	// that later installs can check that the store path has
+	// the same content.
+	NARHash string `json:"nar_hash,omitempty"`
+
+	// NARSize is the size of the output's NAR serialization in
+	// bytes.
+	NARSize int64 `json:"nar_size,omitempty"`
 }
 
 func (p *Package) GetSource() string {
@@ -108,12 +118,42 @@ func (i *SystemInfo) DefaultOutputs() []Output {
 	return []Output{i.Outputs[0]}
 }
 
+// Equals reports whether two system infos have the same outputs. It ignores
+// NAR hashes and sizes, which are only recorded once an output is installed.
 func (i *SystemInfo) Equals(other *SystemInfo) bool {
 	if i == nil || other == nil {
 		return i == other
 	}
 
-	return slices.Equal(i.Outputs, other.Outputs)
+	return slices.EqualFunc(i.Outputs, other.Outputs, func(a, b Output) bool {
+		return a.Name == b.Name && a.Path == b.Path && a.Default == b.Default
+	})
+}
+
+// UnhashedOutputPaths returns the store paths of the outputs that don't have
+// a NAR hash yet.
+func (i *SystemInfo) UnhashedOutputPaths() []string {
+	// Outputs from a legacy StorePath aren't written to the lockfile.
+	if i == nil || i.outputIsFromStorePath {
+		return nil
+	}
+	paths := []string{}
+	for _, output := range i.Outputs {
+		if output.NARHash == "" {
+			paths = append(paths, output.Path)
+		}
+	}
+	return paths
+}
+
+// SetNARInfo sets the NAR hash and size of the output with a store path.
+func (i *SystemInfo) SetNARInfo(path, narHash string, narSize int64) {
+	for j := range i.Outputs {
+		if i.Outputs[j].Path == path {
+			i.Outputs[j].NARHash = narHash
+			i.Outputs[j].NARSize = narSize
+		}
+	}
 }
 
 // If we have a StorePath and no Outputs, we need to convert to the new format.
@@ -5,8 +5,12 @@
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
 	"sync"
 	"time"
 
@@ -15,6 +19,7 @@
 	"go.jetify.com/devbox/internal/boxcli/featureflag"
 	"go.jetify.com/devbox/internal/boxcli/usererr"
 	"go.jetify.com/devbox/internal/devpkg/pkgtype"
+	"go.jetify.com/devbox/internal/envir"
 	"go.jetify.com/devbox/internal/nix"
 	"go.jetify.com/devbox/internal/redact"
 	"go.jetify.com/devbox/internal/searcher"
@@ -52,6 +57,17 @@ func (f *File) FetchResolvedPackage(pkg string, refresh bool) (*Package, error)
 	if version == "" {
 		return nil, usererr.New("No version specified for %q.", name)
 	}
+	if envir.IsOffline() {
+		source := "the devbox search service"
+		if pkgtype.IsRunX(pkg) {
+			source = "the GitHub releases of its repository"
+		}
+		return nil, usererr.New(
+			"%s is not in devbox.lock and resolving it needs %s, which offline mode doesn't use. "+
+				"Run devbox install without --offline to lock it first.",
+			pkg, source,
+		)
+	}
 
 	if pkgtype.IsRunX(pkg) {
 		ref, err := ResolveRunXPackage(context.TODO(), pkg)
@@ -71,7 +87,215 @@ func (f *File) FetchResolvedPackage(pkg string, refresh bool) (*Package, error)
 	if err != nil {
 		return nil, errors.Wrapf(nix.ErrPackageNotFound, "%s@%s", name, version)
 	}
+	return packageFromSearch(name, packageVersion)
+}
+
+// NixpkgsAsOf resolves packages to the versions that nixpkgs had at a date.
+// It looks up the nixpkgs commit as of the date at most once, so a command
+// should share one NixpkgsAsOf between all the packages that it resolves.
+type NixpkgsAsOf struct {
+	Date time.Time
+
+	commit    *nixpkgsCommit
+	commitErr error
+	versions  map[string]string
+}
+
+type nixpkgsCommit struct {
+	Rev  string
+	Date time.Time
+}
+
+// NewNixpkgsAsOf returns a NixpkgsAsOf for date.
+func NewNixpkgsAsOf(date time.Time) *NixpkgsAsOf {
+	return &NixpkgsAsOf{Date: date, versions: map[string]string{}}
+}
+
+// versionAt evaluates the version of a nixpkgs attribute path at the newest
+// nixpkgs commit from before the date. It returns an empty version if the
+// attribute path didn't exist at that commit.
+func (n *NixpkgsAsOf) versionAt(ctx context.Context, attrPath string) (string, error) {
+	if version, ok := n.versions[attrPath]; ok {
+		return version, nil
+	}
+	if n.commitErr != nil {
+		return "", n.commitErr
+	}
+	if n.commit == nil {
+		commit, err := nixpkgsCommitAsOf(ctx, n.Date)
+		if err == nil {
+			// Fetch nixpkgs first so that evaluation errors only mean
+			// that an attribute path doesn't exist.
+			_, err = nix.ResolveFlake(ctx, commit.ref(), false)
+		}
+		if err != nil {
+			n.commitErr = err
+			return "", err
+		}
+		n.commit = commit
+	}
+	installable := flake.Installable{Ref: n.commit.ref(), AttrPath: attrPath + ".version"}
+	out, err := nix.Command("eval", "--raw", installable).Output(ctx)
+	if err != nil {
+		slog.Debug("attribute path isn't in nixpkgs", "attr_path", attrPath, "rev", n.commit.Rev, "err", err)
+	}
+	n.versions[attrPath] = string(out)
+	return n.versions[attrPath], nil
+}
+
+func (c *nixpkgsCommit) ref() flake.Ref {
+	return flake.Ref{Type: flake.TypeGitHub, Owner: "NixOS", Repo: "nixpkgs", Rev: c.Rev}
+}
+
+// FetchResolvedPackageAsOf is like FetchResolvedPackage, but it resolves a
+// versioned package to the newest version that matches its version constraint
+// and that was in nixpkgs at nixpkgs.Date. Flakes, runx packages and packages
+// without a version can't be resolved as of a date.
+//
+// The package is locked to the nixpkgs commit as of the date when that commit
+// has the version, so that it's built with the dependencies of the time
+// instead of those of a later rebuild.
+func (f *File) FetchResolvedPackageAsOf(ctx context.Context, pkg string, nixpkgs *NixpkgsAsOf) (*Package, error) {
+	name, constraint, versioned := searcher.ParseVersionedPackage(pkg)
+	if !versioned || pkgtype.IsFlake(pkg) || pkgtype.IsRunX(pkg) {
+		return nil, usererr.New(
+			"%s can't be resolved as of a date, only versioned nixpkgs packages such as %s@latest can",
+			pkg, name,
+		)
+	}
+	results, err := searcher.Client().Search(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	versionAt := func(attrPath string) (string, error) {
+		return nixpkgs.versionAt(ctx, attrPath)
+	}
+	newest, attrPath, err := newestVersionAsOf(results, name, constraint, nixpkgs.Date, versionAt)
+	if err != nil {
+		return nil, err
+	}
+	if newest == nil {
+		return nil, usererr.New(
+			"no version of %s matching %q was published before %s",
+			name, constraint, nixpkgs.Date.Format(time.DateOnly),
+		)
+	}
+	if attrPath == "" {
+		return packageFromSearch(name, newest)
+	}
+	// The search results only have store paths for the newest commit of
+	// the version, so they're left out and found when installing.
+	return &Package{
+		LastModified: nixpkgs.commit.Date.UTC().Format(time.RFC3339),
+		Resolved:     fmt.Sprintf("github:NixOS/nixpkgs/%s#%s", nixpkgs.commit.Rev, attrPath),
+		Version:      newest.Version,
+		Source:       devboxSearchSource,
+	}, nil
+}
+
+// newestVersionAsOf returns the newest version of a package in search results
+// that matches a version constraint and was in nixpkgs before asOf, or nil if
+// there isn't one. If versionAt confirmed the version at the nixpkgs commit as
+// of the date, the attribute path that has it is returned too.
+//
+// The search results have the newest commit for each version. A version
+// whose newest commit is from before asOf is locked to that commit. Otherwise
+// the version was current on asOf if versionAt reports it for one of its
+// attribute paths. When the backend records when a version was first seen,
+// versions first seen after asOf are skipped without evaluating them, and
+// versions first seen before asOf are accepted even if versionAt doesn't
+// find them.
+func newestVersionAsOf(
+	results *searcher.SearchResults,
+	name, constraint string,
+	asOf time.Time,
+	versionAt func(attrPath string) (string, error),
+) (*searcher.PackageVersion, string, error) {
+	var candidates []*searcher.PackageVersion
+	for _, result := range results.Packages {
+		if result.Name != name {
+			continue
+		}
+		for i, v := range result.Versions {
+			if searcher.VersionMatches(constraint, v.Version) {
+				candidates = append(candidates, &result.Versions[i])
+			}
+		}
+	}
+	slices.SortStableFunc(candidates, func(a, b *searcher.PackageVersion) int {
+		return searcher.CompareVersions(b.Version, a.Version)
+	})
+
+	for _, v := range candidates {
+		info, err := selectForSystem(v.Systems)
+		if err != nil {
+			info = v.PackageInfo
+		}
+		if time.Unix(int64(info.LastUpdated), 0).Before(asOf) {
+			return v, "", nil
+		}
+		firstSeenBefore := info.FirstUpdated != 0 && time.Unix(int64(info.FirstUpdated), 0).Before(asOf)
+		if info.FirstUpdated != 0 && !firstSeenBefore {
+			continue
+		}
+		for _, attrPath := range info.AttrPaths {
+			version, err := versionAt(attrPath)
+			if err != nil {
+				return nil, "", err
+			}
+			if version == v.Version {
+				return v, attrPath, nil
+			}
+		}
+		if firstSeenBefore {
+			return v, "", nil
+		}
+	}
+	return nil, "", nil
+}
+
+// nixpkgsCommitAsOf returns the newest commit on the nixpkgs-unstable branch
+// from before asOf.
+func nixpkgsCommitAsOf(ctx context.Context, asOf time.Time) (*nixpkgsCommit, error) {
+	query := url.Values{
+		"sha":      {"nixpkgs-unstable"},
+		"until":    {asOf.Add(-time.Second).UTC().Format(time.RFC3339)},
+		"per_page": {"1"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"https://api.github.com/repos/NixOS/nixpkgs/commits?"+query.Encode(), nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "find the nixpkgs commit as of the date")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("find the nixpkgs commit as of the date: GitHub API returned %s", resp.Status)
+	}
+	var commits []struct {
+		SHA    string `json:"sha"`
+		Commit struct {
+			Committer struct {
+				Date time.Time `json:"date"`
+			} `json:"committer"`
+		} `json:"commit"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&commits); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(commits) == 0 {
+		return nil, usererr.New("nixpkgs has no commits from before %s", asOf.Format(time.DateOnly))
+	}
+	return &nixpkgsCommit{Rev: commits[0].SHA, Date: commits[0].Commit.Committer.Date}, nil
+}
 
+// packageFromSearch returns the lockfile entry for a package version from the
+// /v1 search endpoints.
+func packageFromSearch(name string, packageVersion *searcher.PackageVersion) (*Package, error) {
 	sysInfos, err := buildLockSystemInfos(packageVersion)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,160 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package lock
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.jetify.com/devbox/internal/envir"
+	"go.jetify.com/devbox/internal/searcher"
+)
+
+func TestFetchResolvedPackageAsOf(t *testing.T) {
+	// Each commit has the versions that were current on its date. Most
+	// versions are still current in later commits.
+	commits := []struct {
+		date     string
+		versions []string
+	}{
+		{"2023-10-01", []string{"1.21.3"}},
+		{"2024-05-15", []string{"1.21.9", "1.22.5"}},
+		{"2024-05-20", []string{"1.21.9", "1.22.5"}},
+		{"2024-08-01", []string{"1.21.9", "1.22.7"}},
+		{"2024-09-01", []string{"1.22.7", "1.23.1"}},
+		{"2024-11-01", []string{"1.22.7", "1.23.1"}},
+	}
+	index := &searcher.Index{}
+	for _, commit := range commits {
+		day, _ := time.Parse(time.DateOnly, commit.date)
+		for _, version := range commit.versions {
+			index.Add(searcher.IndexEntry{
+				Name:        "go",
+				AttrPath:    "go_" + strings.ReplaceAll(version[:4], ".", "_"),
+				Version:     version,
+				Commit:      "commit-" + commit.date,
+				LastUpdated: day.Unix(),
+				Systems:     []string{"x86_64-linux"},
+			})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "index.json")
+	if err := index.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(envir.DevboxSearchIndex, path)
+
+	// nixpkgsAsOf returns a NixpkgsAsOf whose commit as of the date is
+	// already known, so that the test doesn't need GitHub or nix.
+	nixpkgsAsOf := func(asOf time.Time) *NixpkgsAsOf {
+		nixpkgs := NewNixpkgsAsOf(asOf)
+		nixpkgs.commit = &nixpkgsCommit{Rev: "as-of-commit", Date: asOf.Add(-time.Hour)}
+		for _, commit := range commits {
+			if day, _ := time.Parse(time.DateOnly, commit.date); !day.Before(asOf) {
+				break
+			}
+			for _, version := range commit.versions {
+				nixpkgs.versions["go_"+strings.ReplaceAll(version[:4], ".", "_")] = version
+			}
+		}
+		return nixpkgs
+	}
+
+	lockfile := &File{Packages: map[string]*Package{}}
+	for _, test := range []struct {
+		pkg, asOf, want, wantResolved string
+	}{
+		{"go@latest", "2024-06-01", "1.22.5", "github:NixOS/nixpkgs/commit-2024-05-20#go_1_22"},
+		{"go@latest", "2024-08-15", "1.22.7", "github:NixOS/nixpkgs/as-of-commit#go_1_22"},
+		{"go@latest", "2024-10-01", "1.23.1", "github:NixOS/nixpkgs/as-of-commit#go_1_23"},
+		{"go@1.21", "2024-06-01", "1.21.9", "github:NixOS/nixpkgs/as-of-commit#go_1_21"},
+		{"go@1.21", "2024-05-01", "1.21.3", "github:NixOS/nixpkgs/commit-2023-10-01#go_1_21"},
+		{"go@1.22.5", "2024-12-01", "1.22.5", "github:NixOS/nixpkgs/commit-2024-05-20#go_1_22"},
+	} {
+		asOf, _ := time.Parse(time.DateOnly, test.asOf)
+		resolved, err := lockfile.FetchResolvedPackageAsOf(context.Background(), test.pkg, nixpkgsAsOf(asOf))
+		if err != nil {
+			t.Errorf("FetchResolvedPackageAsOf(%q, %s) error: %v", test.pkg, test.asOf, err)
+			continue
+		}
+		if resolved.Version != test.want {
+			t.Errorf("FetchResolvedPackageAsOf(%q, %s) = %s, want %s",
+				test.pkg, test.asOf, resolved.Version, test.want)
+		}
+		if resolved.Resolved != test.wantResolved {
+			t.Errorf("FetchResolvedPackageAsOf(%q, %s) resolved to %s, want %s",
+				test.pkg, test.asOf, resolved.Resolved, test.wantResolved)
+		}
+	}
+
+	asOf, _ := time.Parse(time.DateOnly, "2023-01-01")
+	_, err := lockfile.FetchResolvedPackageAsOf(context.Background(), "go@latest", nixpkgsAsOf(asOf))
+	if err == nil || !strings.Contains(err.Error(), "published before 2023-01-01") {
+		t.Errorf("FetchResolvedPackageAsOf before the first version error = %v, want not published error", err)
+	}
+	_, err = lockfile.FetchResolvedPackageAsOf(context.Background(), "github:NixOS/nixpkgs#go", nixpkgsAsOf(asOf))
+	if err == nil || !strings.Contains(err.Error(), "can't be resolved as of a date") {
+		t.Errorf("FetchResolvedPackageAsOf of a flake error = %v, want can't be resolved error", err)
+	}
+}
+
+func TestNewestVersionAsOfWithoutFirstUpdated(t *testing.T) {
+	// The search service only has the newest commit of each version, so
+	// versions that were current on the date have later commits.
+	lastUpdated := map[string]string{
+		"1.23.1": "2024-11-01",
+		"1.22.7": "2024-11-01",
+		"1.22.5": "2024-07-31",
+		"1.21.3": "2024-02-01",
+	}
+	pkg := searcher.Package{Name: "go"}
+	for version, date := range lastUpdated {
+		day, _ := time.Parse(time.DateOnly, date)
+		info := searcher.PackageInfo{
+			LastUpdated: int(day.Unix()),
+			AttrPaths:   []string{"go_" + strings.ReplaceAll(version[:4], ".", "_")},
+			Version:     version,
+		}
+		pkg.Versions = append(pkg.Versions, searcher.PackageVersion{
+			PackageInfo: info,
+			Name:        "go",
+			Systems:     map[string]searcher.PackageInfo{"x86_64-linux": info},
+		})
+	}
+	results := &searcher.SearchResults{Packages: []searcher.Package{pkg}}
+
+	// nixpkgs on 2024-06-01 had go_1_22 at 1.22.5 and no go_1_23.
+	nixpkgs := map[string]string{"go_1_21": "1.21.3", "go_1_22": "1.22.5"}
+	var evaluated []string
+	versionAt := func(attrPath string) (string, error) {
+		evaluated = append(evaluated, attrPath)
+		return nixpkgs[attrPath], nil
+	}
+
+	asOf, _ := time.Parse(time.DateOnly, "2024-06-01")
+	for constraint, want := range map[string]struct{ version, attrPath string }{
+		"latest": {"1.22.5", "go_1_22"},
+		"1.22":   {"1.22.5", "go_1_22"},
+		"1.21":   {"1.21.3", ""},
+	} {
+		got, attrPath, err := newestVersionAsOf(results, "go", constraint, asOf, versionAt)
+		if err != nil {
+			t.Fatalf("newestVersionAsOf(go@%s) error: %v", constraint, err)
+		}
+		if got == nil || got.Version != want.version {
+			t.Errorf("newestVersionAsOf(go@%s) = %v, want %s", constraint, got, want.version)
+		}
+		if attrPath != want.attrPath {
+			t.Errorf("newestVersionAsOf(go@%s) attribute path = %q, want %q", constraint, attrPath, want.attrPath)
+		}
+	}
+	for _, attrPath := range evaluated {
+		if attrPath == "go_1_21" {
+			t.Errorf("newestVersionAsOf evaluated %s, but its newest commit is before the date", attrPath)
+		}
+	}
+}
@@ -124,5 +124,13 @@ func printDevEnvCacheHash(profileDir string) (string, error) {
 }
 
 func getLockfileHash(projectDir string) (string, error) {
-	return cachehash.JSONFile(lockFilePath(projectDir))
+	hash, err := cachehash.JSONFile(FilePath(projectDir))
+	if err != nil {
+		return "", err
+	}
+	localHash, err := cachehash.JSONFile(LocalFilePath(projectDir))
+	if err != nil || localHash == "" {
+		return hash, err
+	}
+	return cachehash.Bytes([]byte(hash + localHash)), nil
 }
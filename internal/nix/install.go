@@ -17,6 +17,7 @@
 
 	"go.jetify.com/devbox/internal/boxcli/usererr"
 	"go.jetify.com/devbox/internal/cmdutil"
+	"go.jetify.com/devbox/internal/envir"
 	"go.jetify.com/devbox/internal/fileutil"
 	"go.jetify.com/devbox/nix"
 )
@@ -74,6 +75,11 @@ func EnsureNixInstalled(ctx context.Context, writer io.Writer, withDaemonFunc fu
 		)
 	}
 
+	if envir.IsOffline() {
+		return usererr.New("Nix is not installed and can't be installed in offline mode " +
+			"because the installer has to be downloaded.")
+	}
+
 	color.Yellow("\nNix is not installed. Devbox will attempt to install it.\n\n")
 
 	installer := nix.Installer{}
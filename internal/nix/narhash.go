@@ -0,0 +1,187 @@
+package nix
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"go.jetify.com/devbox/internal/debug"
+)
+
+// PathInfo is the NAR hash and size of a store path.
+type PathInfo struct {
+	// NARHash is the SRI hash of the path's NAR serialization, such as
+	// "sha256-...".
+	NARHash string
+	NARSize int64
+}
+
+// PathInfos returns the NAR hashes and sizes of store paths in a store, which
+// is the local store if store is empty. Paths that aren't in the store are
+// omitted.
+func PathInfos(ctx context.Context, store string, paths []string) (map[string]PathInfo, error) {
+	defer debug.FunctionTimer().End()
+	return pathInfos(ctx, store, paths, false)
+}
+
+// Closure is the combined closure of a set of store paths.
+type Closure struct {
+	// Paths is the number of store paths in the closure.
+	Paths   int
+	NARSize int64
+}
+
+// ClosureOf returns the combined closure of store paths in a store, which is
+// the local store if store is empty. Paths that are in the closure of more
+// than one of the store paths are only counted once.
+func ClosureOf(ctx context.Context, store string, paths []string) (Closure, error) {
+	defer debug.FunctionTimer().End()
+	infos, err := pathInfos(ctx, store, paths, true)
+	if err != nil {
+		return Closure{}, err
+	}
+	closure := Closure{Paths: len(infos)}
+	for _, info := range infos {
+		closure.NARSize += info.NARSize
+	}
+	return closure, nil
+}
+
+func pathInfos(ctx context.Context, store string, paths []string, recursive bool) (map[string]PathInfo, error) {
+	if len(paths) == 0 {
+		return map[string]PathInfo{}, nil
+	}
+
+	cmd := Command("path-info", "--json")
+	if recursive {
+		cmd.Args = append(cmd.Args, "--recursive")
+	}
+	if store == "" {
+		cmd.Args = append(cmd.Args, "--offline")
+	} else {
+		cmd.Args = append(cmd.Args, "--store", store)
+	}
+	cmd.Args = appendArgs(cmd.Args, paths)
+	out, err := cmd.Output(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parsePathInfos(out)
+}
+
+type jsonPathInfo struct {
+	Path    string `json:"path"`
+	NARHash string `json:"narHash"`
+	NARSize int64  `json:"narSize"`
+	Valid   *bool  `json:"valid"`
+}
+
+// parsePathInfos parses the output of `nix path-info --json`, which is an
+// object keyed by store path in newer versions of nix and an array in older
+// versions (see parseStorePathFromInstallableOutput).
+func parsePathInfos(output []byte) (map[string]PathInfo, error) {
+	var infos []jsonPathInfo
+	var modern map[string]*jsonPathInfo
+	if err := json.Unmarshal(output, &modern); err == nil {
+		for path, info := range modern {
+			if info != nil {
+				info.Path = path
+				infos = append(infos, *info)
+			}
+		}
+	} else if err := json.Unmarshal(output, &infos); err != nil {
+		return nil, fmt.Errorf("failed to parse path-info output: %s", output)
+	}
+
+	result := map[string]PathInfo{}
+	for _, info := range infos {
+		if info.NARHash == "" || info.Valid != nil && !*info.Valid {
+			continue
+		}
+		hash, err := sriHash(info.NARHash)
+		if err != nil {
+			return nil, fmt.Errorf("path-info for %s: %w", info.Path, err)
+		}
+		result[info.Path] = PathInfo{NARHash: hash, NARSize: info.NARSize}
+	}
+	return result, nil
+}
+
+// modifiedPathRe matches the error that `nix store verify` prints for a path
+// whose contents don't match its hash in the store database.
+var modifiedPathRe = regexp.MustCompile(`path '([^']+)' was modified!`)
+
+// ModifiedStorePaths checks the contents of local store paths against the
+// hashes in the store database and returns the paths that were modified
+// after they were added to the store.
+func ModifiedStorePaths(ctx context.Context, paths []string) ([]string, error) {
+	defer debug.FunctionTimer().End()
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	// --no-trust skips signature checks, locally built paths aren't
+	// signed.
+	cmd := Command("store", "verify", "--no-trust")
+	cmd.Args = appendArgs(cmd.Args, paths)
+	_, err := cmd.Output(ctx)
+	if err == nil {
+		return nil, nil
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return nil, err
+	}
+	var modified []string
+	for _, match := range modifiedPathRe.FindAllSubmatch(exitErr.Stderr, -1) {
+		modified = append(modified, string(match[1]))
+	}
+	if len(modified) == 0 {
+		return nil, err
+	}
+	return modified, nil
+}
+
+// sriHash converts a hash in nix's "<algo>:<base32>" format to an SRI hash
+// ("<algo>-<base64>") so that hashes from different versions of nix can be
+// compared. SRI hashes are returned as-is.
+func sriHash(hash string) (string, error) {
+	algo, digest, ok := strings.Cut(hash, ":")
+	if !ok {
+		return hash, nil
+	}
+	b, err := decodeNixBase32(digest)
+	if err != nil {
+		return "", err
+	}
+	return algo + "-" + base64.StdEncoding.EncodeToString(b), nil
+}
+
+const nixBase32Alphabet = "0123456789abcdfghijklmnpqrsvwxyz"
+
+// decodeNixBase32 decodes nix's base32 encoding, which uses its own alphabet
+// and encodes the bytes in reverse order.
+func decodeNixBase32(s string) ([]byte, error) {
+	out := make([]byte, len(s)*5/8)
+	for n := 0; n < len(s); n++ {
+		c := s[len(s)-n-1]
+		digit := strings.IndexByte(nixBase32Alphabet, c)
+		if digit == -1 {
+			return nil, fmt.Errorf("invalid character %q in base32 hash %q", c, s)
+		}
+		b := n * 5
+		i, j := b/8, uint(b%8)
+		out[i] |= byte(digit << j)
+		if carry := byte(digit >> (8 - j)); i+1 < len(out) {
+			out[i+1] |= carry
+		} else if carry != 0 {
+			return nil, fmt.Errorf("invalid base32 hash %q", s)
+		}
+	}
+	return out, nil
+}
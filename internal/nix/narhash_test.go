@@ -0,0 +1,38 @@
+package nix
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParsePathInfos(t *testing.T) {
+	want := map[string]PathInfo{
+		"/nix/store/a-hello": {
+			// sha256 of the empty string.
+			NARHash: "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+			NARSize: 128,
+		},
+	}
+	tests := map[string]string{
+		"modern": `{
+  "/nix/store/a-hello": {"narHash": "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", "narSize": 128},
+  "/nix/store/b-missing": null
+}`,
+		"legacy": `[
+  {"path": "/nix/store/a-hello", "narHash": "sha256:0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73", "narSize": 128, "valid": true},
+  {"path": "/nix/store/b-missing", "valid": false}
+]`,
+	}
+	for name, output := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parsePathInfos([]byte(output))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("wrong path infos (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package nix
 
 import (
+	"go.jetify.com/devbox/internal/envir"
 	"go.jetify.com/devbox/nix"
 )
 
@@ -38,7 +39,15 @@
 var Default = nix.Default
 
 func AtLeast(version string) bool              { return nix.AtLeast(version) }
-func Command(args ...any) *Cmd                 { return nix.Command(args...) }
 func SourceProfile() (sourced bool, err error) { return nix.SourceProfile() }
 func System() string                           { return nix.System() }
 func Version() string                          { return nix.Version() }
+
+// Command creates a nix command. In offline mode it passes --offline so that
+// nix only uses the local store and cached flake inputs.
+func Command(args ...any) *Cmd {
+	if envir.IsOffline() {
+		args = append([]any{"--offline"}, args...)
+	}
+	return nix.Command(args...)
+}
@@ -50,6 +50,50 @@ func StorePathsFromInstallable(ctx context.Context, installable string, allowIns
 	return maps.Keys(paths), nil
 }
 
+// SystemOutput is an output of a package that was evaluated for a specific
+// system.
+type SystemOutput struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Default bool   `json:"default"`
+}
+
+// systemOutputsExpr maps a derivation to a list of SystemOutputs. Default
+// outputs are the ones that nix installs, which are listed in
+// meta.outputsToInstall.
+const systemOutputsExpr = `drv:
+  let default = drv.meta.outputsToInstall or [ "out" ];
+  in map (name: {
+    inherit name;
+    path = drv.${name}.outPath;
+    default = builtins.elem name default;
+  }) (drv.outputs or [ "out" ])`
+
+// OutputsForSystem evaluates the store paths of a flake installable's outputs
+// for system, which doesn't need to be the current system. The installable
+// is only evaluated, not built, so it works without a builder for system.
+func OutputsForSystem(ctx context.Context, installable, system string, allowInsecure bool) ([]SystemOutput, error) {
+	defer debug.FunctionTimer().End()
+
+	// --impure for NIXPKGS_ALLOW_UNFREE
+	cmd := Command("eval", FixInstallableArg(installable),
+		"--json", "--impure", "--system", system, "--apply", systemOutputsExpr)
+	cmd.Env = allowUnfreeEnv(os.Environ())
+	if allowInsecure {
+		cmd.Env = allowInsecureEnv(cmd.Env)
+	}
+
+	out, err := cmd.Output(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var outputs []SystemOutput
+	if err := json.Unmarshal(out, &outputs); err != nil {
+		return nil, fmt.Errorf("failed to parse outputs of %s for %s: %w", installable, system, err)
+	}
+	return outputs, nil
+}
+
 // StorePathsAreInStore a map of store paths to whether they are in the store.
 func StorePathsAreInStore(ctx context.Context, storePaths []string) (map[string]bool, error) {
 	defer debug.FunctionTimer().End()
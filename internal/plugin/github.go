@@ -16,6 +16,7 @@
 	"github.com/samber/lo"
 	"go.jetify.com/devbox/internal/boxcli/usererr"
 	"go.jetify.com/devbox/internal/cachehash"
+	"go.jetify.com/devbox/internal/envir"
 	"go.jetify.com/devbox/nix/flake"
 	"go.jetify.com/pkg/filecache"
 )
@@ -90,6 +91,9 @@ func (p *githubPlugin) FileContent(subpath string) ([]byte, error) {
 		}
 	}
 
+	if envir.IsOffline() {
+		return cachedOffline(githubCache, contentURL+ttl.String(), p.LockfileKey(), contentURL)
+	}
 	return githubCache.GetOrSet(
 		contentURL+ttl.String(),
 		func() ([]byte, time.Duration, error) {
@@ -131,6 +135,22 @@ func() ([]byte, time.Duration, error) {
 	)
 }
 
+// cachedOffline returns plugin content from cache in offline mode, even if
+// it's expired.
+func cachedOffline(cache *filecache.Cache[[]byte], key, plugin, contentURL string) ([]byte, error) {
+	content, err := cache.Get(key)
+	if err == nil || errors.Is(err, filecache.Expired) {
+		return content, nil
+	}
+	if errors.Is(err, filecache.NotFound) {
+		return nil, usererr.New(
+			"plugin %s is not cached and can't be downloaded from %s in offline mode",
+			plugin, contentURL,
+		)
+	}
+	return nil, err
+}
+
 func (p *githubPlugin) url(subpath string) (string, error) {
 	// Github redirects "master" to "main" in new repos. They don't do the reverse
 	// so setting master here is better.
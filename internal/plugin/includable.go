@@ -28,6 +28,8 @@ func parseIncludable(includableRef, workingDir string) (Includable, error) {
 		return newGithubPlugin(ref)
 	case flake.TypeGit:
 		return newGitPlugin(ref)
+	case flake.TypeFile, flake.TypeTarball:
+		return newRemotePlugin(ref)
 	default:
 		return nil, fmt.Errorf("unsupported ref type %q", ref.Type)
 	}
@@ -20,6 +20,11 @@ func LoadConfigFromInclude(include string, lockfile *lock.File, workingDir strin
 		if err != nil {
 			return nil, err
 		}
+		if remote, ok := includable.(*remotePlugin); ok {
+			if err := remote.verifyLocked(lockfile); err != nil {
+				return nil, err
+			}
+		}
 	}
 	return getConfigIfAny(includable, lockfile.ProjectDir())
 }
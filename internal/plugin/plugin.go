@@ -11,8 +11,10 @@
 	"log/slog"
 	"os"
 	"path/filepath"
+	"reflect"
 	"slices"
 	"strings"
+	"sync"
 	"text/template"
 
 	"github.com/pkg/errors"
@@ -52,8 +54,16 @@ type PluginOnlyData struct {
 	// 1. Built-in plugins are triggered by packages (See plugins.builtInMap)
 	// 2. Plugins can be added via the "include" field in devbox.json or plugin.json
 	Source Includable
+	// Diagnostics are the problems found when validating the plugin.json
+	// against configSchema. They don't prevent the plugin from loading.
+	Diagnostics []configfile.Diagnostic `json:"-"`
 }
 
+// configSchema is the schema of a plugin.json file.
+var configSchema = sync.OnceValue(func() *configfile.Schema {
+	return configfile.GenerateSchema(reflect.TypeFor[Config]())
+})
+
 func (c *Config) ProcessComposeYaml() (string, string) {
 	for file, contentPath := range c.CreateFiles {
 		if strings.HasSuffix(file, "process-compose.yaml") || strings.HasSuffix(file, "process-compose.yml") {
@@ -198,7 +208,20 @@ func buildConfig(pkg Includable, projectDir, content string) (*Config, error) {
 		return nil, err
 	}
 
-	return cfg, errors.WithStack(json.Unmarshal(jsonb, cfg))
+	cfg.Diagnostics, err = configfile.Validate(buf.Bytes(), configSchema())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := json.Unmarshal(jsonb, cfg); err != nil {
+		if len(cfg.Diagnostics) > 0 {
+			return nil, &configfile.ValidationError{
+				Name:        pkg.LockfileKey(),
+				Diagnostics: cfg.Diagnostics,
+			}
+		}
+		return nil, errors.WithStack(err)
+	}
+	return cfg, nil
 }
 
 func jsonPurifyPluginContent(content []byte) ([]byte, error) {
@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.jetify.com/devbox/plugins"
+)
+
+func TestBuildConfigDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	content := `{
+  "name": "my-plugin",
+  "create_files": {},
+  "shell": {
+    "init_hooks": ["echo hi"]
+  }
+}`
+	if err := os.WriteFile(filepath.Join(dir, "plugin.json"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	includable, err := parseIncludable("path:./plugin.json", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := getConfigIfAny(includable, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Diagnostics) != 1 {
+		t.Fatalf("got diagnostics %v, want 1 diagnostic", cfg.Diagnostics)
+	}
+	want := `5:5: unknown field "init_hooks" in "shell" (did you mean "init_hook"?)`
+	if got := cfg.Diagnostics[0].String(); got != want {
+		t.Errorf("got diagnostic %q, want %q", got, want)
+	}
+}
+
+func TestBuiltinsHaveNoDiagnostics(t *testing.T) {
+	entries, err := plugins.Builtins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		t.Run(entry.Name(), func(t *testing.T) {
+			content, err := plugins.BuiltInForPackage(strings.TrimSuffix(entry.Name(), ".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			includable := &LocalPlugin{name: entry.Name()}
+			cfg, err := buildConfig(includable, t.TempDir(), string(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range cfg.Diagnostics {
+				t.Errorf("unexpected diagnostic: %s", d)
+			}
+		})
+	}
+}
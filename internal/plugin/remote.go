@@ -0,0 +1,394 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mholt/archives"
+	"github.com/pkg/errors"
+	"go.jetify.com/devbox/internal/boxcli/usererr"
+	"go.jetify.com/devbox/internal/cachehash"
+	"go.jetify.com/devbox/internal/envir"
+	"go.jetify.com/devbox/internal/lock"
+	"go.jetify.com/devbox/nix/flake"
+	"go.jetify.com/pkg/filecache"
+)
+
+var remoteCache = filecache.New[[]byte]("devbox/plugin/remote")
+
+// remotePlugin is a plugin included from a URL, such as
+// "https://example.com/plugins/postgres/plugin.json". The URL can point to a
+// plugin.json file (flake.TypeFile) or to an archive containing one
+// (flake.TypeTarball).
+//
+// The content of a remote plugin is pinned in the lockfile. For file includes
+// the pinned content is the plugin.json itself, and each file that the plugin
+// fetches besides it is pinned the first time it's fetched. For tarballs it
+// is the whole archive (and therefore every file the plugin creates).
+type remotePlugin struct {
+	ref  flake.Ref
+	name string
+
+	// lockfile is the lockfile that the plugin was verified against. The
+	// plugin's other files are pinned in it.
+	lockfile *lock.File
+}
+
+var remoteNameRegexp = regexp.MustCompile("[^a-zA-Z0-9-_.]+")
+
+func newRemotePlugin(ref flake.Ref) (*remotePlugin, error) {
+	plugin := &remotePlugin{ref: ref}
+	name, err := getPluginNameFromContent(plugin)
+	if err != nil && !errors.Is(err, errNameMissing) {
+		return nil, err
+	}
+	if name == "" {
+		// Like github plugins, fall back to a name derived from where
+		// the plugin lives.
+		u, err := url.Parse(ref.URL)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		dir := strings.TrimSuffix(u.Path, pluginConfigName)
+		name = u.Host + "." + strings.ReplaceAll(strings.Trim(dir, "/"), "/", ".")
+		if ref.Dir != "" {
+			name += "." + strings.ReplaceAll(ref.Dir, "/", ".")
+		}
+	}
+	plugin.name = remoteNameRegexp.ReplaceAllString(strings.Trim(name, "."), " ")
+	return plugin, nil
+}
+
+func (p *remotePlugin) Fetch() ([]byte, error) {
+	content, err := p.FileContent(pluginConfigName)
+	if err != nil {
+		return nil, err
+	}
+	return jsonPurifyPluginContent(content)
+}
+
+func (p *remotePlugin) CanonicalName() string {
+	return p.name
+}
+
+func (p *remotePlugin) Hash() string {
+	return cachehash.Bytes([]byte(p.ref.String()))
+}
+
+func (p *remotePlugin) LockfileKey() string {
+	return p.ref.String()
+}
+
+func (p *remotePlugin) FileContent(subpath string) ([]byte, error) {
+	if p.ref.Type == flake.TypeTarball {
+		archive, err := p.download(p.ref.URL)
+		if err != nil {
+			return nil, err
+		}
+		return readFromArchive(archive, path.Join(p.ref.Dir, subpath))
+	}
+
+	fileURL, err := p.fileURL(subpath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := p.download(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	if subpath := path.Clean(subpath); subpath != pluginConfigName {
+		if err := p.verifyFileLocked(subpath, content); err != nil {
+			return nil, err
+		}
+	}
+	return content, nil
+}
+
+// contentHash returns the hash of the content that is pinned in the lockfile.
+func (p *remotePlugin) contentHash() (string, error) {
+	pinnedURL, err := p.pinnedURL()
+	if err != nil {
+		return "", err
+	}
+	content, err := p.download(pinnedURL)
+	if err != nil {
+		return "", err
+	}
+	return lock.IncludeHash(content), nil
+}
+
+// pinnedURL returns the URL of the content that is pinned in the lockfile:
+// the archive for tarballs and the plugin.json for files.
+func (p *remotePlugin) pinnedURL() (string, error) {
+	if p.ref.Type == flake.TypeTarball {
+		return p.ref.URL, nil
+	}
+	return p.fileURL(pluginConfigName)
+}
+
+// verifyLocked pins the plugin's content hash in the lockfile if it isn't
+// pinned yet. Otherwise, it returns an error if the content no longer matches
+// the pinned hash.
+func (p *remotePlugin) verifyLocked(lockfile *lock.File) error {
+	p.lockfile = lockfile
+	hash, err := p.contentHash()
+	if err != nil {
+		return err
+	}
+	pinned := lockfile.PinnedIncludeHash(p.LockfileKey())
+	if pinned == "" {
+		lockfile.PinInclude(p.LockfileKey(), p.ref.URL, hash)
+		return nil
+	}
+	if pinned != hash {
+		return usererr.New(
+			"the content of include %s has changed since it was locked.\n"+
+				"Locked hash: %s\nFetched hash: %s\n"+
+				"If the change is expected, run `devbox update` to lock the new content.",
+			p.LockfileKey(), pinned, hash,
+		)
+	}
+	return nil
+}
+
+// verifyFileLocked is like verifyLocked for a file that a file include fetches
+// besides its plugin.json, such as a file in create_files.
+func (p *remotePlugin) verifyFileLocked(subpath string, content []byte) error {
+	if p.lockfile == nil {
+		return nil
+	}
+	hash := lock.IncludeHash(content)
+	pinned := p.lockfile.PinnedIncludeFiles(p.LockfileKey())[subpath]
+	if pinned == "" {
+		p.lockfile.PinIncludeFile(p.LockfileKey(), subpath, hash)
+		return nil
+	}
+	if pinned != hash {
+		return usererr.New(
+			"the content of %s in include %s has changed since it was locked.\n"+
+				"Locked hash: %s\nFetched hash: %s\n"+
+				"If the change is expected, run `devbox update` to lock the new content.",
+			subpath, p.LockfileKey(), pinned, hash,
+		)
+	}
+	return nil
+}
+
+// fileURL returns the URL of a file relative to the directory of the
+// included plugin.json.
+func (p *remotePlugin) fileURL(subpath string) (string, error) {
+	base, err := url.Parse(p.ref.URL)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	// The include can name the plugin.json directly or the directory
+	// containing it.
+	if !strings.HasSuffix(base.Path, ".json") && !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	if strings.HasSuffix(base.Path, "/") {
+		base.Path += pluginConfigName
+	}
+	rel, err := url.Parse(subpath)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return base.ResolveReference(rel).String(), nil
+}
+
+func (p *remotePlugin) download(contentURL string) ([]byte, error) {
+	ttl, err := remoteCacheTTL()
+	if err != nil {
+		return nil, err
+	}
+	if envir.IsOffline() {
+		return cachedOffline(remoteCache, contentURL+ttl.String(), p.LockfileKey(), contentURL)
+	}
+	return remoteCache.GetOrSet(
+		contentURL+ttl.String(),
+		func() ([]byte, time.Duration, error) {
+			body, err := p.get(contentURL)
+			return body, ttl, err
+		},
+	)
+}
+
+// get downloads contentURL without going through the cache.
+func (p *remotePlugin) get(contentURL string) ([]byte, error) {
+	res, err := http.Get(contentURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, usererr.New(
+			"failed to get plugin %s @ %s (Status code %d).",
+			p.LockfileKey(),
+			contentURL,
+			res.StatusCode,
+		)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// remoteCacheTTL returns how long downloaded remote plugins are cached. It's
+// the same TTL as github plugins. Changes are still detected because the
+// content hash is pinned in the lockfile.
+func remoteCacheTTL() (time.Duration, error) {
+	ttlStr := os.Getenv("DEVBOX_X_GITHUB_PLUGIN_CACHE_TTL")
+	if ttlStr == "" {
+		return 24 * time.Hour, nil
+	}
+	ttl, err := time.ParseDuration(ttlStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid DEVBOX_X_GITHUB_PLUGIN_CACHE_TTL=%q: %w", ttlStr, err)
+	}
+	return ttl, nil
+}
+
+// IncludeUpdate is a remote include whose content changed since it was
+// pinned in the lockfile.
+type IncludeUpdate struct {
+	// Include is the URL of the include.
+	Include string `json:"include"`
+	OldHash string `json:"old_hash"`
+	NewHash string `json:"new_hash"`
+
+	plugin  *remotePlugin
+	url     string
+	content []byte
+	// files is the new content of the include's other pinned files, keyed
+	// by their path relative to its plugin.json.
+	files map[string][]byte
+}
+
+// OutdatedIncludes downloads the remote plugins in includes, bypassing the
+// cache, and returns the ones whose content, or the content of one of their
+// pinned files, no longer matches the hashes in lockfile. Only https and
+// tarball includes are checked, because they're the only plugins that are
+// pinned in lockfile. Includes that aren't pinned yet are skipped because they are
+// pinned to their latest content the next time they're loaded.
+func OutdatedIncludes(includes []Includable, lockfile *lock.File) ([]*IncludeUpdate, error) {
+	var updates []*IncludeUpdate
+	checked := map[string]bool{}
+	for _, include := range includes {
+		plugin, ok := include.(*remotePlugin)
+		if !ok || checked[plugin.LockfileKey()] {
+			continue
+		}
+		checked[plugin.LockfileKey()] = true
+		pinned := lockfile.PinnedIncludeHash(plugin.LockfileKey())
+		if pinned == "" {
+			continue
+		}
+		pinnedURL, err := plugin.pinnedURL()
+		if err != nil {
+			return nil, err
+		}
+		content, err := plugin.get(pinnedURL)
+		if err != nil {
+			return nil, err
+		}
+		hash := lock.IncludeHash(content)
+		changed := hash != pinned
+		files := map[string][]byte{}
+		for subpath, pinnedFile := range lockfile.PinnedIncludeFiles(plugin.LockfileKey()) {
+			fileURL, err := plugin.fileURL(subpath)
+			if err != nil {
+				return nil, err
+			}
+			files[subpath], err = plugin.get(fileURL)
+			if err != nil {
+				return nil, err
+			}
+			changed = changed || lock.IncludeHash(files[subpath]) != pinnedFile
+		}
+		if changed {
+			updates = append(updates, &IncludeUpdate{
+				Include: plugin.ref.URL,
+				OldHash: pinned,
+				NewHash: hash,
+				plugin:  plugin,
+				url:     pinnedURL,
+				content: content,
+				files:   files,
+			})
+		}
+	}
+	return updates, nil
+}
+
+// Apply pins the new content of the include in lockfile and caches it so that
+// it's used the next time the include is loaded. Like lock.File.PinInclude, it
+// only updates the in-memory lockfile.
+func (u *IncludeUpdate) Apply(lockfile *lock.File) error {
+	ttl, err := remoteCacheTTL()
+	if err != nil {
+		return err
+	}
+	if err := remoteCache.Set(u.url+ttl.String(), u.content, ttl); err != nil {
+		return errors.WithStack(err)
+	}
+	lockfile.PinInclude(u.plugin.LockfileKey(), u.plugin.ref.URL, u.NewHash)
+	for subpath, content := range u.files {
+		fileURL, err := u.plugin.fileURL(subpath)
+		if err != nil {
+			return err
+		}
+		if err := remoteCache.Set(fileURL+ttl.String(), content, ttl); err != nil {
+			return errors.WithStack(err)
+		}
+		lockfile.PinIncludeFile(u.plugin.LockfileKey(), subpath, lock.IncludeHash(content))
+	}
+	return nil
+}
+
+// readFromArchive returns the content of the file at name in an archive. Like
+// Nix, it ignores a single top-level directory in the archive, so that
+// "plugin.json" matches both "plugin.json" and "my-plugin-v1/plugin.json".
+func readFromArchive(archive []byte, name string) ([]byte, error) {
+	ctx := context.Background()
+	format, stream, err := archives.Identify(ctx, "", bytes.NewReader(archive))
+	if err != nil {
+		return nil, errors.Wrap(err, "identify plugin archive format")
+	}
+	extractor, ok := format.(archives.Extractor)
+	if !ok {
+		return nil, errors.Errorf("unsupported plugin archive format %s", format.Extension())
+	}
+
+	var content []byte
+	err = extractor.Extract(ctx, stream, func(ctx context.Context, f archives.FileInfo) error {
+		if content != nil || !f.Mode().IsRegular() {
+			return nil
+		}
+		entry := path.Clean(f.NameInArchive)
+		_, trimmed, _ := strings.Cut(entry, "/")
+		if entry != name && trimmed != name {
+			return nil
+		}
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		content, err = io.ReadAll(r)
+		return err
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if content == nil {
+		return nil, errors.Errorf("file %s not found in plugin archive", name)
+	}
+	return content, nil
+}
@@ -0,0 +1,321 @@
+package plugin
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.jetify.com/devbox/internal/lock"
+	"go.jetify.com/devbox/nix/flake"
+)
+
+func TestRemotePluginFile(t *testing.T) {
+	t.Setenv("DEVBOX_X_GITHUB_PLUGIN_CACHE_TTL", "1ns")
+	t.Cleanup(func() { _ = remoteCache.Clear() })
+
+	pluginJSON := `{"name": "shared-postgres", "create_files": {"{{ .Virtenv }}/conf": "conf/pg.conf"}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/plugins/postgres/plugin.json":
+			_, _ = w.Write([]byte(pluginJSON))
+		case "/plugins/postgres/conf/pg.conf":
+			_, _ = w.Write([]byte("port = 5432"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	for _, include := range []string{
+		srv.URL + "/plugins/postgres/plugin.json",
+		srv.URL + "/plugins/postgres/",
+	} {
+		t.Run(include, func(t *testing.T) {
+			includable, err := parseIncludable(include, t.TempDir())
+			if err != nil {
+				t.Fatalf("parseIncludable(%q) error: %v", include, err)
+			}
+			plugin, ok := includable.(*remotePlugin)
+			if !ok {
+				t.Fatalf("parseIncludable(%q) = %T, want *remotePlugin", include, includable)
+			}
+			if got := plugin.CanonicalName(); got != "shared-postgres" {
+				t.Errorf("CanonicalName() = %q, want %q", got, "shared-postgres")
+			}
+			conf, err := plugin.FileContent("conf/pg.conf")
+			if err != nil {
+				t.Fatalf("FileContent error: %v", err)
+			}
+			if string(conf) != "port = 5432" {
+				t.Errorf("FileContent = %q, want %q", conf, "port = 5432")
+			}
+		})
+	}
+}
+
+func TestRemotePluginTarball(t *testing.T) {
+	t.Cleanup(func() { _ = remoteCache.Clear() })
+
+	archive := mkTarGz(t, map[string]string{
+		"plugins-v1/postgres/plugin.json": `{"name": "tarball-postgres"}`,
+		"plugins-v1/postgres/pg.conf":     "port = 5432",
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	}))
+	t.Cleanup(srv.Close)
+
+	include := srv.URL + "/plugins-v1.tar.gz?dir=postgres"
+	includable, err := parseIncludable(include, t.TempDir())
+	if err != nil {
+		t.Fatalf("parseIncludable(%q) error: %v", include, err)
+	}
+	if got := includable.CanonicalName(); got != "tarball-postgres" {
+		t.Errorf("CanonicalName() = %q, want %q", got, "tarball-postgres")
+	}
+	conf, err := includable.FileContent("pg.conf")
+	if err != nil {
+		t.Fatalf("FileContent error: %v", err)
+	}
+	if string(conf) != "port = 5432" {
+		t.Errorf("FileContent = %q, want %q", conf, "port = 5432")
+	}
+}
+
+func TestRemotePluginVerifyLocked(t *testing.T) {
+	t.Setenv("DEVBOX_X_GITHUB_PLUGIN_CACHE_TTL", "1ns")
+	t.Cleanup(func() { _ = remoteCache.Clear() })
+
+	content := `{"name": "pinned"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(content))
+	}))
+	t.Cleanup(srv.Close)
+
+	ref, err := flake.ParseRef(srv.URL + "/plugin.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin, err := newRemotePlugin(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lockfile := &lock.File{Packages: map[string]*lock.Package{}}
+	if err := plugin.verifyLocked(lockfile); err != nil {
+		t.Fatalf("first verifyLocked error: %v", err)
+	}
+	pinned := lockfile.PinnedIncludeHash(plugin.LockfileKey())
+	if pinned != lock.IncludeHash([]byte(content)) {
+		t.Fatalf("pinned hash = %q, want %q", pinned, lock.IncludeHash([]byte(content)))
+	}
+	if len(lockfile.Packages) != 0 {
+		t.Errorf("verifyLocked added package entries %v, want only an include pin", lockfile.Packages)
+	}
+	if err := plugin.verifyLocked(lockfile); err != nil {
+		t.Errorf("verifyLocked with unchanged content error: %v", err)
+	}
+
+	content = `{"name": "pinned", "env": {"CHANGED": "1"}}`
+	err = plugin.verifyLocked(lockfile)
+	if err == nil || !strings.Contains(err.Error(), "has changed since it was locked") {
+		t.Errorf("verifyLocked with changed content error = %v, want content changed error", err)
+	}
+
+	lockfile.UnpinIncludes()
+	if err := plugin.verifyLocked(lockfile); err != nil {
+		t.Errorf("verifyLocked after UnpinIncludes error: %v", err)
+	}
+}
+
+func TestRemotePluginFilesLocked(t *testing.T) {
+	t.Setenv("DEVBOX_X_GITHUB_PLUGIN_CACHE_TTL", "1ns")
+	t.Cleanup(func() { _ = remoteCache.Clear() })
+
+	conf := "port = 5432"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/plugin.json":
+			_, _ = w.Write([]byte(`{"name": "files", "create_files": {"{{ .Virtenv }}/pg.conf": "conf/pg.conf"}}`))
+		case "/conf/pg.conf":
+			_, _ = w.Write([]byte(conf))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	include := srv.URL + "/plugin.json"
+	ref, err := flake.ParseRef(include)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin, err := newRemotePlugin(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockfile := &lock.File{Packages: map[string]*lock.Package{}}
+	if err := plugin.verifyLocked(lockfile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plugin.FileContent("conf/pg.conf"); err != nil {
+		t.Fatalf("first FileContent error: %v", err)
+	}
+	pinned := lockfile.PinnedIncludeFiles(plugin.LockfileKey())["conf/pg.conf"]
+	if want := lock.IncludeHash([]byte(conf)); pinned != want {
+		t.Fatalf("pinned file hash = %q, want %q", pinned, want)
+	}
+
+	conf = "port = 6543"
+	_, err = plugin.FileContent("conf/pg.conf")
+	if err == nil || !strings.Contains(err.Error(), "has changed since it was locked") {
+		t.Errorf("FileContent with changed content error = %v, want content changed error", err)
+	}
+
+	updates, err := OutdatedIncludes([]Includable{plugin}, lockfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("OutdatedIncludes with a changed file returned %d updates, want 1", len(updates))
+	}
+	if err := updates[0].Apply(lockfile); err != nil {
+		t.Fatal(err)
+	}
+	got, err := plugin.FileContent("conf/pg.conf")
+	if err != nil {
+		t.Fatalf("FileContent after Apply error: %v", err)
+	}
+	if string(got) != conf {
+		t.Errorf("FileContent after Apply = %q, want %q", got, conf)
+	}
+}
+
+func TestOutdatedIncludes(t *testing.T) {
+	t.Cleanup(func() { _ = remoteCache.Clear() })
+
+	content := `{"name": "outdated"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(content))
+	}))
+	t.Cleanup(srv.Close)
+
+	include := srv.URL + "/plugin.json"
+	ref, err := flake.ParseRef(include)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin, err := newRemotePlugin(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two plugins can include the same remote plugin, which is only
+	// checked once.
+	includes := []Includable{plugin, &LocalPlugin{}, plugin}
+	lockfile := &lock.File{Packages: map[string]*lock.Package{}}
+	if err := plugin.verifyLocked(lockfile); err != nil {
+		t.Fatal(err)
+	}
+
+	updates, err := OutdatedIncludes(includes, lockfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 0 {
+		t.Errorf("OutdatedIncludes with unchanged content returned %d updates, want 0", len(updates))
+	}
+
+	content = `{"name": "outdated", "env": {"CHANGED": "1"}}`
+	updates, err = OutdatedIncludes(includes, lockfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 1 || updates[0].Include != include {
+		t.Fatalf("OutdatedIncludes with changed content returned %d updates, want 1 for %s", len(updates), include)
+	}
+	// Checking for updates doesn't change what's cached for the pinned
+	// content.
+	if err := plugin.verifyLocked(lockfile); err != nil {
+		t.Errorf("verifyLocked before Apply error: %v", err)
+	}
+
+	if err := updates[0].Apply(lockfile); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lockfile.PinnedIncludeHash(plugin.LockfileKey()), lock.IncludeHash([]byte(content)); got != want {
+		t.Errorf("pinned hash after Apply = %q, want %q", got, want)
+	}
+	if err := plugin.verifyLocked(lockfile); err != nil {
+		t.Errorf("verifyLocked after Apply error: %v", err)
+	}
+}
+
+func TestRemotePluginOffline(t *testing.T) {
+	// Cached content expires immediately, but offline mode still uses it.
+	t.Setenv("DEVBOX_X_GITHUB_PLUGIN_CACHE_TTL", "1ns")
+	t.Cleanup(func() { _ = remoteCache.Clear() })
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/plugin.json":
+			_, _ = w.Write([]byte(`{"name": "offline"}`))
+		case "/conf/app.conf":
+			_, _ = w.Write([]byte("offline = true"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	ref, err := flake.ParseRef(srv.URL + "/plugin.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin, err := newRemotePlugin(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plugin.FileContent("conf/app.conf"); err != nil {
+		t.Fatalf("online FileContent error: %v", err)
+	}
+	srv.Close()
+
+	t.Setenv("DEVBOX_OFFLINE", "1")
+	conf, err := plugin.FileContent("conf/app.conf")
+	if err != nil {
+		t.Fatalf("offline FileContent error: %v", err)
+	}
+	if string(conf) != "offline = true" {
+		t.Errorf("offline FileContent = %q, want %q", conf, "offline = true")
+	}
+	_, err = plugin.FileContent("conf/other.conf")
+	if err == nil || !strings.Contains(err.Error(), "offline mode") {
+		t.Errorf("offline FileContent of uncached file error = %v, want offline error", err)
+	}
+}
+
+func mkTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -1,5 +1,8 @@
 package plugin
 
 func Update() error {
-	return githubCache.Clear()
+	if err := githubCache.Clear(); err != nil {
+		return err
+	}
+	return remoteCache.Clear()
 }
@@ -63,7 +63,7 @@ func profileIsNotEmpty(path string) (bool, error) {
 		return false, errors.WithStack(err)
 	}
 	for _, entry := range entries {
-		if entry.Name() != configfile.DefaultName ||
+		if !configfile.IsConfigName(entry.Name()) ||
 			isModifiedConfig(filepath.Join(path, entry.Name())) {
 			return true, nil
 		}
@@ -71,11 +71,14 @@ func profileIsNotEmpty(path string) (bool, error) {
 	return false, nil
 }
 
+// isModifiedConfig reports whether a config file in the global profile was
+// changed by the user. Devbox only creates devbox.json, so a config in any
+// other format is the user's own.
 func isModifiedConfig(path string) bool {
 	if filepath.Base(path) == configfile.DefaultName {
 		return !devconfig.IsDefault(path)
 	}
-	return false
+	return true
 }
 
 // urlIsArchive checks if a file URL points to an archive file
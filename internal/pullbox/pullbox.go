@@ -14,6 +14,7 @@
 
 	"go.jetify.com/devbox/internal/boxcli/usererr"
 	"go.jetify.com/devbox/internal/devbox/devopt"
+	"go.jetify.com/devbox/internal/envir"
 	"go.jetify.com/devbox/internal/pullbox/git"
 	"go.jetify.com/devbox/internal/pullbox/tar"
 	"go.jetify.com/devbox/internal/ux"
@@ -44,6 +45,9 @@ func (p *pullbox) Pull(ctx context.Context) error {
 	if p.URL == "" {
 		return usererr.New("Nothing to pull from. Pass a git repo, URL, or file path to pull from.")
 	}
+	if envir.IsOffline() && !p.isLocalConfig() {
+		return usererr.New("Cannot pull %s in offline mode. Only a local file path can be pulled.", p.URL)
+	}
 
 	notEmpty, err := profileIsNotEmpty(p.ProjectDir())
 	if err != nil {
@@ -93,6 +97,9 @@ func (p *pullbox) Push(ctx context.Context) error {
 	if p.URL == "" {
 		return usererr.New("Nowhere to push to. Pass a git repo to push to.")
 	}
+	if envir.IsOffline() {
+		return usererr.New("Cannot push to %s in offline mode.", p.URL)
+	}
 	ux.Finfof(os.Stderr, "Pushing global config to %s\n", p.URL)
 	return git.Push(ctx, p.ProjectDir(), p.URL)
 }
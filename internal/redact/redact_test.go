@@ -299,3 +299,16 @@ func checkRedactedError(t *testing.T, got error, wantMsg string) {
 		t.Errorf("got wrong redacted error:\ngot:  %q\nwant: %q", gotMsg, wantMsg)
 	}
 }
+
+func TestSecrets(t *testing.T) {
+	AddSecrets("hunter2-pw", "", "hunter2-pw2", "dev", "5432", "12345678", "true")
+
+	got := Secrets("password=hunter2-pw2 backup=hunter2-pw user=alex env=dev port=5432 id=12345678 debug=true")
+	want := "password=<redacted> backup=<redacted> user=alex env=dev port=5432 id=12345678 debug=true"
+	if got != want {
+		t.Errorf("got Secrets() = %q, want %q", got, want)
+	}
+	if got := Secrets(""); got != "" {
+		t.Errorf("got Secrets(\"\") = %q, want empty string", got)
+	}
+}
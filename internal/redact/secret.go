@@ -0,0 +1,73 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package redact
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SecretPlaceholder replaces secret values in redacted strings.
+const SecretPlaceholder = "<redacted>"
+
+var secrets struct {
+	sync.RWMutex
+	values   []string
+	replacer *strings.Replacer
+}
+
+// minSecretLength is the length of the shortest value that [AddSecrets] marks
+// as secret. Shorter values, such as ports or environment names, are too
+// likely to appear in unrelated text.
+const minSecretLength = 8
+
+// AddSecrets marks values as secret so that [Secrets] removes them from
+// strings. Values that are shorter than 8 bytes or that are numbers or
+// booleans are ignored.
+func AddSecrets(values ...string) {
+	secrets.Lock()
+	defer secrets.Unlock()
+
+	for _, v := range values {
+		if isSecretLike(v) && !slices.Contains(secrets.values, v) {
+			secrets.values = append(secrets.values, v)
+		}
+	}
+	// Replace longer values first so that a secret that contains another
+	// secret is replaced whole.
+	slices.SortFunc(secrets.values, func(a, b string) int {
+		return cmp.Compare(len(b), len(a))
+	})
+	oldnew := make([]string, 0, 2*len(secrets.values))
+	for _, v := range secrets.values {
+		oldnew = append(oldnew, v, SecretPlaceholder)
+	}
+	secrets.replacer = strings.NewReplacer(oldnew...)
+}
+
+func isSecretLike(v string) bool {
+	if len(v) < minSecretLength {
+		return false
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return false
+	}
+	_, err := strconv.ParseBool(v)
+	return err != nil
+}
+
+// Secrets returns s with every value that was marked as secret by
+// [AddSecrets] replaced with [SecretPlaceholder].
+func Secrets(s string) string {
+	secrets.RLock()
+	defer secrets.RUnlock()
+
+	if secrets.replacer == nil {
+		return s
+	}
+	return secrets.replacer.Replace(s)
+}
@@ -10,9 +10,11 @@
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"runtime"
 
 	"github.com/pkg/errors"
+	"go.jetify.com/devbox/internal/boxcli/usererr"
 	"go.jetify.com/devbox/internal/build"
 	"go.jetify.com/devbox/internal/envir"
 	"go.jetify.com/devbox/internal/redact"
@@ -22,20 +24,42 @@
 
 var ErrNotFound = errors.New("Not found")
 
-type client struct {
-	host string
+// Backend searches for packages and resolves versioned packages to the
+// nixpkgs commits that have them.
+type Backend interface {
+	Search(ctx context.Context, query string) (*SearchResults, error)
+	// Resolve returns the latest version of the package that matches the
+	// version constraint.
+	Resolve(name, version string) (*PackageVersion, error)
+	// ResolveV2 is like Resolve, but it returns the flake installable and
+	// outputs of the package for each system.
+	ResolveV2(ctx context.Context, name, version string) (*ResolveResponse, error)
 }
 
-func Client() *client {
+// Client returns the backend that devbox uses. It's the local index at
+// DEVBOX_SEARCH_INDEX if that's set, or the search service at
+// DEVBOX_SEARCH_HOST otherwise.
+func Client() Backend {
+	if path := os.Getenv(envir.DevboxSearchIndex); path != "" {
+		return IndexBackend(path)
+	}
 	return &client{
 		host: envir.GetValueOrDefault(envir.DevboxSearchHost, searchAPIEndpoint),
 	}
 }
 
+// client is the Backend for the search service's HTTP API.
+type client struct {
+	host string
+}
+
 func (c *client) Search(ctx context.Context, query string) (*SearchResults, error) {
 	if query == "" {
 		return nil, fmt.Errorf("query should not be empty")
 	}
+	if err := c.checkOnline("search for " + query); err != nil {
+		return nil, err
+	}
 
 	endpoint, err := url.JoinPath(c.host, "v1/search")
 	if err != nil {
@@ -52,6 +76,9 @@ func (c *client) Resolve(name, version string) (*PackageVersion, error) {
 	if name == "" || version == "" {
 		return nil, fmt.Errorf("name and version should not be empty")
 	}
+	if err := c.checkOnline("resolve " + name + "@" + version); err != nil {
+		return nil, err
+	}
 
 	endpoint, err := url.JoinPath(c.host, "v1/resolve")
 	if err != nil {
@@ -73,6 +100,9 @@ func (c *client) ResolveV2(ctx context.Context, name, version string) (*ResolveR
 	if version == "" {
 		return nil, redact.Errorf("version is empty")
 	}
+	if err := c.checkOnline("resolve " + name + "@" + version); err != nil {
+		return nil, err
+	}
 
 	endpoint, err := url.JoinPath(c.host, "v2/resolve")
 	if err != nil {
@@ -85,6 +115,15 @@ func (c *client) ResolveV2(ctx context.Context, name, version string) (*ResolveR
 	return execGet[ResolveResponse](ctx, searchURL)
 }
 
+// checkOnline returns an error if devbox is in offline mode, because every
+// request to the search service uses the network.
+func (c *client) checkOnline(action string) error {
+	if !envir.IsOffline() {
+		return nil
+	}
+	return usererr.New("cannot %s in offline mode because it needs the devbox search service at %s", action, c.host)
+}
+
 var userAgent = fmt.Sprintf("Devbox/%s (%s; %s)", build.Version, runtime.GOOS, runtime.GOARCH)
 
 func execGet[T any](ctx context.Context, url string) (*T, error) {
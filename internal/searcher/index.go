@@ -0,0 +1,361 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.jetify.com/devbox/internal/nix"
+	"go.jetify.com/devbox/nix/flake"
+)
+
+// Index is a file-based package index built from the packages of one or more
+// pinned nixpkgs commits. Each commit has one version of a package, so an
+// index with several commits can resolve several versions.
+type Index struct {
+	Packages []IndexEntry `json:"packages"`
+}
+
+// IndexEntry is a package in a nixpkgs commit.
+type IndexEntry struct {
+	// Name is the package name without its version (the pname).
+	Name     string `json:"name"`
+	AttrPath string `json:"attr_path"`
+	Version  string `json:"version"`
+	Summary  string `json:"summary,omitempty"`
+
+	Commit string `json:"commit"`
+	// LastUpdated is the time of Commit in seconds since the Unix epoch.
+	LastUpdated int64 `json:"last_updated"`
+	// Systems are the systems that the package was indexed for.
+	Systems []string `json:"systems"`
+}
+
+// ReadIndex reads an index file. A file that doesn't exist is an empty index.
+func ReadIndex(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Index{Packages: []IndexEntry{}}, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	index := &Index{}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, errors.Wrapf(err, "parse search index %s", path)
+	}
+	return index, nil
+}
+
+// WriteFile writes the index to path.
+func (i *Index) WriteFile(path string) error {
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(path, append(data, '\n'), 0o644))
+}
+
+// Add adds entries to the index. Entries for a package that is already
+// indexed at the same commit add their systems to the existing entry.
+func (i *Index) Add(entries ...IndexEntry) {
+	type key struct{ commit, attrPath string }
+	existing := make(map[key]int, len(i.Packages))
+	for j, entry := range i.Packages {
+		existing[key{entry.Commit, entry.AttrPath}] = j
+	}
+	for _, entry := range entries {
+		j, ok := existing[key{entry.Commit, entry.AttrPath}]
+		if !ok {
+			existing[key{entry.Commit, entry.AttrPath}] = len(i.Packages)
+			i.Packages = append(i.Packages, entry)
+			continue
+		}
+		systems := slices.Concat(i.Packages[j].Systems, entry.Systems)
+		slices.Sort(systems)
+		i.Packages[j].Systems = slices.Compact(systems)
+	}
+	slices.SortFunc(i.Packages, func(a, b IndexEntry) int {
+		return cmp.Or(
+			strings.Compare(a.Name, b.Name),
+			strings.Compare(a.AttrPath, b.AttrPath),
+			cmp.Compare(b.LastUpdated, a.LastUpdated),
+		)
+	})
+}
+
+// IndexNixpkgs returns the index entries for the packages of a nixpkgs flake
+// on the current system. If packages is nil, they're listed with `nix search
+// --json`. Otherwise, packages is the output of `nix search --json` or
+// `nix-env -qa --json` for that nixpkgs commit.
+func IndexNixpkgs(ctx context.Context, nixpkgs flake.Ref, packages []byte) ([]IndexEntry, error) {
+	meta, err := nix.ResolveFlake(ctx, nixpkgs, false)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Locked.Rev == "" {
+		return nil, errors.Errorf("%s isn't locked to a git commit", nixpkgs)
+	}
+	if packages == nil {
+		packages, err = nix.Command("search", "--json", meta.Locked, "^").Output(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return parseNixPackages(packages, meta.Locked.Rev, meta.LastModified, nix.System())
+}
+
+// parseNixPackages parses the output of `nix search --json` or `nix-env -qa
+// --json` into index entries for a nixpkgs commit. Packages without a pname
+// or version can't be resolved and are skipped.
+func parseNixPackages(data []byte, commit string, lastUpdated int64, system string) ([]IndexEntry, error) {
+	var packages map[string]struct {
+		PName       string `json:"pname"`
+		Version     string `json:"version"`
+		Description string `json:"description"`
+		System      string `json:"system"`
+		Meta        struct {
+			Description string `json:"description"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal(data, &packages); err != nil {
+		return nil, fmt.Errorf("parse nix package list: %w", err)
+	}
+
+	entries := make([]IndexEntry, 0, len(packages))
+	for key, pkg := range packages {
+		if pkg.PName == "" || pkg.Version == "" {
+			continue
+		}
+		// nix search keys are flake output paths such as
+		// legacyPackages.x86_64-linux.hello, and nix-env keys are
+		// attribute paths.
+		attrPath, pkgSystem := key, cmp.Or(pkg.System, system)
+		if rest, ok := strings.CutPrefix(key, "legacyPackages."); ok {
+			pkgSystem, attrPath, _ = strings.Cut(rest, ".")
+		}
+		entries = append(entries, IndexEntry{
+			Name:        pkg.PName,
+			AttrPath:    attrPath,
+			Version:     pkg.Version,
+			Summary:     cmp.Or(pkg.Description, pkg.Meta.Description),
+			Commit:      commit,
+			LastUpdated: lastUpdated,
+			Systems:     []string{pkgSystem},
+		})
+	}
+	return entries, nil
+}
+
+// IndexBackend returns a Backend that searches and resolves packages with the
+// index file at path. It doesn't use the network.
+func IndexBackend(path string) Backend {
+	return &indexBackend{path: path}
+}
+
+type indexBackend struct {
+	path string
+}
+
+// loadedIndexes caches indexes by path, because they can be large and a
+// backend is created for every request.
+var loadedIndexes sync.Map
+
+func (b *indexBackend) index() (*Index, error) {
+	if index, ok := loadedIndexes.Load(b.path); ok {
+		return index.(*Index), nil
+	}
+	if _, err := os.Stat(b.path); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	index, err := ReadIndex(b.path)
+	if err != nil {
+		return nil, err
+	}
+	loadedIndexes.Store(b.path, index)
+	return index, nil
+}
+
+func (b *indexBackend) Search(ctx context.Context, query string) (*SearchResults, error) {
+	if query == "" {
+		return nil, fmt.Errorf("query should not be empty")
+	}
+	index, err := b.index()
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	byName := map[string][]*IndexEntry{}
+	for i := range index.Packages {
+		entry := &index.Packages[i]
+		if strings.Contains(strings.ToLower(entry.Name), query) ||
+			strings.Contains(strings.ToLower(entry.AttrPath), query) {
+			byName[entry.Name] = append(byName[entry.Name], entry)
+		}
+	}
+
+	results := &SearchResults{Packages: []Package{}}
+	for name, entries := range byName {
+		pkg := Package{Name: name}
+		first := firstUpdated(entries)
+		for _, entry := range newestEntries(entries) {
+			pkg.Versions = append(pkg.Versions, entry.packageVersion(first[entry.Version]))
+		}
+		pkg.NumVersions = len(pkg.Versions)
+		results.Packages = append(results.Packages, pkg)
+	}
+	// Exact matches first, then by name.
+	slices.SortFunc(results.Packages, func(a, b Package) int {
+		return cmp.Or(
+			-compareBool(strings.ToLower(a.Name) == query, strings.ToLower(b.Name) == query),
+			strings.Compare(a.Name, b.Name),
+		)
+	})
+	results.NumResults = len(results.Packages)
+	return results, nil
+}
+
+func (b *indexBackend) Resolve(name, version string) (*PackageVersion, error) {
+	entry, err := b.resolve(name, version)
+	if err != nil {
+		return nil, err
+	}
+	index, err := b.index()
+	if err != nil {
+		return nil, err
+	}
+	var sameVersion []*IndexEntry
+	for i := range index.Packages {
+		if other := &index.Packages[i]; other.Name == entry.Name && other.Version == entry.Version {
+			sameVersion = append(sameVersion, other)
+		}
+	}
+	pkgVersion := entry.packageVersion(firstUpdated(sameVersion)[entry.Version])
+	return &pkgVersion, nil
+}
+
+func (b *indexBackend) ResolveV2(ctx context.Context, name, version string) (*ResolveResponse, error) {
+	entry, err := b.resolve(name, version)
+	if err != nil {
+		return nil, err
+	}
+	resolved := &ResolveResponse{
+		Name:    entry.Name,
+		Version: entry.Version,
+		Summary: entry.Summary,
+		Systems: make(map[string]ResolvedSystem, len(entry.Systems)),
+	}
+	for _, system := range entry.Systems {
+		resolved.Systems[system] = ResolvedSystem{
+			FlakeInstallable: flake.Installable{
+				Ref: flake.Ref{
+					Type:  flake.TypeGitHub,
+					Owner: "NixOS",
+					Repo:  "nixpkgs",
+					Rev:   entry.Commit,
+				},
+				AttrPath: entry.AttrPath,
+			},
+			LastUpdated: time.Unix(entry.LastUpdated, 0).UTC(),
+		}
+	}
+	return resolved, nil
+}
+
+// resolve returns the newest version of a package, by name or attribute path,
+// that matches a version constraint.
+func (b *indexBackend) resolve(name, version string) (*IndexEntry, error) {
+	if name == "" || version == "" {
+		return nil, fmt.Errorf("name and version should not be empty")
+	}
+	index, err := b.index()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*IndexEntry
+	for i := range index.Packages {
+		entry := &index.Packages[i]
+		if (entry.Name == name || entry.AttrPath == name) && VersionMatches(version, entry.Version) {
+			matches = append(matches, entry)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, ErrNotFound
+	}
+	return newestEntries(matches)[0], nil
+}
+
+// newestEntries returns the entry with the newest commit for each version,
+// sorted from the newest version to the oldest.
+func newestEntries(entries []*IndexEntry) []*IndexEntry {
+	byVersion := map[string]*IndexEntry{}
+	for _, entry := range entries {
+		if newest := byVersion[entry.Version]; newest == nil || entry.LastUpdated > newest.LastUpdated {
+			byVersion[entry.Version] = entry
+		}
+	}
+	newest := make([]*IndexEntry, 0, len(byVersion))
+	for _, entry := range byVersion {
+		newest = append(newest, entry)
+	}
+	slices.SortFunc(newest, func(a, b *IndexEntry) int {
+		return cmp.Or(
+			CompareVersions(b.Version, a.Version),
+			strings.Compare(a.AttrPath, b.AttrPath),
+		)
+	})
+	return newest
+}
+
+// firstUpdated returns the time of the oldest commit that has each version.
+func firstUpdated(entries []*IndexEntry) map[string]int64 {
+	first := map[string]int64{}
+	for _, entry := range entries {
+		if t, ok := first[entry.Version]; !ok || entry.LastUpdated < t {
+			first[entry.Version] = entry.LastUpdated
+		}
+	}
+	return first
+}
+
+func (e *IndexEntry) packageVersion(firstUpdated int64) PackageVersion {
+	info := PackageInfo{
+		CommitHash:   e.Commit,
+		LastUpdated:  int(e.LastUpdated),
+		FirstUpdated: int(firstUpdated),
+		AttrPaths:    []string{e.AttrPath},
+		Version:      e.Version,
+		Summary:      e.Summary,
+	}
+	systems := make(map[string]PackageInfo, len(e.Systems))
+	for _, system := range e.Systems {
+		systemInfo := info
+		systemInfo.System = system
+		systems[system] = systemInfo
+	}
+	return PackageVersion{PackageInfo: info, Name: e.Name, Systems: systems}
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,149 @@
+package searcher
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"go.jetify.com/devbox/internal/envir"
+)
+
+// nixSearchOutput and nixEnvOutput list the same packages in the formats of
+// `nix search --json` and `nix-env -qa --json`.
+const (
+	nixSearchOutput = `{
+  "legacyPackages.x86_64-linux.go_1_22": {"pname": "go", "version": "1.22.5", "description": "The Go Programming language"},
+  "legacyPackages.x86_64-linux.hello": {"pname": "hello", "version": "2.12.1", "description": "A program that produces a familiar, friendly greeting"},
+  "legacyPackages.x86_64-linux.someDerivation": {"pname": "", "version": ""}
+}`
+	nixEnvOutput = `{
+  "go_1_22": {"name": "go-1.22.5", "pname": "go", "version": "1.22.5", "system": "aarch64-darwin", "meta": {"description": "The Go Programming language"}},
+  "hello": {"name": "hello-2.12.1", "pname": "hello", "version": "2.12.1", "system": "aarch64-darwin", "meta": {"description": "A program that produces a familiar, friendly greeting"}}
+}`
+)
+
+func testIndex(t *testing.T) string {
+	t.Helper()
+
+	index := &Index{}
+	for _, output := range []string{nixSearchOutput, nixEnvOutput} {
+		entries, err := parseNixPackages([]byte(output), "commit-old", 1700000000, "x86_64-linux")
+		if err != nil {
+			t.Fatal(err)
+		}
+		index.Add(entries...)
+	}
+	index.Add(IndexEntry{
+		Name:        "go",
+		AttrPath:    "go",
+		Version:     "1.23.1",
+		Commit:      "commit-new",
+		LastUpdated: 1720000000,
+		Systems:     []string{"x86_64-linux"},
+	})
+	path := filepath.Join(t.TempDir(), "index.json")
+	if err := index.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseNixPackages(t *testing.T) {
+	index, err := ReadIndex(testIndex(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The entries from both formats are merged into one per package and
+	// commit.
+	if len(index.Packages) != 3 {
+		t.Fatalf("got %d index entries, want 3: %+v", len(index.Packages), index.Packages)
+	}
+	hello := index.Packages[2]
+	if hello.Name != "hello" || hello.AttrPath != "hello" || hello.Version != "2.12.1" {
+		t.Errorf("got entry %+v, want hello 2.12.1", hello)
+	}
+	if want := []string{"aarch64-darwin", "x86_64-linux"}; len(hello.Systems) != 2 ||
+		hello.Systems[0] != want[0] || hello.Systems[1] != want[1] {
+		t.Errorf("got hello systems %v, want %v", hello.Systems, want)
+	}
+}
+
+func TestIndexBackend(t *testing.T) {
+	backend := IndexBackend(testIndex(t))
+
+	for _, test := range []struct {
+		name, version, wantVersion, wantCommit string
+	}{
+		{"go", "latest", "1.23.1", "commit-new"},
+		{"go", "1.22", "1.22.5", "commit-old"},
+		{"go_1_22", "latest", "1.22.5", "commit-old"},
+		{"hello", "2.12.1", "2.12.1", "commit-old"},
+	} {
+		resolved, err := backend.Resolve(test.name, test.version)
+		if err != nil {
+			t.Errorf("Resolve(%q, %q) error: %v", test.name, test.version, err)
+			continue
+		}
+		if resolved.Version != test.wantVersion || resolved.CommitHash != test.wantCommit {
+			t.Errorf("Resolve(%q, %q) = %s at %s, want %s at %s", test.name, test.version,
+				resolved.Version, resolved.CommitHash, test.wantVersion, test.wantCommit)
+		}
+	}
+	if _, err := backend.Resolve("go", "1.21"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resolve of a missing version error = %v, want ErrNotFound", err)
+	}
+
+	resolved, err := backend.ResolveV2(context.Background(), "go", "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "github:NixOS/nixpkgs/commit-new#go"
+	if got := resolved.Systems["x86_64-linux"].FlakeInstallable.String(); got != want {
+		t.Errorf("ResolveV2 installable = %s, want %s", got, want)
+	}
+
+	results, err := backend.Search(context.Background(), "GO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Packages) != 1 || results.Packages[0].Name != "go" {
+		t.Fatalf("Search(%q) = %+v, want go", "GO", results.Packages)
+	}
+	versions := results.Packages[0].Versions
+	if len(versions) != 2 || versions[0].Version != "1.23.1" || versions[1].Version != "1.22.5" {
+		t.Errorf("Search(%q) versions = %+v, want 1.23.1 and 1.22.5", "GO", versions)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(NewHandler(IndexBackend(testIndex(t))))
+	t.Cleanup(srv.Close)
+	t.Setenv(envir.DevboxSearchHost, srv.URL)
+
+	resolved, err := Client().ResolveV2(context.Background(), "go", "1.22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Version != "1.22.5" {
+		t.Errorf("ResolveV2 through the handler = %s, want 1.22.5", resolved.Version)
+	}
+	pkgVersion, err := Client().Resolve("hello", "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkgVersion.Version != "2.12.1" {
+		t.Errorf("Resolve through the handler = %s, want 2.12.1", pkgVersion.Version)
+	}
+	if _, err := Client().Resolve("ripgrep", "latest"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resolve of a missing package through the handler error = %v, want ErrNotFound", err)
+	}
+	results, err := Client().Search(context.Background(), "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.NumResults != 1 {
+		t.Errorf("Search through the handler found %d results, want 1", results.NumResults)
+	}
+}
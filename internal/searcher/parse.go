@@ -5,6 +5,8 @@
 
 import (
 	"strings"
+
+	"golang.org/x/mod/semver"
 )
 
 // ParseVersionedPackage checks if the given package is a versioned package
@@ -25,3 +27,20 @@ func ParseVersionedPackage(versionedName string) (name, version string, found bo
 	name, version = versionedName[:atSymbolIndex], versionedName[atSymbolIndex+1:]
 	return name, version, true
 }
+
+// VersionMatches reports whether version satisfies a version constraint that
+// is "latest", an exact version or a prefix of versions such as "3.12".
+func VersionMatches(constraint, version string) bool {
+	return constraint == "latest" || version == constraint ||
+		strings.HasPrefix(version, constraint+".")
+}
+
+// CompareVersions compares two package versions like strings.Compare.
+// Semantic versions are compared by precedence, other versions as strings.
+func CompareVersions(a, b string) int {
+	va, vb := "v"+a, "v"+b
+	if semver.IsValid(va) && semver.IsValid(vb) {
+		return semver.Compare(va, vb)
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package searcher
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// NewHandler returns an HTTP handler that serves a backend with the same API
+// as the search service. Pointing DEVBOX_SEARCH_HOST at it makes devbox use
+// the backend, such as an index served from an internal mirror.
+func NewHandler(backend Backend) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/search", func(w http.ResponseWriter, r *http.Request) {
+		results, err := backend.Search(r.Context(), r.URL.Query().Get("q"))
+		writeResponse(w, results, err)
+	})
+	mux.HandleFunc("GET /v1/resolve", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		resolved, err := backend.Resolve(query.Get("name"), query.Get("version"))
+		writeResponse(w, resolved, err)
+	})
+	mux.HandleFunc("GET /v2/resolve", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		resolved, err := backend.ResolveV2(r.Context(), query.Get("name"), query.Get("version"))
+		writeResponse(w, resolved, err)
+	})
+	return mux
+}
+
+func writeResponse(w http.ResponseWriter, body any, err error) {
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -24,6 +24,10 @@ type flakePlan struct {
 	Packages    []*devpkg.Package
 	FlakeInputs []flakeInput
 	System      string
+
+	// Groups are the optional package groups that are installed. Their
+	// packages are already in Packages.
+	Groups []string
 }
 
 func newFlakePlan(ctx context.Context, devbox devboxer) (*flakePlan, error) {
@@ -49,9 +53,19 @@ func newFlakePlan(ctx context.Context, devbox devboxer) (*flakePlan, error) {
 		Stdenv:      devbox.Lockfile().Stdenv(),
 		Packages:    packages,
 		System:      nix.System(),
+		Groups:      devbox.Config().SelectedGroups(),
 	}, nil
 }
 
+// Description returns the description of the flake, which names the selected
+// groups.
+func (f *flakePlan) Description() string {
+	if len(f.Groups) == 0 {
+		return "A devbox shell"
+	}
+	return "A devbox shell with groups " + strings.Join(f.Groups, ", ")
+}
+
 func (f *flakePlan) needsGlibcPatch() bool {
 	for _, in := range f.FlakeInputs {
 		if in.Ref == glibcPatchFlakeRef {
@@ -32,7 +32,7 @@ func getNixpkgsInfo(commitHash string) *NixpkgsInfo {
 
 func nixpkgsMirrorURL(commitHash string) string {
 	baseURL := os.Getenv(envir.DevboxCache)
-	if baseURL == "" {
+	if baseURL == "" || envir.IsOffline() {
 		return ""
 	}
 
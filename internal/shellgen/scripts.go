@@ -5,8 +5,10 @@
 	_ "embed"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"text/template"
 
@@ -14,6 +16,7 @@
 	"go.jetify.com/devbox/internal/boxcli/featureflag"
 	"go.jetify.com/devbox/internal/debug"
 	"go.jetify.com/devbox/internal/devconfig"
+	"go.jetify.com/devbox/internal/devconfig/configfile"
 	"go.jetify.com/devbox/internal/devpkg"
 	"go.jetify.com/devbox/internal/lock"
 	"go.jetify.com/devbox/internal/plugin"
@@ -61,8 +64,9 @@ func WriteScriptsToFiles(devbox devboxer) error {
 	written[HooksFilename] = struct{}{}
 
 	// Write scripts to files.
-	for name, body := range devbox.Config().Scripts() {
-		scriptBody, err := ScriptBody(devbox, body.String())
+	scripts := devbox.Config().Scripts()
+	for name := range scripts {
+		scriptBody, err := ScriptBody(devbox, withDependencies(devbox.ProjectDir(), scripts, name))
 		if err != nil {
 			return errors.WithStack(err)
 		}
@@ -87,6 +91,74 @@ func WriteScriptsToFiles(devbox devboxer) error {
 	return nil
 }
 
+// withDependencies returns the body of the named script preceded by the
+// commands that run the scripts it depends on. Each dependency is defined as a
+// shell function and runs once in a subshell, so that it sees everything the
+// init hook set up without running the hook again. The dependencies in a level
+// run in parallel, and a level only starts after the previous one succeeds.
+func withDependencies(projectDir string, scripts configfile.Scripts, name string) string {
+	levels := scripts.DependencyLevels(name)
+	if len(levels) == 0 {
+		return scriptCommands(projectDir, &scripts[name].ScriptConfig)
+	}
+
+	b := strings.Builder{}
+	funcs := map[string]string{}
+	for _, level := range levels {
+		for _, dep := range level {
+			funcs[dep] = fmt.Sprintf("__devbox_script_%d", len(funcs))
+			// The no-op keeps the function valid when the script has
+			// no commands of its own.
+			fmt.Fprintf(&b, "%s() {\n:\n%s\n}\n\n", funcs[dep],
+				scriptCommands(projectDir, &scripts[dep].ScriptConfig))
+		}
+	}
+	for _, level := range levels {
+		fmt.Fprintf(&b, "# Run %s.\n", strings.Join(level, ", "))
+		if len(level) == 1 {
+			// Run a lone script in the foreground so that it can
+			// read stdin.
+			fmt.Fprintf(&b, "( %s ) || exit $?\n\n", funcs[level[0]])
+			continue
+		}
+		b.WriteString("__devbox_pids=\n")
+		for _, dep := range level {
+			fmt.Fprintf(&b, "( %s ) & __devbox_pids=\"$__devbox_pids $!\"\n", funcs[dep])
+		}
+		b.WriteString("for __devbox_pid in $__devbox_pids; do\n")
+		b.WriteString("    wait \"$__devbox_pid\" || __devbox_status=$?\n")
+		b.WriteString("done\n")
+		b.WriteString("[ \"${__devbox_status:-0}\" -eq 0 ] || exit \"$__devbox_status\"\n\n")
+	}
+	b.WriteString(scriptCommands(projectDir, &scripts[name].ScriptConfig))
+	return b.String()
+}
+
+// scriptCommands returns the commands of a script, preceded by the commands
+// that change to its directory and export its env.
+func scriptCommands(projectDir string, script *configfile.ScriptConfig) string {
+	if script.Cwd == "" && len(script.Env) == 0 {
+		return script.String()
+	}
+
+	b := strings.Builder{}
+	if script.Cwd != "" {
+		cwd := script.Cwd
+		if !filepath.IsAbs(cwd) {
+			cwd = filepath.Join(projectDir, cwd)
+		}
+		fmt.Fprintf(&b, "cd '%s' || exit\n", strings.ReplaceAll(cwd, "'", `'\''`))
+	}
+	for _, k := range slices.Sorted(maps.Keys(script.Env)) {
+		// Leave $ unescaped so that values can reference other
+		// variables.
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`").Replace(script.Env[k])
+		fmt.Fprintf(&b, "export %s=\"%s\"\n", k, v)
+	}
+	b.WriteString(script.String())
+	return b.String()
+}
+
 func writeRawInitHookFile(devbox devboxer, body string) (err error) {
 	script, err := createScriptFile(devbox, HooksFilename)
 	if err != nil {
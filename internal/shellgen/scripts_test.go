@@ -0,0 +1,75 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package shellgen
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.jetify.com/devbox/internal/devconfig/configfile"
+)
+
+func TestWithDependencies(t *testing.T) {
+	cfg, err := configfile.LoadBytes([]byte(`{
+  "shell": {
+    "scripts": {
+      "build":    {"cmds": ["echo build $1"], "depends_on": ["generate", "lint"]},
+      "deps":     "echo deps",
+      "generate": {"cmds": "echo generate", "depends_on": ["deps"]},
+      "lint":     {"depends_on": ["deps"]},
+      "fail":     {"cmds": "echo fail", "depends_on": ["broken"]},
+      "broken":   "exit 3"
+    }
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scripts := cfg.Scripts()
+
+	out, err := exec.Command("sh", "-c", withDependencies(t.TempDir(), scripts, "build"), "sh", "arg").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// deps runs once, before generate, and build runs last with the
+	// script's arguments.
+	if got, want := string(out), "deps\ngenerate\nbuild arg\n"; got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+
+	out, err = exec.Command("sh", "-c", withDependencies(t.TempDir(), scripts, "fail")).Output()
+	exitErr := &exec.ExitError{}
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("got error %v, want exit status 3", err)
+	}
+	if strings.Contains(string(out), "fail") {
+		t.Errorf("script ran after its dependency failed: %q", out)
+	}
+}
+
+func TestScriptCommands(t *testing.T) {
+	projectDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(projectDir, "sub dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	script := &configfile.ScriptConfig{
+		Cwd: "sub dir",
+		Env: map[string]string{"GREETING": `hello "$NAME"`},
+	}
+	script.AppendScript(`echo "$GREETING from $(basename "$PWD")"`)
+
+	cmd := exec.Command("sh", "-c", scriptCommands(projectDir, script))
+	cmd.Env = append(os.Environ(), "NAME=devbox")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out), "hello \"devbox\" from sub dir\n"; got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+}
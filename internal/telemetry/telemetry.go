@@ -83,13 +83,18 @@ func Stop() {
 	if !started || !needsFlush.Load() {
 		return
 	}
+	started = false
+
+	// Events stay buffered on disk until a command runs online.
+	if envir.IsOffline() {
+		return
+	}
 
 	// Report errors in a separate process so we don't block exiting.
 	exe, err := os.Executable()
 	if err == nil {
 		_ = exec.Command(exe, "upload-telemetry").Start()
 	}
-	started = false
 }
 
 func Event(e EventName, meta Metadata) {
@@ -261,6 +266,9 @@ func (m *Metadata) pkgContext() map[string]any {
 )
 
 func Upload() {
+	if envir.IsOffline() {
+		return
+	}
 	wg := sync.WaitGroup{} //nolint:varnamelen
 	wg.Add(2)
 	go func() {
@@ -65,7 +65,7 @@ func CheckVersion(w io.Writer, commandPath string) {
 		return
 	}
 
-	if envir.IsDevboxCloud() {
+	if envir.IsDevboxCloud() || envir.IsOffline() {
 		return
 	}
 
@@ -101,6 +101,10 @@ func(skipPath string) bool { return strings.HasPrefix(commandPath, skipPath) },
 // for devbox. The production devbox application is actually this launcher script
 // that acts as "devbox" and delegates commands to the devbox CLI binary.
 func SelfUpdate(stdOut, stdErr io.Writer) error {
+	if envir.IsOffline() {
+		return usererr.New("cannot update devbox in offline mode, it needs to download the " +
+			"latest release. Unset " + envir.DevboxOffline + " and remove --offline to update.")
+	}
 	if isNewLauncherAvailable() {
 		return selfUpdateLauncher(stdOut, stdErr)
 	}
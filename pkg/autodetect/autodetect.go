@@ -25,7 +25,7 @@ func DryRun(ctx context.Context, path string) ([]byte, error) {
 	if err := populateConfig(ctx, path, config); err != nil {
 		return nil, err
 	}
-	return config.Root.Bytes(), nil
+	return config.Root.Bytes()
 }
 
 func populateConfig(ctx context.Context, path string, config *devconfig.Config) error {